@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/4domm/ngxstat/internal/infrastructure/client"
 	"github.com/4domm/ngxstat/internal/infrastructure/generator"
@@ -21,25 +24,70 @@ func main() {
 		return
 	}
 
-	nginxParser := parser.NginxParser{}
+	logParser, err := newLogParser(config)
+	if err != nil {
+		fmt.Printf("error %v\n", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	var linesReader service.Reader
-	if strings.HasPrefix(config.Path, "http://") || strings.HasPrefix(config.Path, "https://") {
+
+	switch {
+	case config.Follow, config.Command == domain.SERVE:
+		linesReader = reader.NewTailReader(ctx, reader.DefaultPollInterval)
+	case client.IsURL(config.Path):
 		linesReader = &reader.URLReader{}
-	} else {
+	default:
 		linesReader = &reader.FileReader{}
 	}
 
-	analyticsService := service.NewAnalyticsService(nginxParser, linesReader)
+	analyticsService := service.NewAnalyticsService(logParser, linesReader)
 	writer := generator.FileWriter{}
 	markdownReportGen := generator.NewMarkdownReportGenerator(writer)
 	adocReportGen := generator.NewAdocReportGenerator(writer)
+	jsonReportGen := generator.NewJSONReportGenerator(writer)
+	htmlReportGen := generator.NewHTMLReportGenerator(writer)
 	generators := map[string]app.ReportGenerator{
 		domain.ADOC:     adocReportGen,
 		domain.MARKDOWN: markdownReportGen,
+		domain.JSON:     jsonReportGen,
+		domain.HTML:     htmlReportGen,
 		"":              markdownReportGen,
 	}
-	application := app.NewApplication(generators, config, nginxParser, analyticsService, writer)
+	application := app.NewApplication(generators, config, logParser, analyticsService, writer)
+
+	if err := application.Run(); err != nil {
+		fmt.Printf("error %v\n", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+func newLogParser(config *domain.InputConfig) (parser.LogParser, error) {
+	if config.Parser == domain.AUTO {
+		candidates, err := parser.DefaultCandidates(config.LogFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		return parser.NewDetectingParser(config.DetectLines, candidates...), nil
+	}
+
+	if config.Parser == domain.JSON {
+		mapping, err := parser.ParseJSONFieldMapping(config.JSONFields, config.JSONTimeLayout)
+		if err != nil {
+			return nil, err
+		}
+
+		return parser.NewJSONParser(mapping), nil
+	}
+
+	if config.LogFormat != "" {
+		return parser.NewFormatParser(config.LogFormat)
+	}
 
-	application.Run()
+	return parser.NginxParser{}, nil
 }
@@ -4,7 +4,9 @@ go 1.22.6
 
 require (
 	github.com/HdrHistogram/hdrhistogram-go v1.1.2
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.7.0
+	github.com/ulikunitz/xz v0.5.15
 )
 
 require (
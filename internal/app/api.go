@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/filter"
+	"github.com/4domm/ngxstat/internal/infrastructure/client"
+)
+
+// ReportPath — путь, по которому команда api отдаёт отчёты.
+const ReportPath = "/report"
+
+// reportContentTypes — Content-Type ответа по формату отчёта; других форматов API не отдаёт.
+var reportContentTypes = map[string]string{
+	domain.JSON:     "application/json; charset=utf-8",
+	domain.MARKDOWN: "text/markdown; charset=utf-8",
+	domain.ADOC:     "text/asciidoc; charset=utf-8",
+	domain.HTML:     "text/html; charset=utf-8",
+}
+
+// api отдаёт отчёты по логам InputConfig.Path на InputConfig.Listen до сигнала завершения.
+func (a *Application) api() error {
+	listener, err := net.Listen("tcp", a.InputConfig.Listen)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle(http.MethodGet+" "+ReportPath, a.ReportHandler())
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	fmt.Printf("api: http://%s%s\n", listener.Addr(), ReportPath)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+
+	if httpErr := <-serveErr; err == nil && !errors.Is(httpErr, http.ErrServerClosed) {
+		err = httpErr
+	}
+
+	return err
+}
+
+// ReportHandler строит отчёт по запросу GET /report?from=&to=&filter=&format=: период и фильтры
+// запроса дополняют настройки запуска, формат по умолчанию — json. Ошибка в параметрах — ответ 400,
+// ошибка анализа — 500.
+func (a *Application) ReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inputConfig, err := a.reportConfig(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := a.AnalyticsService.Process(inputConfig)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Отчёт собирается целиком до ответа, чтобы ошибка генерации успела стать кодом 500.
+		var body bytes.Buffer
+
+		if err := a.Generators[inputConfig.OutputFormat].WriteReport(&body, result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", reportContentTypes[inputConfig.OutputFormat])
+		_, _ = body.WriteTo(w)
+	})
+}
+
+// reportConfig возвращает копию настроек запуска с параметрами запроса /report.
+func (a *Application) reportConfig(query url.Values) (*domain.InputConfig, error) {
+	inputConfig := *a.InputConfig
+
+	inputConfig.OutputFormat = query.Get("format")
+	if inputConfig.OutputFormat == "" {
+		inputConfig.OutputFormat = domain.JSON
+	}
+
+	if _, ok := reportContentTypes[inputConfig.OutputFormat]; !ok || a.Generators[inputConfig.OutputFormat] == nil {
+		return nil, &domain.InvalidOutputFormatError{Format: inputConfig.OutputFormat}
+	}
+
+	var err error
+
+	if from := query.Get("from"); from != "" {
+		if inputConfig.From, err = parseQueryDate(from); err != nil {
+			return nil, err
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		if inputConfig.To, err = parseQueryDate(to); err != nil {
+			return nil, err
+		}
+	}
+
+	// Concat копирует фильтры запуска: срез общий для всех запросов.
+	inputConfig.Filters = slices.Concat(a.InputConfig.Filters, query["filter"])
+
+	if _, err := filter.ForConfig(&inputConfig); err != nil {
+		return nil, err
+	}
+
+	return &inputConfig, nil
+}
+
+// parseQueryDate разбирает дату из query string. Незакодированный «+» смещения (…T10:00:00+0300)
+// декодируется в пробел, поэтому пробел возвращается обратно: иначе такие запросы получали бы 400.
+func parseQueryDate(value string) (time.Time, error) {
+	return client.ParseDate(strings.ReplaceAll(value, " ", "+"))
+}
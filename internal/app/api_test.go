@@ -0,0 +1,164 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/app"
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/generator"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/4domm/ngxstat/internal/infrastructure/reader"
+	"github.com/4domm/ngxstat/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIApplication(t *testing.T) *app.Application {
+	t.Helper()
+
+	formatParser, err := parser.NewFormatParser(parser.CommonLogFormat)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join([]string{
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /users/1 HTTP/1.1" 200 100`,
+		`10.0.0.1 - - [11/Oct/2023:13:55:37 +0000] "GET /users/2 HTTP/1.1" 200 5000`,
+		`10.0.0.2 - - [12/Oct/2023:13:55:38 +0000] "POST /orders HTTP/1.1" 502 50`,
+	}, "\n")), 0o600))
+
+	writer := generator.FileWriter{}
+	generators := map[string]app.ReportGenerator{
+		domain.JSON:     generator.NewJSONReportGenerator(writer),
+		domain.MARKDOWN: generator.NewMarkdownReportGenerator(writer),
+		domain.ADOC:     generator.NewAdocReportGenerator(writer),
+	}
+	inputConfig := &domain.InputConfig{Command: domain.API, Path: path, Workers: 2}
+	application := app.NewApplication(generators, inputConfig, formatParser,
+		service.NewAnalyticsService(formatParser, &reader.FileReader{}), writer)
+
+	return &application
+}
+
+func getReport(t *testing.T, handler http.Handler, query url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, app.ReportPath+"?"+query.Encode(), http.NoBody))
+
+	return recorder
+}
+
+func decodeTotal(t *testing.T, recorder *httptest.ResponseRecorder) int64 {
+	t.Helper()
+
+	var report generator.JSONReport
+
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+
+	return report.Requests.Total
+}
+
+func TestReportHandler(t *testing.T) {
+	handler := newAPIApplication(t).ReportHandler()
+
+	tests := []struct {
+		name  string
+		query url.Values
+		total int64
+	}{
+		{name: "all", query: url.Values{}, total: 3},
+		{name: "period", query: url.Values{"from": {"2023-10-11"}, "to": {"2023-10-12"}}, total: 1},
+		{name: "filters", query: url.Values{"filter": {`method == "GET"`, "size > 1000"}}, total: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := getReport(t, handler, tt.query)
+
+			require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+			assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tt.total, decodeTotal(t, recorder))
+		})
+	}
+}
+
+func TestReportHandler_UnencodedOffset(t *testing.T) {
+	handler := newAPIApplication(t).ReportHandler()
+
+	// «+» смещения часового пояса не закодирован как %2B и приходит в обработчик пробелом.
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+		app.ReportPath+"?from=2023-10-11T16:00:00+0300&to=2023-10-12T16:55:38+0300", http.NoBody))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, int64(2), decodeTotal(t, recorder))
+}
+
+func TestReportHandler_Formats(t *testing.T) {
+	handler := newAPIApplication(t).ReportHandler()
+
+	markdown := getReport(t, handler, url.Values{"format": {domain.MARKDOWN}})
+	require.Equal(t, http.StatusOK, markdown.Code)
+	assert.Equal(t, "text/markdown; charset=utf-8", markdown.Header().Get("Content-Type"))
+	assert.Contains(t, markdown.Body.String(), "/users/1")
+
+	adoc := getReport(t, handler, url.Values{"format": {domain.ADOC}})
+	require.Equal(t, http.StatusOK, adoc.Code)
+	assert.True(t, strings.HasPrefix(adoc.Header().Get("Content-Type"), "text/asciidoc"))
+	assert.Contains(t, adoc.Body.String(), "/orders")
+}
+
+func TestReportHandler_BadRequest(t *testing.T) {
+	handler := newAPIApplication(t).ReportHandler()
+
+	for _, query := range []url.Values{
+		{"format": {"xml"}},
+		{"format": {domain.HTML}},
+		{"from": {"yesterday"}},
+		{"filter": {"status >="}},
+	} {
+		recorder := getReport(t, handler, query)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query.Encode())
+	}
+}
+
+func TestReportHandler_ConcurrentRequests(t *testing.T) {
+	handler := newAPIApplication(t).ReportHandler()
+
+	const requests = 8
+
+	totals := make([]int64, requests)
+
+	var wg sync.WaitGroup
+
+	for i := range totals {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, app.ReportPath, http.NoBody))
+
+			var report generator.JSONReport
+			if json.Unmarshal(recorder.Body.Bytes(), &report) == nil {
+				totals[i] = report.Requests.Total
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, total := range totals {
+		assert.Equal(t, int64(3), total)
+	}
+}
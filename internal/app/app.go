@@ -1,6 +1,10 @@
 package app
 
 import (
+	"fmt"
+	"io"
+	"time"
+
 	"github.com/4domm/ngxstat/internal/domain"
 	"github.com/4domm/ngxstat/internal/infrastructure/generator"
 	"github.com/4domm/ngxstat/internal/infrastructure/parser"
@@ -9,6 +13,9 @@ import (
 
 type ReportGenerator interface {
 	GenerateReport(result *domain.AnalysisResult)
+	GenerateDiffReport(comparison *domain.Comparison)
+	// WriteReport пишет отчёт в произвольный поток, например в ответ команды api.
+	WriteReport(w io.Writer, result *domain.AnalysisResult) error
 
 	GenerateExceptionReport(filePath string, message string)
 	GetErrorFilePath() string
@@ -37,14 +44,76 @@ func NewApplication(
 		FileWriter:       writer,
 	}
 }
-func (a *Application) Run() {
+func (a *Application) Run() error {
 	reportGenerator := a.Generators[a.InputConfig.OutputFormat]
-	res, err := a.AnalyticsService.Process(a.InputConfig)
+
+	if a.InputConfig.Follow {
+		return a.follow(reportGenerator)
+	}
+
+	var (
+		res *domain.AnalysisResult
+		err error
+	)
+
+	switch a.InputConfig.Command {
+	case domain.SNAPSHOT:
+		return a.snapshot()
+	case domain.DIFF:
+		return a.diff(reportGenerator)
+	case domain.SERVE:
+		return a.serve()
+	case domain.API:
+		return a.api()
+	case domain.MERGE:
+		res, err = a.merge()
+	default:
+		res, err = a.AnalyticsService.Process(a.InputConfig)
+	}
 
 	if err != nil {
 		reportGenerator.GenerateExceptionReport(reportGenerator.GetErrorFilePath(), err.Error())
-		return
+		return err
 	}
 
 	reportGenerator.GenerateReport(res)
+
+	if err := a.checkErrorRate(res); err != nil {
+		reportGenerator.GenerateExceptionReport(reportGenerator.GetErrorFilePath(), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// follow перерисовывает отчёт при каждом обновлении окна и печатает краткую сводку в терминал.
+func (a *Application) follow(reportGenerator ReportGenerator) error {
+	err := a.AnalyticsService.Follow(a.InputConfig, func(res *domain.AnalysisResult) {
+		reportGenerator.GenerateReport(res)
+		fmt.Println(FormatSummary(res))
+	})
+
+	if err != nil {
+		reportGenerator.GenerateExceptionReport(reportGenerator.GetErrorFilePath(), err.Error())
+	}
+
+	return err
+}
+
+// FormatSummary возвращает однострочную сводку по результату для вывода в терминал.
+func FormatSummary(res *domain.AnalysisResult) string {
+	var top string
+
+	var topCount int64
+
+	for resource, count := range res.MostRequestedResources {
+		if count > topCount || (count == topCount && resource < top) {
+			top, topCount = resource, count
+		}
+	}
+
+	return fmt.Sprintf("[%s] requests=%d 5xx=%d avg_size=%.0f p95_size=%d p95_latency=%v top=%s(%d) failed_lines=%d",
+		res.To.Format(time.TimeOnly), res.TotalRequests, res.TotalServerErrorsLogs, res.AverageResponseSize,
+		res.Percentile95ResponseSize, res.RequestLatency.P95, top, topCount, res.ParseErrors.Total)
 }
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// diff сравнивает текущий период (--path, --from, --to) с базовым (--base-path, --base-from, --base-to)
+// и пишет отчёт с изменениями. Порог --max-error-rate проверяется для обоих периодов.
+func (a *Application) diff(reportGenerator ReportGenerator) error {
+	comparison, err := a.compare()
+	if err == nil {
+		err = a.checkErrorRate(comparison.Base, comparison.Current)
+	}
+
+	if err != nil {
+		reportGenerator.GenerateExceptionReport(reportGenerator.GetErrorFilePath(), err.Error())
+		return err
+	}
+
+	reportGenerator.GenerateDiffReport(comparison)
+
+	return nil
+}
+
+func (a *Application) compare() (*domain.Comparison, error) {
+	return a.AnalyticsService.Compare(a.InputConfig.BaseConfig(), a.InputConfig)
+}
+
+func (a *Application) checkErrorRate(results ...*domain.AnalysisResult) error {
+	for _, result := range results {
+		if rate := result.ParseErrorRate(); rate > a.InputConfig.MaxErrorRate {
+			return &domain.ErrorRateExceededError{Rate: rate, MaxRate: a.InputConfig.MaxErrorRate}
+		}
+	}
+
+	return nil
+}
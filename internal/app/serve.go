@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/infrastructure/metrics"
+	"github.com/4domm/ngxstat/internal/service"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// serve следит за логами и отдаёт метрики на InputConfig.Listen, пока читатель не закроет поток
+// (по сигналу завершения). Адрес занимается до чтения логов, чтобы ошибка сразу завершала запуск.
+func (a *Application) serve() error {
+	listener, err := net.Listen("tcp", a.InputConfig.Listen)
+	if err != nil {
+		return err
+	}
+
+	stats := service.NewLiveStats(a.InputConfig.ApproxTop)
+
+	mux := http.NewServeMux()
+	mux.Handle(metrics.Path, metrics.NewHandler(stats.Sample))
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	fmt.Printf("metrics: http://%s%s\n", listener.Addr(), metrics.Path)
+
+	err = a.AnalyticsService.Serve(a.InputConfig, stats)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if shutdownErr := server.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+
+	if httpErr := <-serveErr; err == nil && !errors.Is(httpErr, http.ErrServerClosed) {
+		err = httpErr
+	}
+
+	return err
+}
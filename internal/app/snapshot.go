@@ -0,0 +1,74 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/service"
+)
+
+// snapshot собирает статистику по логам и сохраняет её в InputConfig.Output без подсчёта топов.
+func (a *Application) snapshot() error {
+	accumulator, err := a.AnalyticsService.Aggregate(a.InputConfig)
+	if err != nil {
+		return err
+	}
+
+	file, err := a.FileWriter.CreateFile(a.InputConfig.Output)
+	if err != nil {
+		return err
+	}
+
+	snapshot := &service.Snapshot{From: a.InputConfig.From, To: a.InputConfig.To, Accumulator: accumulator}
+	if err := service.WriteSnapshot(file, snapshot); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("snapshot %s: requests=%d lines=%d files=%d\n", a.InputConfig.Output,
+		accumulator.AnalysisResult.TotalRequests, accumulator.AnalysisResult.TotalLines, len(accumulator.AnalysisResult.Filenames))
+
+	return nil
+}
+
+// merge объединяет снимки InputConfig.Snapshots и считает по ним топы и перцентили. Записи снимков
+// уже не отфильтровать, поэтому --ip выбирает только статистику клиента, остальные разделы — по всем.
+func (a *Application) merge() (*domain.AnalysisResult, error) {
+	snapshots := make([]*service.Snapshot, 0, len(a.InputConfig.Snapshots))
+
+	for _, path := range a.InputConfig.Snapshots {
+		snapshot, err := readSnapshot(path)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	merged, err := service.MergeSnapshots(snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ProcessResult(merged.Accumulator, a.InputConfig, merged.From, merged.To), nil
+}
+
+func readSnapshot(path string) (*service.Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	snapshot, err := service.ReadSnapshot(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,75 @@
+package app_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/app"
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/generator"
+	"github.com/4domm/ngxstat/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resultRecorder запоминает результат вместо записи отчёта в файл.
+type resultRecorder struct {
+	*generator.JSONReportGenerator
+	result *domain.AnalysisResult
+}
+
+func (r *resultRecorder) GenerateReport(result *domain.AnalysisResult) {
+	r.result = result
+}
+
+func writeSnapshot(t *testing.T, path string, entries ...*domain.LogData) {
+	t.Helper()
+
+	accumulator := service.NewAccumulator()
+	for _, entry := range entries {
+		accumulator.Add(entry)
+	}
+
+	file, err := os.Create(path)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	require.NoError(t, service.WriteSnapshot(file, &service.Snapshot{Accumulator: accumulator}))
+}
+
+func TestMerge_ClientIP(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.snapshot"), filepath.Join(dir, "second.snapshot")
+
+	// Клиент 10.0.0.9 не входит в топ по числу запросов, но --ip должен найти именно его.
+	writeSnapshot(t, first,
+		&domain.LogData{IPAddress: "10.0.0.1", Timestamp: start, Resource: "/a", StatusCode: "200"},
+		&domain.LogData{IPAddress: "10.0.0.1", Timestamp: start, Resource: "/a", StatusCode: "200"},
+		&domain.LogData{IPAddress: "10.0.0.9", Timestamp: start, Resource: "/login", StatusCode: "401", ResponseSize: 10},
+	)
+	writeSnapshot(t, second,
+		&domain.LogData{IPAddress: "10.0.0.2", Timestamp: start, Resource: "/b", StatusCode: "200"},
+		&domain.LogData{IPAddress: "10.0.0.2", Timestamp: start, Resource: "/b", StatusCode: "200"},
+		&domain.LogData{IPAddress: "10.0.0.3", Timestamp: start, Resource: "/c", StatusCode: "200"},
+		&domain.LogData{IPAddress: "10.0.0.3", Timestamp: start, Resource: "/c", StatusCode: "200"},
+		&domain.LogData{IPAddress: "10.0.0.9", Timestamp: start.Add(time.Hour), Resource: "/login", StatusCode: "200"},
+	)
+
+	recorder := &resultRecorder{JSONReportGenerator: generator.NewJSONReportGenerator(generator.FileWriter{})}
+	inputConfig := &domain.InputConfig{Command: domain.MERGE, Snapshots: []string{first, second}, ClientIP: "10.0.0.9"}
+	application := app.NewApplication(map[string]app.ReportGenerator{"": recorder}, inputConfig, nil, nil, generator.FileWriter{})
+
+	require.NoError(t, application.Run())
+	require.NotNil(t, recorder.result.Client)
+
+	client := recorder.result.Client
+	assert.Equal(t, "10.0.0.9", client.IP)
+	assert.Equal(t, int64(2), client.Requests)
+	assert.Equal(t, int64(1), client.ClientErrors)
+	assert.Equal(t, start.Add(time.Hour), client.LastRequest)
+	assert.Equal(t, map[string]int64{"/login": 2}, client.Resources)
+}
@@ -0,0 +1,31 @@
+package domain
+
+// AgentClasses — запросы по классам User-Agent: классу устройства (bot, mobile, tablet, desktop, other),
+// семейству браузера или бота и операционной системе. Классов немного, поэтому топ не урезается.
+type AgentClasses struct {
+	Devices  map[string]int64
+	Families map[string]int64
+	OSes     map[string]int64
+}
+
+func NewAgentClasses() AgentClasses {
+	return AgentClasses{
+		Devices:  make(map[string]int64),
+		Families: make(map[string]int64),
+		OSes:     make(map[string]int64),
+	}
+}
+
+// Add учитывает запрос клиента с классом device, семейством family и ОС os.
+func (ac AgentClasses) Add(device, family, os string) {
+	ac.Devices[device]++
+	ac.Families[family]++
+	ac.OSes[os]++
+}
+
+// Merge добавляет к счётчикам классов счётчики other.
+func (ac AgentClasses) Merge(other AgentClasses) {
+	mergeCounts(ac.Devices, other.Devices)
+	mergeCounts(ac.Families, other.Families)
+	mergeCounts(ac.OSes, other.OSes)
+}
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"net/netip"
+	"slices"
 	"sort"
 	"time"
 
@@ -15,6 +17,11 @@ type AnalysisResult struct {
 	MostRequestedResources   map[string]int64
 	MostFrequentStatusCodes  map[string]int64
 	MostFrequentReferrers    map[string]int64
+	RequestMethods           map[string]int64
+	MostRequestedEndpoints   map[string]int64
+	MostRequestedPaths       map[string]int64
+	MostFrequentQueryParams  map[string]int64
+	QueryParamValues         map[string]map[string]int64
 	TotalResponseSize        int64
 	TotalRequests            int64
 	TotalServerErrorsLogs    int64
@@ -23,6 +30,23 @@ type AnalysisResult struct {
 	From                     time.Time
 	To                       time.Time
 	Filenames                []string
+	RequestLatency           LatencyPercentiles
+	UpstreamLatency          LatencyPercentiles
+	ResourceLatencies        map[string]LatencyPercentiles
+	FileFormats              map[string]map[string]int64
+	TotalLines               int64
+	ParseErrors              ParseErrors
+	TimeSeries               TimeSeries
+	SizeDistribution         []SizeBucket
+	UniqueVisitors           UniqueVisitors
+	AgentClasses             AgentClasses
+	TopClients               []Client
+	// QueryParam — параметр --query-param, значения которого выводятся в отчёте; пустой — не выводятся.
+	QueryParam string
+	// Client — клиент отчёта --ip; nil, если отчёт не по одному адресу.
+	Client *Client
+	// ApproxTop — погрешности топов ресурсов и рефереров; nil, если топы точные.
+	ApproxTop *ApproxTop
 }
 
 func NewAnalysisResult() *AnalysisResult {
@@ -30,17 +54,130 @@ func NewAnalysisResult() *AnalysisResult {
 		MostRequestedResources:  make(map[string]int64),
 		MostFrequentStatusCodes: make(map[string]int64),
 		MostFrequentReferrers:   make(map[string]int64),
+		RequestMethods:          make(map[string]int64),
+		MostRequestedEndpoints:  make(map[string]int64),
+		MostRequestedPaths:      make(map[string]int64),
+		MostFrequentQueryParams: make(map[string]int64),
+		QueryParamValues:        make(map[string]map[string]int64),
+		FileFormats:             make(map[string]map[string]int64),
+		ParseErrors:             NewParseErrors(),
+		AgentClasses:            NewAgentClasses(),
 	}
 }
+
+// Merge добавляет к результату счётчики other. Вызывается до ProcessAll: после подсчёта топов
+// словари урезаны и сумма станет неверной.
+func (ar *AnalysisResult) Merge(other *AnalysisResult) {
+	ar.TotalResponseSize += other.TotalResponseSize
+	ar.TotalRequests += other.TotalRequests
+	ar.TotalServerErrorsLogs += other.TotalServerErrorsLogs
+	ar.TotalLines += other.TotalLines
+
+	mergeCounts(ar.MostRequestedResources, other.MostRequestedResources)
+	mergeCounts(ar.MostFrequentStatusCodes, other.MostFrequentStatusCodes)
+	mergeCounts(ar.MostFrequentReferrers, other.MostFrequentReferrers)
+	mergeCounts(ar.RequestMethods, other.RequestMethods)
+	mergeCounts(ar.MostRequestedEndpoints, other.MostRequestedEndpoints)
+	mergeCounts(ar.MostRequestedPaths, other.MostRequestedPaths)
+	mergeCounts(ar.MostFrequentQueryParams, other.MostFrequentQueryParams)
+
+	for name, values := range other.QueryParamValues {
+		mergeCounts(ar.queryValues(name), values)
+	}
+
+	ar.AgentClasses.Merge(other.AgentClasses)
+
+	for filename, formats := range other.FileFormats {
+		if _, ok := ar.FileFormats[filename]; !ok {
+			ar.FileFormats[filename] = make(map[string]int64, len(formats))
+		}
+
+		mergeCounts(ar.FileFormats[filename], formats)
+	}
+
+	ar.ParseErrors.Merge(other.ParseErrors)
+
+	for _, filename := range other.Filenames {
+		if !slices.Contains(ar.Filenames, filename) {
+			ar.Filenames = append(ar.Filenames, filename)
+		}
+	}
+}
+
+// AddQueryValue учитывает значение value параметра name.
+func (ar *AnalysisResult) AddQueryValue(name, value string, count int64) {
+	ar.queryValues(name)[value] += count
+}
+
+func (ar *AnalysisResult) queryValues(name string) map[string]int64 {
+	values, ok := ar.QueryParamValues[name]
+	if !ok {
+		values = make(map[string]int64)
+		ar.QueryParamValues[name] = values
+	}
+
+	return values
+}
+
+func mergeCounts(target, source map[string]int64) {
+	for key, value := range source {
+		target[key] += value
+	}
+}
+
 func (ar *AnalysisResult) ProcessAll(topN int, histogram *hdrhistogram.Histogram, from, to time.Time) {
 	ar.CountAverageResponseSize()
 	ar.GetTopRequestedResources(topN)
 	ar.getTopReferrers(topN)
+	ar.MostRequestedEndpoints = ar.getTopN(ar.MostRequestedEndpoints, topN)
+	ar.MostRequestedPaths = ar.getTopN(ar.MostRequestedPaths, topN)
+	ar.MostFrequentQueryParams = ar.getTopN(ar.MostFrequentQueryParams, topN)
+
+	for name, values := range ar.QueryParamValues {
+		ar.QueryParamValues[name] = ar.getTopN(values, topN)
+	}
+
 	ar.From = from
 	ar.To = to
 	ar.getTopFrequentStatusCodes(topN)
 	ar.getPercentile(histogram)
+	ar.SizeDistribution = NewSizeDistribution(histogram)
+}
+
+// ProcessLatencies считает перцентили задержек; по ресурсам — только для попавших в топ,
+// поэтому вызывается после ProcessAll.
+func (ar *AnalysisResult) ProcessLatencies(request, upstream *hdrhistogram.Histogram, resources map[string]*hdrhistogram.Histogram) {
+	ar.RequestLatency = NewLatencyPercentiles(request)
+	ar.UpstreamLatency = NewLatencyPercentiles(upstream)
+	ar.ResourceLatencies = make(map[string]LatencyPercentiles)
+
+	for resource := range ar.MostRequestedResources {
+		if histogram, ok := resources[resource]; ok {
+			ar.ResourceLatencies[resource] = NewLatencyPercentiles(histogram)
+		}
+	}
+}
+
+// AddFileFormat учитывает строку файла, разобранную в формате format.
+func (ar *AnalysisResult) AddFileFormat(filename, format string) {
+	formats, ok := ar.FileFormats[filename]
+	if !ok {
+		formats = make(map[string]int64)
+		ar.FileFormats[filename] = formats
+	}
+
+	formats[format]++
+}
+
+// ParseErrorRate возвращает долю прочитанных строк, которые не удалось разобрать.
+func (ar *AnalysisResult) ParseErrorRate() float64 {
+	if ar.TotalLines == 0 {
+		return 0
+	}
+
+	return float64(ar.ParseErrors.Total) / float64(ar.TotalLines)
 }
+
 func (ar *AnalysisResult) CountAverageResponseSize() {
 	if ar.TotalRequests > 0 {
 		ar.AverageResponseSize = float64(ar.TotalResponseSize) / float64(ar.TotalRequests)
@@ -83,6 +220,34 @@ func (ar *AnalysisResult) getTopN(countMap map[string]int64, topN int) map[strin
 	return topNMap
 }
 
+// SelectClient делает результат отчётом по клиенту ip (--ip) по статистике clients. Ключи clients —
+// адреса в том виде, в каком они записаны в логе, поэтому сравниваются разобранные адреса;
+// без запросов статистика нулевая.
+func (ar *AnalysisResult) SelectClient(ip string, clients map[string]*ClientStats, topN int) {
+	addr, _ := netip.ParseAddr(ip)
+	client := Client{IP: ip}
+
+	var stats *ClientStats
+
+	for key, clientStats := range clients {
+		if parsed, err := netip.ParseAddr(key); key != ip && (err != nil || parsed != addr) {
+			continue
+		}
+
+		if stats == nil {
+			stats = NewClientStats()
+		}
+
+		stats.Merge(clientStats)
+	}
+
+	if stats != nil {
+		client = NewTopClients(map[string]*ClientStats{ip: stats}, topN)[0]
+	}
+
+	ar.Client = &client
+}
+
 func (ar *AnalysisResult) getPercentile(histogram *hdrhistogram.Histogram) {
 	ar.Percentile95ResponseSize = histogram.ValueAtPercentile(PERCENTILE)
 }
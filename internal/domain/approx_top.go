@@ -0,0 +1,15 @@
+package domain
+
+// ApproxTop описывает топы, посчитанные приближённо (--approx-top): для каждого значения в топе
+// хранится погрешность — точное число вхождений лежит в [счётчик-погрешность, счётчик].
+type ApproxTop struct {
+	// Capacity — сколько значений отслеживается одновременно; погрешность не больше N/Capacity.
+	Capacity       int
+	ResourceErrors map[string]int64
+	ReferrerErrors map[string]int64
+	EndpointErrors map[string]int64
+	PathErrors     map[string]int64
+	// QueryParamErrors — погрешности имён параметров, QueryValueErrors — значений по имени параметра.
+	QueryParamErrors map[string]int64
+	QueryValueErrors map[string]map[string]int64
+}
@@ -0,0 +1,164 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// MaxClientResources — сколько ресурсов клиента считается по отдельности. Когда ресурсов больше, новый
+// ресурс забирает счётчик самого редкого (как в Space-Saving): счётчики становятся оценками сверху,
+// но ресурс, запрошенный клиентом чаще Requests/MaxClientResources раз, из словаря не выпадает.
+const MaxClientResources = 64
+
+// ClientStats — статистика запросов одного клиента (IP-адреса).
+type ClientStats struct {
+	Requests     int64
+	Bytes        int64
+	ClientErrors int64
+	ServerErrors int64
+	FirstRequest time.Time
+	LastRequest  time.Time
+	// Resources — запросы клиента по ресурсам, не больше MaxClientResources; в результате анализа — только топ.
+	Resources map[string]int64
+}
+
+func NewClientStats() *ClientStats {
+	return &ClientStats{Resources: make(map[string]int64)}
+}
+
+// Add учитывает запрос клиента.
+func (cs *ClientStats) Add(logData *LogData) {
+	cs.Requests++
+	cs.Bytes += logData.ResponseSize
+	cs.addResource(logData.Resource, 1)
+
+	if len(logData.StatusCode) == 3 {
+		switch logData.StatusCode[0] {
+		case '4':
+			cs.ClientErrors++
+		case '5':
+			cs.ServerErrors++
+		}
+	}
+
+	cs.updateSeen(logData.Timestamp, logData.Timestamp)
+}
+
+// Merge добавляет к статистике клиента счётчики other.
+func (cs *ClientStats) Merge(other *ClientStats) {
+	cs.Requests += other.Requests
+	cs.Bytes += other.Bytes
+	cs.ClientErrors += other.ClientErrors
+	cs.ServerErrors += other.ServerErrors
+
+	// Ресурсы добавляются от частых к редким, чтобы вытеснение не зависело от порядка обхода словаря.
+	for _, resource := range sortedKeys(other.Resources) {
+		cs.addResource(resource, other.Resources[resource])
+	}
+
+	cs.updateSeen(other.FirstRequest, other.LastRequest)
+}
+
+// addResource учитывает count запросов к resource, не заводя больше MaxClientResources счётчиков.
+func (cs *ClientStats) addResource(resource string, count int64) {
+	if _, ok := cs.Resources[resource]; ok || len(cs.Resources) < MaxClientResources {
+		cs.Resources[resource] += count
+		return
+	}
+
+	// Самый редкий ресурс, при равенстве — последний по значению, как в topCounts.
+	var (
+		rarest string
+		floor  int64 = -1
+	)
+
+	for key, value := range cs.Resources {
+		if floor < 0 || value < floor || value == floor && key > rarest {
+			rarest, floor = key, value
+		}
+	}
+
+	delete(cs.Resources, rarest)
+	cs.Resources[resource] = floor + count
+}
+
+func (cs *ClientStats) updateSeen(first, last time.Time) {
+	if !first.IsZero() && (cs.FirstRequest.IsZero() || first.Before(cs.FirstRequest)) {
+		cs.FirstRequest = first
+	}
+
+	if !last.IsZero() && last.After(cs.LastRequest) {
+		cs.LastRequest = last
+	}
+}
+
+// ErrorRate возвращает долю запросов клиента, завершившихся кодом 4xx или 5xx.
+func (cs *ClientStats) ErrorRate() float64 {
+	if cs.Requests == 0 {
+		return 0
+	}
+
+	return float64(cs.ClientErrors+cs.ServerErrors) / float64(cs.Requests)
+}
+
+// Client — клиент из топа по числу запросов.
+type Client struct {
+	IP string
+	ClientStats
+}
+
+// NewTopClients выбирает topN клиентов с наибольшим числом запросов, при равенстве — по адресу,
+// и оставляет у каждого topN самых запрашиваемых ресурсов.
+func NewTopClients(clients map[string]*ClientStats, topN int) []Client {
+	top := make([]Client, 0, len(clients))
+
+	for ip, stats := range clients {
+		top = append(top, Client{IP: ip, ClientStats: *stats})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+
+		return top[i].IP < top[j].IP
+	})
+
+	top = top[:min(topN, len(top))]
+
+	for i := range top {
+		top[i].Resources = topCounts(top[i].Resources, topN)
+	}
+
+	return top
+}
+
+// topCounts возвращает topN наибольших счётчиков, при равенстве — по значению.
+func topCounts(counts map[string]int64, topN int) map[string]int64 {
+	keys := sortedKeys(counts)
+
+	top := make(map[string]int64, min(topN, len(keys)))
+	for _, key := range keys[:min(topN, len(keys))] {
+		top[key] = counts[key]
+	}
+
+	return top
+}
+
+// sortedKeys возвращает ключи по убыванию счётчиков, при равенстве — по значению.
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
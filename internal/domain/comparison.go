@@ -0,0 +1,136 @@
+package domain
+
+import "sort"
+
+// Change — значение метрики в базовом и текущем периодах.
+type Change struct {
+	Base    float64
+	Current float64
+}
+
+func (c Change) Delta() float64 {
+	return c.Current - c.Base
+}
+
+// Percent возвращает изменение в долях базового значения (0.25 — рост на 25%);
+// false, если базовое значение нулевое и изменение в процентах не определено.
+func (c Change) Percent() (float64, bool) {
+	if c.Base == 0 {
+		return 0, false
+	}
+
+	return c.Delta() / c.Base, true
+}
+
+// EntryStatus — как значение топа изменилось между периодами.
+type EntryStatus string
+
+const (
+	// EntryKept — значение есть в топах обоих периодов.
+	EntryKept EntryStatus = "kept"
+	// EntryNew — значение появилось в топе текущего периода.
+	EntryNew EntryStatus = "new"
+	// EntryGone — значение выпало из топа.
+	EntryGone EntryStatus = "gone"
+)
+
+// TopCounts — счётчики периода, из которых выбираются топы, до урезания до topN: по ним сравниваются
+// и значения, которые вошли в топ только одного из периодов.
+type TopCounts struct {
+	StatusCodes map[string]int64
+	Resources   map[string]int64
+	Referrers   map[string]int64
+}
+
+// CountChange — значение из топа хотя бы одного из периодов. Base и Current — числа вхождений
+// в периодах, в том числе вне топа (ноль — значение в периоде не встречалось или, с --approx-top,
+// не отслеживалось).
+type CountChange struct {
+	Value   string
+	Base    int64
+	Current int64
+	Status  EntryStatus
+}
+
+func (c CountChange) Change() Change {
+	return Change{Base: float64(c.Base), Current: float64(c.Current)}
+}
+
+// Comparison — сравнение текущего периода с базовым для отчёта ngxstat diff.
+type Comparison struct {
+	Base    *AnalysisResult
+	Current *AnalysisResult
+
+	TotalRequests            Change
+	ServerErrors             Change
+	AverageResponseSize      Change
+	Percentile95ResponseSize Change
+	StatusCodes              []CountChange
+	Resources                []CountChange
+	Referrers                []CountChange
+}
+
+// NewComparison сравнивает результаты периодов; какие значения попадают в сравнение, решают топы
+// результатов, а числа вхождений берутся из baseCounts и currentCounts.
+func NewComparison(base, current *AnalysisResult, baseCounts, currentCounts TopCounts) *Comparison {
+	return &Comparison{
+		Base:    base,
+		Current: current,
+		TotalRequests: Change{
+			Base: float64(base.TotalRequests), Current: float64(current.TotalRequests),
+		},
+		ServerErrors: Change{
+			Base: float64(base.TotalServerErrorsLogs), Current: float64(current.TotalServerErrorsLogs),
+		},
+		AverageResponseSize: Change{Base: base.AverageResponseSize, Current: current.AverageResponseSize},
+		Percentile95ResponseSize: Change{
+			Base: float64(base.Percentile95ResponseSize), Current: float64(current.Percentile95ResponseSize),
+		},
+		StatusCodes: compareCounts(
+			base.MostFrequentStatusCodes, current.MostFrequentStatusCodes, baseCounts.StatusCodes, currentCounts.StatusCodes,
+		),
+		Resources: compareCounts(
+			base.MostRequestedResources, current.MostRequestedResources, baseCounts.Resources, currentCounts.Resources,
+		),
+		Referrers: compareCounts(
+			base.MostFrequentReferrers, current.MostFrequentReferrers, baseCounts.Referrers, currentCounts.Referrers,
+		),
+	}
+}
+
+// compareCounts объединяет топы периодов baseTop и currentTop с числами вхождений из полных счётчиков
+// baseCounts и currentCounts: сначала значения по убыванию числа вхождений в текущем периоде, затем —
+// в базовом.
+func compareCounts(baseTop, currentTop, baseCounts, currentCounts map[string]int64) []CountChange {
+	changes := make([]CountChange, 0, len(baseTop)+len(currentTop))
+
+	for value := range currentTop {
+		change := CountChange{Value: value, Base: baseCounts[value], Current: currentCounts[value], Status: EntryNew}
+
+		if _, ok := baseTop[value]; ok {
+			change.Status = EntryKept
+		}
+
+		changes = append(changes, change)
+	}
+
+	for value := range baseTop {
+		if _, ok := currentTop[value]; !ok {
+			changes = append(changes, CountChange{Value: value, Base: baseCounts[value], Current: currentCounts[value], Status: EntryGone})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Current != changes[j].Current {
+			return changes[i].Current > changes[j].Current
+		}
+
+		if changes[i].Base != changes[j].Base {
+			return changes[i].Base > changes[j].Base
+		}
+
+		return changes[i].Value < changes[j].Value
+	})
+
+	return changes
+}
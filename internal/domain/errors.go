@@ -3,17 +3,25 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var ErrDownload = errors.New("failed to download file")
 var ErrFinding = errors.New("no files")
+var ErrFollowURL = errors.New("режим --follow поддерживает только локальные файлы")
+var ErrSnapshotFormat = errors.New("файл не является снимком ngxstat или повреждён")
+var ErrServeURL = errors.New("команда serve следит только за локальными файлами")
+var ErrNoSnapshots = errors.New("укажите хотя бы один файл снимка: ngxstat merge snap1 snap2 ...")
+var ErrNoBaseline = errors.New("укажите базовый период для сравнения: --base-path, --base-from или --base-to")
+var ErrReadLog = errors.New("не удалось дочитать лог")
+var ErrMixedSources = errors.New("--path и --base-path должны быть оба локальными файлами или оба URL")
 
 type InvalidOutputFormatError struct {
 	Format string
 }
 
 func (e *InvalidOutputFormatError) Error() string {
-	return fmt.Sprintf("Неверный формат вывода: %s. Используйте %s или %s.", e.Format, MARKDOWN, ADOC)
+	return fmt.Sprintf("Неверный формат вывода: %s. Используйте %s, %s, %s или %s.", e.Format, MARKDOWN, ADOC, JSON, HTML)
 }
 
 type MissingFilterValueError struct {
@@ -29,3 +37,135 @@ type InvalidFilterCombinationError struct {
 func (e *InvalidFilterCombinationError) Error() string {
 	return "Для фильтрации необходимо указать оба параметра: --filter-field и --filter-value."
 }
+
+// InvalidFilterError — ошибка разбора выражения --filter. Position — смещение ошибки в байтах,
+// в сообщении позиция считается с единицы.
+type InvalidFilterError struct {
+	Expression string
+	Position   int
+	Reason     string
+}
+
+func (e *InvalidFilterError) Error() string {
+	return fmt.Sprintf("Неверный фильтр %q: %s (позиция %d).", e.Expression, e.Reason, e.Position+1)
+}
+
+type InvalidFilterValueError struct {
+	Field  FilterField
+	Value  string
+	Reason string
+}
+
+func (e *InvalidFilterValueError) Error() string {
+	return fmt.Sprintf("Неверное значение фильтра %s=%q: %s.", e.Field, e.Value, e.Reason)
+}
+
+type InvalidClientIPError struct {
+	IP string
+}
+
+func (e *InvalidClientIPError) Error() string {
+	return fmt.Sprintf("Неверный IP-адрес для отчёта --ip: %q.", e.IP)
+}
+
+type InvalidLogFormatError struct {
+	Format string
+	Reason string
+}
+
+func (e *InvalidLogFormatError) Error() string {
+	return fmt.Sprintf("Неверный log_format %q: %s.", e.Format, e.Reason)
+}
+
+type InvalidFieldMappingError struct {
+	Mapping string
+}
+
+func (e *InvalidFieldMappingError) Error() string {
+	return fmt.Sprintf("Неверное сопоставление полей %q, ожидается поле=ключ.", e.Mapping)
+}
+
+type InvalidParserError struct {
+	Parser string
+}
+
+func (e *InvalidParserError) Error() string {
+	return fmt.Sprintf("Неизвестный парсер: %s. Используйте %s, %s или %s.", e.Parser, AUTO, NGINX, JSON)
+}
+
+type ErrorRateExceededError struct {
+	Rate    float64
+	MaxRate float64
+}
+
+func (e *ErrorRateExceededError) Error() string {
+	return fmt.Sprintf("Доля неразобранных строк %.2f%% превышает допустимую %.2f%%.", e.Rate*100, e.MaxRate*100)
+}
+
+type InvalidErrorRateError struct {
+	Rate float64
+}
+
+func (e *InvalidErrorRateError) Error() string {
+	return fmt.Sprintf("Неверная допустимая доля ошибок: %v, ожидается число от 0 до 1.", e.Rate)
+}
+
+type InvalidBucketError struct {
+	Bucket string
+}
+
+func (e *InvalidBucketError) Error() string {
+	return fmt.Sprintf("Неверная ширина интервала: %s. Используйте 1m, 5m, 1h или 1d.", e.Bucket)
+}
+
+type InvalidFollowIntervalError struct {
+	Window  time.Duration
+	Refresh time.Duration
+}
+
+func (e *InvalidFollowIntervalError) Error() string {
+	return fmt.Sprintf("Неверные интервалы режима --follow: окно %v, обновление %v; оба должны быть положительными.", e.Window, e.Refresh)
+}
+
+type InvalidApproxTopError struct {
+	Capacity int
+}
+
+func (e *InvalidApproxTopError) Error() string {
+	return fmt.Sprintf("Неверная ёмкость --approx-top: %d, ожидается неотрицательное число (0 — точные топы).", e.Capacity)
+}
+
+type InvalidWorkersError struct {
+	Workers int
+}
+
+func (e *InvalidWorkersError) Error() string {
+	return fmt.Sprintf("Неверное число обработчиков --workers: %d, ожидается неотрицательное число (0 — по числу ядер).", e.Workers)
+}
+
+type SnapshotVersionError struct {
+	Version   int
+	Supported int
+}
+
+func (e *SnapshotVersionError) Error() string {
+	return fmt.Sprintf("Неподдерживаемая версия снимка: %d, поддерживается %d.", e.Version, e.Supported)
+}
+
+type SnapshotMismatchError struct {
+	ApproxTop      int
+	OtherApproxTop int
+}
+
+func (e *SnapshotMismatchError) Error() string {
+	return fmt.Sprintf("Снимки сняты с разной ёмкостью --approx-top (%d и %d) и не объединяются.", e.ApproxTop, e.OtherApproxTop)
+}
+
+type InvalidRewriteRuleError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *InvalidRewriteRuleError) Error() string {
+	return fmt.Sprintf("Неверное правило --rewrite %q: %s.", e.Rule, e.Reason)
+}
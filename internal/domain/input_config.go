@@ -14,17 +14,102 @@ const (
 	REFERER    FilterField = "referer"
 	REMOTEUSER FilterField = "remote_user"
 	SIZE       FilterField = "size"
-	ADOC                   = "adoc"
-	MARKDOWN               = "markdown"
+	IP         FilterField = "ip"
+	FILE       FilterField = "file"
+	FORMAT     FilterField = "format"
+	REQUEST    FilterField = "request_time"
+	UPSTREAM   FilterField = "upstream_time"
+	// AGENTCLASS, AGENTFAMILY и AGENTOS — класс устройства, семейство и ОС по классификации User-Agent.
+	AGENTCLASS  FilterField = "agent_class"
+	AGENTFAMILY FilterField = "agent_family"
+	AGENTOS     FilterField = "agent_os"
+	ENDPOINT    FilterField = "endpoint"
+	PATH        FilterField = "path"
+	ADOC                    = "adoc"
+	MARKDOWN                = "markdown"
+	HTML                    = "html"
+	NGINX                   = "nginx"
+	JSON                    = "json"
+	AUTO                    = "auto"
+	SNAPSHOT                = "snapshot"
+	MERGE                   = "merge"
+	DIFF                    = "diff"
+	SERVE                   = "serve"
+	API                     = "api"
 )
 
-var FilterFields = []FilterField{AGENT, METHOD, STATUS, RESOURCE, REFERER, REMOTEUSER, SIZE, ""}
+// Commands — команды, которые можно указать первым аргументом; без команды строится отчёт по логам.
+var Commands = []string{SNAPSHOT, MERGE, DIFF, SERVE, API}
+
+// FilterFields — поля, доступные в выражениях --filter и во флаге --filter-field.
+var FilterFields = []FilterField{
+	AGENT, METHOD, STATUS, RESOURCE, REFERER, REMOTEUSER, SIZE, IP, FILE, FORMAT, REQUEST, UPSTREAM,
+	AGENTCLASS, AGENTFAMILY, AGENTOS, ENDPOINT, PATH,
+}
+
+// FieldFilter — условие на одно поле (--filter-field/--filter-value). Значение типизировано полем:
+// 5xx, 500-599 или >=400 для чисел, подсеть для ip, шаблон * и ? или ~regex для строк.
+// Условия на одно поле объединяются через ИЛИ, на разные поля — через И.
+type FieldFilter struct {
+	Field FilterField
+	Value string
+}
 
 type InputConfig struct {
+	Command      string
 	Path         string
 	From         time.Time
 	To           time.Time
 	OutputFormat string
-	FilterField  FilterField
-	FilterValue  string
+	// Filters — выражения --filter; запись должна подойти под все.
+	Filters []string
+	// FieldFilters — пары --filter-field/--filter-value в порядке флагов.
+	FieldFilters   []FieldFilter
+	LogFormat      string
+	Parser         string
+	JSONFields     string
+	JSONTimeLayout string
+	DetectLines    int
+	MaxErrorRate   float64
+	Follow         bool
+	Window         time.Duration
+	Refresh        time.Duration
+	Bucket         time.Duration
+	ApproxTop      int
+	Workers        int
+	// Rewrites — правила --rewrite "выражение=>замена" для шаблонов путей.
+	Rewrites []string
+	// QueryParam — параметр query string, значения которого выводятся в отчёте (--query-param).
+	QueryParam string
+	// ClientIP — адрес, по которому строится отчёт --ip; пустой — отчёт по всем клиентам.
+	ClientIP string
+	// Output — файл снимка для команды snapshot.
+	Output string
+	// Snapshots — файлы снимков для команды merge.
+	Snapshots []string
+	// Listen — адрес HTTP-сервера команд serve и api.
+	Listen string
+	// BasePath, BaseFrom и BaseTo задают базовый период команды diff; пустые берутся из Path, From и To.
+	BasePath string
+	BaseFrom time.Time
+	BaseTo   time.Time
+}
+
+// BaseConfig возвращает настройки анализа базового периода команды diff.
+func (c *InputConfig) BaseConfig() *InputConfig {
+	base := *c
+
+	if c.BasePath != "" {
+		base.Path = c.BasePath
+	}
+
+	if !c.BaseFrom.IsZero() {
+		base.From = c.BaseFrom
+	}
+
+	if !c.BaseTo.IsZero() {
+		base.To = c.BaseTo
+	}
+
+	return &base
 }
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// LatencyPercentiles хранит распределение задержек, записанных в гистограмму в микросекундах.
+type LatencyPercentiles struct {
+	Count int64
+	P50   time.Duration
+	P90   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func NewLatencyPercentiles(histogram *hdrhistogram.Histogram) LatencyPercentiles {
+	if histogram == nil || histogram.TotalCount() == 0 {
+		return LatencyPercentiles{}
+	}
+
+	return LatencyPercentiles{
+		Count: histogram.TotalCount(),
+		P50:   microseconds(histogram.ValueAtPercentile(50)),
+		P90:   microseconds(histogram.ValueAtPercentile(90)),
+		P95:   microseconds(histogram.ValueAtPercentile(95)),
+		P99:   microseconds(histogram.ValueAtPercentile(99)),
+		Max:   microseconds(histogram.Max()),
+	}
+}
+
+func microseconds(value int64) time.Duration {
+	return time.Duration(value) * time.Microsecond
+}
@@ -1,9 +1,13 @@
 package domain
 
-import "time"
+import (
+	"net/url"
+	"time"
+)
 
 type LogData struct {
 	Filename     string
+	Format       string
 	Timestamp    time.Time
 	IPAddress    string
 	RemoteUser   string
@@ -13,4 +17,15 @@ type LogData struct {
 	ResponseSize int64
 	Referer      string
 	UserAgent    string
+	// Path и Query — путь ресурса и декодированные параметры query string, Endpoint — шаблон пути
+	// (см. urlpath.Normalizer); заполняются после разбора строки.
+	Path     string
+	Query    url.Values
+	Endpoint string
+	Extras   map[string]string
+
+	RequestTime          time.Duration
+	UpstreamResponseTime time.Duration
+	HasRequestTime       bool
+	HasUpstreamTime      bool
 }
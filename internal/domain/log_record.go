@@ -0,0 +1,16 @@
+package domain
+
+// LogRecord — строка лога вместе с местом, откуда она прочитана.
+type LogRecord struct {
+	// Source — имя файла или URL-источника, под которым он попадает в отчёт.
+	Source string
+	// Offset — смещение начала строки в байтах от начала распакованного потока.
+	Offset int64
+	// Line — номер строки в источнике, начиная с 1.
+	Line int64
+	// Raw — содержимое строки без завершающего перевода строки.
+	Raw []byte
+	// Err — ошибка чтения источника (повреждённый архив, обрыв потока). У такой записи нет Raw,
+	// она учитывается как ошибка разбора.
+	Err error
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "github.com/HdrHistogram/hdrhistogram-go"
+
+const (
+	// OtherEndpoint — метка, под которой считаются шаблоны путей сверх лимита собственных счётчиков.
+	// Шаблоны начинаются с «/», поэтому с настоящим шаблоном она не совпадает.
+	OtherEndpoint = "other"
+	// OtherMethod — метка нестандартных HTTP-методов: мусор сканеров вроде \x16\x03\x01 и выдуманные
+	// глаголы не должны заводить каждый свою серию метрик.
+	OtherMethod = "other"
+	// OtherStatus — метка кодов ответа, которые не являются трёхзначным числом 1xx–5xx.
+	OtherStatus = "other"
+)
+
+// MetricsSample — счётчики команды serve с момента запуска на момент запроса метрик. Словари
+// и гистограммы — копии, их можно читать, пока статистика продолжает копиться.
+type MetricsSample struct {
+	TotalLines        int64
+	ParseErrors       int64
+	TotalRequests     int64
+	TotalResponseSize int64
+	// StatusCodes и Methods — запросы по коду ответа и методу; значения вне стандартного набора
+	// считаются под OtherStatus и OtherMethod.
+	StatusCodes map[string]int64
+	Methods     map[string]int64
+	// Endpoints — запросы по шаблонам путей. Набор шаблонов только растёт до лимита, остальные шаблоны
+	// считаются под OtherEndpoint, поэтому каждый счётчик монотонен.
+	Endpoints map[string]int64
+	// ResponseSizes — размеры ответов в байтах, RequestLatency и UpstreamLatency — задержки в микросекундах.
+	ResponseSizes   *hdrhistogram.Histogram
+	RequestLatency  *hdrhistogram.Histogram
+	UpstreamLatency *hdrhistogram.Histogram
+}
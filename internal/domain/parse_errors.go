@@ -0,0 +1,71 @@
+package domain
+
+import "strings"
+
+const (
+	MaxParseErrorSamples    = 10
+	MaxParseErrorSampleSize = 200
+)
+
+type ParseErrorSample struct {
+	Filename   string
+	LineNumber int64
+	Line       string
+	Kind       string
+}
+
+// ParseErrors учитывает строки, которые не удалось разобрать, и хранит ограниченную выборку таких строк.
+type ParseErrors struct {
+	Total   int64
+	ByFile  map[string]int64
+	ByKind  map[string]int64
+	Samples []ParseErrorSample
+}
+
+func NewParseErrors() ParseErrors {
+	return ParseErrors{
+		ByFile: make(map[string]int64),
+		ByKind: make(map[string]int64),
+	}
+}
+
+func (pe *ParseErrors) Add(record LogRecord, kind string) {
+	pe.Total++
+	pe.ByFile[record.Source]++
+	pe.ByKind[kind]++
+
+	if len(pe.Samples) >= MaxParseErrorSamples {
+		return
+	}
+
+	raw := record.Raw
+	if record.Err != nil {
+		raw = []byte(record.Err.Error())
+	}
+
+	truncated := len(raw) > MaxParseErrorSampleSize
+	if truncated {
+		raw = raw[:MaxParseErrorSampleSize]
+	}
+
+	line := strings.TrimRight(string(raw), "\r")
+	if truncated {
+		line += "..."
+	}
+
+	pe.Samples = append(pe.Samples, ParseErrorSample{Filename: record.Source, LineNumber: record.Line, Line: line, Kind: kind})
+}
+
+func (pe *ParseErrors) Merge(other ParseErrors) {
+	pe.Total += other.Total
+	mergeCounts(pe.ByFile, other.ByFile)
+	mergeCounts(pe.ByKind, other.ByKind)
+
+	for _, sample := range other.Samples {
+		if len(pe.Samples) >= MaxParseErrorSamples {
+			break
+		}
+
+		pe.Samples = append(pe.Samples, sample)
+	}
+}
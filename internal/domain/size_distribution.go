@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"math/bits"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// SizeBucket — число ответов с размером от From до To байт включительно.
+type SizeBucket struct {
+	From  int64
+	To    int64
+	Count int64
+}
+
+// NewSizeDistribution группирует гистограмму размеров ответа по степеням двойки: [0], [1], [2, 3],
+// [4, 7] и так далее. Пустые интервалы по краям отбрасываются, внутри диапазона остаются.
+func NewSizeDistribution(histogram *hdrhistogram.Histogram) []SizeBucket {
+	var counts [65]int64
+
+	low, high := len(counts), -1
+
+	for _, bar := range histogram.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+
+		// Границы интервалов гистограммы — степени двойки, поэтому интервал целиком попадает в одну группу.
+		index := bits.Len64(uint64(bar.From))
+		counts[index] += bar.Count
+		low, high = min(low, index), max(high, index)
+	}
+
+	distribution := make([]SizeBucket, 0, max(high-low+1, 0))
+
+	for index := low; index <= high; index++ {
+		bucket := SizeBucket{Count: counts[index]}
+		if index > 0 {
+			bucket.From, bucket.To = 1<<(index-1), 1<<index-1
+		}
+
+		distribution = append(distribution, bucket)
+	}
+
+	return distribution
+}
@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// TimeSeriesBaseWidth — ширина интервалов, в которых накапливается ряд до выбора итоговой ширины.
+	TimeSeriesBaseWidth = time.Minute
+	// MaxTimeSeriesBuckets — сколько интервалов допускается в ряду при автоматическом выборе ширины.
+	MaxTimeSeriesBuckets = 120
+	// MaxExplicitTimeSeriesBuckets — сколько интервалов допускается в ряду с шириной из --bucket
+	// (сутки по минутам или пять лет по дням); ширина, дающая больше интервалов, укрупняется.
+	MaxExplicitTimeSeriesBuckets = 2000
+)
+
+// BucketWidths — ширины интервалов, которые можно задать флагом --bucket.
+var BucketWidths = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// timeSeriesWidths — ширины интервалов для автоматического выбора, по возрастанию.
+var timeSeriesWidths = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// TimeBucket — статистика запросов за интервал [Start, Start+ширина ряда). Коды ответа вне
+// 2xx–5xx (1xx и нечисловые) входят только в Requests.
+type TimeBucket struct {
+	Start     time.Time
+	Requests  int64
+	Bytes     int64
+	Status2xx int64
+	Status3xx int64
+	Status4xx int64
+	Status5xx int64
+}
+
+// Add учитывает запрос с кодом ответа statusCode и телом size байт.
+func (tb *TimeBucket) Add(statusCode string, size int64) {
+	tb.Requests++
+	tb.Bytes += size
+
+	if len(statusCode) != 3 {
+		return
+	}
+
+	switch statusCode[0] {
+	case '2':
+		tb.Status2xx++
+	case '3':
+		tb.Status3xx++
+	case '4':
+		tb.Status4xx++
+	case '5':
+		tb.Status5xx++
+	}
+}
+
+// Merge добавляет к интервалу счётчики other.
+func (tb *TimeBucket) Merge(other *TimeBucket) {
+	tb.Requests += other.Requests
+	tb.Bytes += other.Bytes
+	tb.Status2xx += other.Status2xx
+	tb.Status3xx += other.Status3xx
+	tb.Status4xx += other.Status4xx
+	tb.Status5xx += other.Status5xx
+}
+
+// TimeSeries — запросы по интервалам одинаковой ширины без пропусков, по возрастанию Start.
+type TimeSeries struct {
+	Width   time.Duration
+	Buckets []TimeBucket
+	// Explicit — ширина задана флагом --bucket, а не выбрана автоматически.
+	Explicit bool
+}
+
+// NewTimeSeries собирает ряд из интервалов шириной TimeSeriesBaseWidth, ключ — начало интервала
+// в секундах Unix, и укрупняет их до ширины width (кратной минуте). При нулевой width выбирается
+// наименьшая из timeSeriesWidths, при которой интервалов не больше MaxTimeSeriesBuckets. Если width
+// даёт больше MaxExplicitTimeSeriesBuckets интервалов, берётся наименьшая из более широких timeSeriesWidths,
+// при которой их не больше. Пустые интервалы внутри диапазона заполняются нулями.
+func NewTimeSeries(buckets map[int64]*TimeBucket, width time.Duration) TimeSeries {
+	if len(buckets) == 0 {
+		return TimeSeries{Width: width, Explicit: width > 0}
+	}
+
+	keys := make([]int64, 0, len(buckets))
+	for key := range buckets {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	first, last := time.Unix(keys[0], 0).UTC(), time.Unix(keys[len(keys)-1], 0).UTC()
+	series := TimeSeries{Width: width, Explicit: width > 0}
+
+	if series.Explicit {
+		series.Width = chooseTimeSeriesWidth(last.Sub(first), width, MaxExplicitTimeSeriesBuckets)
+	} else {
+		series.Width = chooseTimeSeriesWidth(last.Sub(first), 0, MaxTimeSeriesBuckets)
+	}
+
+	width = series.Width
+	start := first.Truncate(width)
+	series.Buckets = make([]TimeBucket, int(last.Sub(start)/width)+1)
+
+	for i := range series.Buckets {
+		series.Buckets[i].Start = start.Add(time.Duration(i) * width)
+	}
+
+	for _, key := range keys {
+		index := int(time.Unix(key, 0).Sub(start) / width)
+		series.Buckets[index].Merge(buckets[key])
+	}
+
+	return series
+}
+
+// chooseTimeSeriesWidth возвращает minWidth или наименьшую из более широких timeSeriesWidths,
+// при которой span делится меньше чем на limit интервалов.
+func chooseTimeSeriesWidth(span, minWidth time.Duration, limit int) time.Duration {
+	if minWidth > 0 && span/minWidth < time.Duration(limit) {
+		return minWidth
+	}
+
+	for _, width := range timeSeriesWidths {
+		if width > minWidth && span/width < time.Duration(limit) {
+			return width
+		}
+	}
+
+	return max(minWidth, timeSeriesWidths[len(timeSeriesWidths)-1])
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "github.com/4domm/ngxstat/internal/sketch"
+
+// Cardinality — оценка числа различных значений и её стандартная относительная ошибка.
+type Cardinality struct {
+	Estimate      int64
+	RelativeError float64
+}
+
+func NewCardinality(hll *sketch.HyperLogLog) Cardinality {
+	if hll == nil {
+		return Cardinality{}
+	}
+
+	return Cardinality{Estimate: int64(hll.Count()), RelativeError: hll.RelativeError()}
+}
+
+// UniqueVisitors — оценки числа различных клиентов: по IP-адресу, по паре IP и User-Agent
+// и по имени пользователя ($remote_user).
+type UniqueVisitors struct {
+	IPs          Cardinality
+	IPUserAgents Cardinality
+	RemoteUsers  Cardinality
+}
@@ -0,0 +1,512 @@
+// Package filter компилирует выражения фильтра --filter в предикаты над domain.LogData.
+//
+// Грамматика:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = field ("==" | "!=" | "<" | "<=" | ">" | ">=") value
+//	           | field ("~" | "!~") string
+//	           | field "in" (member | "(" member { "," member } ")")
+//	member     = value [ ".." value ]
+//
+// Строки пишутся в двойных кавычках и сравниваются без учёта регистра; ~ — регулярное выражение RE2.
+// Числа, длительности, IP-адреса и подсети можно писать без кавычек: status >= 500,
+// request_time > 300ms, ip in 10.0.0.0/8. Сравнение с числовым полем, которого нет в записи
+// (например, request_time в формате без $request_time), ложно.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// Predicate сообщает, проходит ли запись фильтр.
+type Predicate func(*domain.LogData) bool
+
+// syntaxError — ошибка разбора без текста выражения; Compile превращает её в domain.InvalidFilterError.
+type syntaxError struct {
+	pos    int
+	reason string
+}
+
+func (e *syntaxError) Error() string {
+	return e.reason
+}
+
+// Compile разбирает выражение один раз и возвращает предикат; для пустого выражения — nil.
+func Compile(expression string) (Predicate, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+
+	predicate, err := compile(expression)
+	if err != nil {
+		var syntax *syntaxError
+		if errors.As(err, &syntax) {
+			return nil, &domain.InvalidFilterError{Expression: expression, Position: syntax.pos, Reason: syntax.reason}
+		}
+
+		return nil, err
+	}
+
+	return predicate, nil
+}
+
+// ForConfig компилирует фильтры запуска: запись должна подойти под все выражения --filter и под
+// условия --filter-field/--filter-value. Условия на одно поле объединяются через ИЛИ, на разные — через И:
+// --filter-field status --filter-value 5xx --filter-field status --filter-value 404 --filter-field method --filter-value POST
+// оставит POST-запросы с кодом 5xx или 404. Отчёт --ip оставляет только запросы этого адреса.
+// Без фильтров возвращается nil.
+func ForConfig(inputConfig *domain.InputConfig) (Predicate, error) {
+	var all []Predicate
+
+	for _, expression := range inputConfig.Filters {
+		predicate, err := Compile(expression)
+		if err != nil {
+			return nil, err
+		}
+
+		if predicate != nil {
+			all = append(all, predicate)
+		}
+	}
+
+	var order []domain.FilterField
+
+	byField := make(map[domain.FilterField][]Predicate)
+
+	for _, fieldFilter := range inputConfig.FieldFilters {
+		predicate, err := FieldPredicate(fieldFilter)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := byField[fieldFilter.Field]; !ok {
+			order = append(order, fieldFilter.Field)
+		}
+
+		byField[fieldFilter.Field] = append(byField[fieldFilter.Field], predicate)
+	}
+
+	for _, field := range order {
+		all = append(all, anyOf(byField[field]))
+	}
+
+	if inputConfig.ClientIP != "" {
+		predicate, err := FieldPredicate(domain.FieldFilter{Field: domain.IP, Value: inputConfig.ClientIP})
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, predicate)
+	}
+
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	return allOf(all), nil
+}
+
+func allOf(predicates []Predicate) Predicate {
+	if len(predicates) == 1 {
+		return predicates[0]
+	}
+
+	return func(log *domain.LogData) bool {
+		for _, predicate := range predicates {
+			if !predicate(log) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func anyOf(predicates []Predicate) Predicate {
+	if len(predicates) == 1 {
+		return predicates[0]
+	}
+
+	return func(log *domain.LogData) bool {
+		for _, predicate := range predicates {
+			if predicate(log) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func compile(expression string) (Predicate, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	predicate, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected("ожидается && или ||")
+	}
+
+	return predicate, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// accept пропускает оператор operator, если он следующий.
+func (p *parser) accept(operator string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == operator {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// take возвращает следующий токен, если он одного из видов kinds, и пропускает его.
+func (p *parser) take(kinds ...tokenKind) (token, bool) {
+	t := p.peek()
+	for _, kind := range kinds {
+		if t.kind == kind {
+			return p.next(), true
+		}
+	}
+
+	return t, false
+}
+
+func (p *parser) expect(operator string) error {
+	if !p.accept(operator) {
+		return p.unexpected("ожидается " + operator)
+	}
+
+	return nil
+}
+
+func (p *parser) unexpected(reason string) error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return &syntaxError{pos: t.pos, reason: reason + ", а выражение закончилось"}
+	}
+
+	return &syntaxError{pos: t.pos, reason: fmt.Sprintf("%s, получено %q", reason, t.text)}
+}
+
+func (p *parser) or() (Predicate, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		first := left
+		left = func(log *domain.LogData) bool { return first(log) || right(log) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (Predicate, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		first := left
+		left = func(log *domain.LogData) bool { return first(log) && right(log) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) unary() (Predicate, error) {
+	if p.accept("!") {
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(log *domain.LogData) bool { return !inner(log) }, nil
+	}
+
+	if p.accept("(") {
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		return inner, p.expect(")")
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (Predicate, error) {
+	name := p.peek()
+	if name.kind != tokenIdent {
+		return nil, p.unexpected("ожидается имя поля")
+	}
+
+	f, ok := fields[domain.FilterField(name.text)]
+	if !ok {
+		return nil, &syntaxError{pos: name.pos, reason: fmt.Sprintf("неизвестное поле %q, доступны: %v", name.text, domain.FilterFields)}
+	}
+
+	p.next()
+
+	if t := p.peek(); t.kind == tokenIdent && t.text == "in" {
+		p.next()
+		return p.in(f)
+	}
+
+	operator := p.peek()
+	if operator.kind != tokenOperator {
+		return nil, p.unexpected("ожидается оператор сравнения")
+	}
+
+	switch operator.text {
+	case "~", "!~":
+		p.next()
+		return p.match(f, operator.text == "!~")
+	case "==", "!=":
+		p.next()
+
+		equal, err := p.member(f, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if operator.text == "==" {
+			return equal, nil
+		}
+
+		return notEqual(f, equal), nil
+	case "<", "<=", ">", ">=":
+		p.next()
+		return p.order(f, operator)
+	}
+
+	return nil, p.unexpected("ожидается оператор сравнения")
+}
+
+// notEqual отрицает равенство; для числового поля, которого нет в записи, результат ложен, как и у ==.
+func notEqual(f field, equal Predicate) Predicate {
+	if f.kind != kindNumber {
+		return func(log *domain.LogData) bool { return !equal(log) }
+	}
+
+	return func(log *domain.LogData) bool {
+		_, ok := f.number(log)
+		return ok && !equal(log)
+	}
+}
+
+func (p *parser) match(f field, negate bool) (Predicate, error) {
+	if f.text == nil {
+		return nil, &syntaxError{pos: p.tokens[p.pos-1].pos, reason: "поле нельзя сравнивать с регулярным выражением"}
+	}
+
+	t, ok := p.take(tokenString)
+	if !ok {
+		return nil, p.unexpected("ожидается регулярное выражение в кавычках")
+	}
+
+	re, err := regexp.Compile(t.text)
+	if err != nil {
+		return nil, &syntaxError{pos: t.pos, reason: "неверное регулярное выражение: " + err.Error()}
+	}
+
+	return func(log *domain.LogData) bool { return re.MatchString(f.text(log)) != negate }, nil
+}
+
+func (p *parser) order(f field, operator token) (Predicate, error) {
+	if f.kind != kindNumber {
+		return nil, &syntaxError{pos: operator.pos, reason: "оператор " + operator.text + " применим только к числовым полям"}
+	}
+
+	value, err := p.number(f)
+	if err != nil {
+		return nil, err
+	}
+
+	compare := map[string]func(float64) bool{
+		"<":  func(n float64) bool { return n < value },
+		"<=": func(n float64) bool { return n <= value },
+		">":  func(n float64) bool { return n > value },
+		">=": func(n float64) bool { return n >= value },
+	}[operator.text]
+
+	return func(log *domain.LogData) bool {
+		n, ok := f.number(log)
+		return ok && compare(n)
+	}, nil
+}
+
+// in разбирает правую часть in: один элемент или список в скобках.
+func (p *parser) in(f field) (Predicate, error) {
+	if !p.accept("(") {
+		return p.member(f, true)
+	}
+
+	var members []Predicate
+
+	for {
+		member, err := p.member(f, true)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return anyOf(members), nil
+}
+
+// member разбирает значение для == или элемент in. В in числовому полю доступны диапазоны a..b,
+// а IP-адресу — подсети.
+func (p *parser) member(f field, inList bool) (Predicate, error) {
+	switch f.kind {
+	case kindNumber:
+		return p.numberMember(f, inList)
+	case kindIP:
+		return p.ipMember(f, inList)
+	default:
+		t, ok := p.take(tokenString)
+		if !ok {
+			return nil, p.unexpected("ожидается строка в кавычках")
+		}
+
+		return func(log *domain.LogData) bool { return strings.EqualFold(f.text(log), t.text) }, nil
+	}
+}
+
+func (p *parser) numberMember(f field, inList bool) (Predicate, error) {
+	low, err := p.number(f)
+	if err != nil {
+		return nil, err
+	}
+
+	high := low
+
+	if inList && p.accept("..") {
+		if high, err = p.number(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(log *domain.LogData) bool {
+		n, ok := f.number(log)
+		return ok && n >= low && n <= high
+	}, nil
+}
+
+func (p *parser) ipMember(f field, inList bool) (Predicate, error) {
+	t, ok := p.take(tokenWord, tokenString)
+	if !ok {
+		return nil, p.unexpected("ожидается IP-адрес")
+	}
+
+	if inList && strings.Contains(t.text, "/") {
+		prefix, err := netip.ParsePrefix(t.text)
+		if err != nil {
+			return nil, &syntaxError{pos: t.pos, reason: "неверная подсеть " + t.text}
+		}
+
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()).Masked()
+
+		return func(log *domain.LogData) bool {
+			addr, err := netip.ParseAddr(f.text(log))
+			return err == nil && prefix.Contains(addr.Unmap())
+		}, nil
+	}
+
+	if strings.Contains(t.text, "/") {
+		return nil, &syntaxError{pos: t.pos, reason: "подсеть проверяется оператором in: ip in " + t.text}
+	}
+
+	want, err := netip.ParseAddr(t.text)
+	if err != nil {
+		return nil, &syntaxError{pos: t.pos, reason: "неверный IP-адрес " + t.text}
+	}
+
+	want = want.Unmap()
+
+	return func(log *domain.LogData) bool {
+		addr, err := netip.ParseAddr(f.text(log))
+		return err == nil && addr.Unmap() == want
+	}, nil
+}
+
+// number разбирает число или, для полей времени, длительность; число в кавычках тоже допускается.
+func (p *parser) number(f field) (float64, error) {
+	t, ok := p.take(tokenWord, tokenString)
+	if !ok {
+		return 0, p.unexpected("ожидается число")
+	}
+
+	if value, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return value, nil
+	}
+
+	if f.duration {
+		if value, err := time.ParseDuration(t.text); err == nil {
+			return value.Seconds(), nil
+		}
+
+		return 0, &syntaxError{pos: t.pos, reason: "ожидается число секунд или длительность (300ms, 1.5s), получено " + strconv.Quote(t.text)}
+	}
+
+	return 0, &syntaxError{pos: t.pos, reason: "ожидается число, получено " + strconv.Quote(t.text)}
+}
@@ -0,0 +1,207 @@
+package filter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	post := &domain.LogData{
+		IPAddress: "10.1.2.3", Method: "POST", Resource: "/api/orders/42", Endpoint: "/api/orders/{id}", StatusCode: "502", ResponseSize: 1500,
+		UserAgent: "Mozilla/5.0", RequestTime: 750 * time.Millisecond, HasRequestTime: true,
+	}
+	bot := &domain.LogData{
+		IPAddress: "192.168.0.7", Method: "GET", Resource: "/", StatusCode: "200", ResponseSize: 100,
+		UserAgent: "Googlebot/2.1",
+	}
+
+	tests := []struct {
+		expression string
+		post, bot  bool
+	}{
+		{`status >= 500 && method in ("POST","PUT") && !(agent ~ "bot")`, true, false},
+		{`status == 200`, false, true},
+		{`status == "502"`, true, false},
+		{`status != 200`, true, false},
+		{`status ~ "^5"`, true, false},
+		{`status in (200..299, 304)`, false, true},
+		{`status in 500..599 || size < 200`, true, true},
+		{`method == "get"`, false, true},
+		{`agent !~ "(?i)bot"`, true, false},
+		{`ip in 10.0.0.0/8`, true, false},
+		{`ip in (10.0.0.0/8, 192.168.0.0/16)`, true, true},
+		{`ip == 192.168.0.7`, false, true},
+		{`request_time > 500ms`, true, false},
+		{`request_time <= 1`, true, false},
+		// У bot нет $request_time: сравнения с отсутствующим полем ложны, в том числе !=.
+		{`request_time != 0.75`, false, false},
+		{`!(request_time > 0)`, false, true},
+		{`referer == ""`, true, true},
+		{`agent_class == "bot"`, false, true},
+		{`endpoint == "/api/orders/{id}"`, true, false},
+		{`agent_class in ("desktop", "other") || agent_family == "googlebot"`, true, true},
+		{`method == "GET" || method == "POST" && status == 200`, false, true},
+		{`(method == "GET" || method == "POST") && status == 200`, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			predicate, err := filter.Compile(tt.expression)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.post, predicate(post), "post")
+			assert.Equal(t, tt.bot, predicate(bot), "bot")
+		})
+	}
+}
+
+func TestCompile_Empty(t *testing.T) {
+	predicate, err := filter.Compile("  ")
+
+	require.NoError(t, err)
+	assert.Nil(t, predicate)
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		position   int
+		reason     string
+	}{
+		{`stat == 200`, 0, `неизвестное поле "stat"`},
+		{`status >= `, 10, "ожидается число, а выражение закончилось"},
+		{`status == 200 &&`, 16, "ожидается имя поля"},
+		{`method < "GET"`, 7, "применим только к числовым полям"},
+		{`agent ~ "("`, 8, "неверное регулярное выражение"},
+		{`agent == bot`, 9, "ожидается строка в кавычках"},
+		{`(status == 200`, 14, "ожидается )"},
+		{`status == 200 status`, 14, "ожидается && или ||"},
+		{`ip == 10.0.0.0/8`, 6, "ip in 10.0.0.0/8"},
+		{`ip in 10.0.0.0/33`, 6, "неверная подсеть"},
+		{`size == 1kb`, 8, `ожидается число, получено "1kb"`},
+		{`method == "GET`, 10, "незакрытая строка"},
+		{`method # "GET"`, 7, "неожиданный символ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := filter.Compile(tt.expression)
+
+			var invalid *domain.InvalidFilterError
+			require.ErrorAs(t, err, &invalid)
+			assert.Equal(t, tt.expression, invalid.Expression)
+			assert.Equal(t, tt.position, invalid.Position)
+			assert.Contains(t, invalid.Reason, tt.reason)
+		})
+	}
+}
+
+func TestForConfig(t *testing.T) {
+	predicate, err := filter.ForConfig(&domain.InputConfig{
+		Filters: []string{"size > 100", "ip in 10.0.0.0/8"},
+		FieldFilters: []domain.FieldFilter{
+			{Field: domain.STATUS, Value: "5xx"},
+			{Field: domain.METHOD, Value: "post"},
+			{Field: domain.STATUS, Value: "404"},
+		},
+	})
+	require.NoError(t, err)
+
+	// Условия на status объединяются через ИЛИ, остальные — через И.
+	assert.True(t, predicate(&domain.LogData{IPAddress: "10.0.0.1", Method: "POST", StatusCode: "503", ResponseSize: 200}))
+	assert.True(t, predicate(&domain.LogData{IPAddress: "10.0.0.1", Method: "POST", StatusCode: "404", ResponseSize: 200}))
+	assert.False(t, predicate(&domain.LogData{IPAddress: "10.0.0.1", Method: "POST", StatusCode: "200", ResponseSize: 200}))
+	assert.False(t, predicate(&domain.LogData{IPAddress: "10.0.0.1", Method: "GET", StatusCode: "503", ResponseSize: 200}))
+	assert.False(t, predicate(&domain.LogData{IPAddress: "10.0.0.1", Method: "POST", StatusCode: "503", ResponseSize: 50}))
+	assert.False(t, predicate(&domain.LogData{IPAddress: "172.16.0.1", Method: "POST", StatusCode: "503", ResponseSize: 200}))
+
+	predicate, err = filter.ForConfig(&domain.InputConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, predicate)
+
+	_, err = filter.ForConfig(&domain.InputConfig{Filters: []string{"status >="}})
+	assert.ErrorAs(t, err, new(*domain.InvalidFilterError))
+}
+
+func TestFieldPredicate(t *testing.T) {
+	log := &domain.LogData{
+		IPAddress: "10.1.2.3", Resource: "/wp-login.php", StatusCode: "502", ResponseSize: 2_000_000,
+		UserAgent: "Googlebot/2.1", RequestTime: 750 * time.Millisecond, HasRequestTime: true,
+	}
+
+	tests := []struct {
+		field domain.FilterField
+		value string
+		want  bool
+	}{
+		{domain.STATUS, "502", true},
+		{domain.STATUS, "5xx", true},
+		{domain.STATUS, "5XX", true},
+		{domain.STATUS, "4xx", false},
+		{domain.STATUS, "!5xx", false},
+		{domain.STATUS, "500-599", true},
+		{domain.STATUS, "200..299", false},
+		{domain.STATUS, ">=500", true},
+		{domain.STATUS, "!=502", false},
+		{domain.SIZE, ">1000000", true},
+		{domain.SIZE, "<= 1000", false},
+		{domain.REQUEST, ">500ms", true},
+		{domain.REQUEST, "0.5-1", true},
+		{domain.IP, "10.0.0.0/8", true},
+		{domain.IP, "!10.0.0.0/8", false},
+		{domain.IP, "10.1.2.3", true},
+		{domain.RESOURCE, "*.php", true},
+		{domain.RESOURCE, "/WP-*", true},
+		{domain.RESOURCE, "/wp-login.ph?", true},
+		{domain.RESOURCE, "*.html", false},
+		{domain.RESOURCE, "/wp-login.php", true},
+		{domain.AGENT, "~bot/\\d", true},
+		{domain.AGENT, "!~(?i)bot", false},
+		{domain.AGENT, "googlebot/2.1", true},
+		{domain.AGENTCLASS, "bot", true},
+		{domain.AGENTCLASS, "!bot", false},
+		{domain.AGENTFAMILY, "Google*", true},
+		{domain.AGENTOS, "Android", false},
+		// Значение не разбирается как выражение: ИЛИ внутри него — часть строки.
+		{domain.AGENT, `x" || agent ~ ".`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.field)+"="+tt.value, func(t *testing.T) {
+			predicate, err := filter.FieldPredicate(domain.FieldFilter{Field: tt.field, Value: tt.value})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, predicate(log))
+		})
+	}
+}
+
+func TestFieldPredicate_Errors(t *testing.T) {
+	tests := []struct {
+		field  domain.FilterField
+		value  string
+		reason string
+	}{
+		{domain.SIZE, "5xx", `ожидается число, получено "5xx"`},
+		{domain.SIZE, ">big", `ожидается число, получено "big"`},
+		{domain.IP, "10.0.0.0/40", "неверная подсеть"},
+		{domain.AGENT, "~(", "неверное регулярное выражение"},
+		{"host", "example.com", "неизвестное поле"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.field)+"="+tt.value, func(t *testing.T) {
+			_, err := filter.FieldPredicate(domain.FieldFilter{Field: tt.field, Value: tt.value})
+
+			var invalid *domain.InvalidFilterValueError
+			require.ErrorAs(t, err, &invalid)
+			assert.Equal(t, tt.field, invalid.Field)
+			assert.Equal(t, tt.value, invalid.Value)
+			assert.Contains(t, invalid.Reason, tt.reason)
+		})
+	}
+}
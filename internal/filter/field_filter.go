@@ -0,0 +1,138 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// statusClass — класс кодов ответа вида 5xx.
+var statusClass = regexp.MustCompile(`^(?i)([1-5])xx$`)
+
+// orderPrefixes проверяются по порядку, двухсимвольные раньше своих префиксов.
+var orderPrefixes = []string{">=", "<=", ">", "<", "=="}
+
+// FieldPredicate компилирует условие --filter-field/--filter-value. Значение типизировано полем:
+//   - числа (status, size, request_time, upstream_time): 404, 5xx, 500-599, 500..599, >1000000, <=300ms;
+//   - ip: адрес или подсеть 10.0.0.0/8;
+//   - строки: точное значение без учёта регистра, шаблон с * и ?, регулярное выражение ~regex.
+//
+// Префикс ! или != отрицает условие, !~regex — регулярное выражение.
+func FieldPredicate(fieldFilter domain.FieldFilter) (Predicate, error) {
+	expression, err := fieldExpression(fieldFilter)
+	if err == nil {
+		var predicate Predicate
+
+		if predicate, err = Compile(expression); err == nil {
+			return predicate, nil
+		}
+	}
+
+	var invalid *domain.InvalidFilterError
+	if errors.As(err, &invalid) {
+		err = &domain.InvalidFilterValueError{Field: fieldFilter.Field, Value: fieldFilter.Value, Reason: invalid.Reason}
+	}
+
+	return nil, err
+}
+
+// fieldExpression переводит условие на поле в выражение фильтра. Значение всегда попадает
+// в выражение строкой в кавычках, поэтому не может изменить его структуру.
+func fieldExpression(fieldFilter domain.FieldFilter) (string, error) {
+	name, value := fieldFilter.Field, fieldFilter.Value
+
+	f, ok := fields[name]
+	if !ok {
+		return "", &domain.InvalidFilterValueError{
+			Field: name, Value: value, Reason: fmt.Sprintf("неизвестное поле, доступны: %v", domain.FilterFields),
+		}
+	}
+
+	negate := false
+
+	switch {
+	case strings.HasPrefix(value, "!="):
+		negate, value = true, value[2:]
+	case strings.HasPrefix(value, "!"):
+		negate, value = true, value[1:]
+	}
+
+	var expression string
+
+	switch f.kind {
+	case kindNumber:
+		expression = numberExpression(name, value)
+	case kindIP:
+		expression = fmt.Sprintf("%s in %s", name, strconv.Quote(value))
+	default:
+		expression = stringExpression(name, value)
+	}
+
+	if negate {
+		return "!(" + expression + ")", nil
+	}
+
+	return expression, nil
+}
+
+func numberExpression(name domain.FilterField, value string) string {
+	for _, prefix := range orderPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			return fmt.Sprintf("%s %s %s", name, prefix, strconv.Quote(strings.TrimSpace(value[len(prefix):])))
+		}
+	}
+
+	if match := statusClass.FindStringSubmatch(value); match != nil && name == domain.STATUS {
+		return fmt.Sprintf("%s in %s00..%s99", name, match[1], match[1])
+	}
+
+	low, high, ok := strings.Cut(value, "..")
+	if !ok && strings.Index(value, "-") > 0 {
+		low, high, ok = strings.Cut(value, "-")
+	}
+
+	if ok {
+		return fmt.Sprintf("%s in %s..%s", name, strconv.Quote(low), strconv.Quote(high))
+	}
+
+	return fmt.Sprintf("%s == %s", name, strconv.Quote(value))
+}
+
+func stringExpression(name domain.FilterField, value string) string {
+	if pattern, ok := strings.CutPrefix(value, "~"); ok {
+		return fmt.Sprintf("%s ~ %s", name, strconv.Quote(pattern))
+	}
+
+	if strings.ContainsAny(value, "*?") {
+		return fmt.Sprintf("%s ~ %s", name, strconv.Quote(globPattern(value)))
+	}
+
+	return fmt.Sprintf("%s == %s", name, strconv.Quote(value))
+}
+
+// globPattern переводит шаблон с * (любые символы) и ? (один символ) в регулярное выражение
+// для всего значения без учёта регистра, как и точное сравнение строк.
+func globPattern(glob string) string {
+	var builder strings.Builder
+
+	builder.WriteString("(?i)^")
+
+	for _, r := range glob {
+		switch r {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	builder.WriteString("$")
+
+	return builder.String()
+}
@@ -0,0 +1,76 @@
+package filter
+
+import (
+	"strconv"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/useragent"
+)
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+	kindIP
+)
+
+// field — поле LogData, доступное в выражении.
+type field struct {
+	kind fieldKind
+	// text — значение для ==, ~ и in; nil, если у поля нет строкового представления.
+	text func(*domain.LogData) string
+	// number — значение числового поля; false, если в записи его нет.
+	number func(*domain.LogData) (float64, bool)
+	// duration — значения поля можно записывать длительностями (300ms, 1.5s); число означает секунды.
+	duration bool
+}
+
+var fields = map[domain.FilterField]field{
+	domain.AGENT:       stringField(func(log *domain.LogData) string { return log.UserAgent }),
+	domain.METHOD:      stringField(func(log *domain.LogData) string { return log.Method }),
+	domain.RESOURCE:    stringField(func(log *domain.LogData) string { return log.Resource }),
+	domain.REFERER:     stringField(func(log *domain.LogData) string { return log.Referer }),
+	domain.REMOTEUSER:  stringField(func(log *domain.LogData) string { return log.RemoteUser }),
+	domain.ENDPOINT:    stringField(func(log *domain.LogData) string { return log.Endpoint }),
+	domain.PATH:        stringField(func(log *domain.LogData) string { return log.Path }),
+	domain.FILE:        stringField(func(log *domain.LogData) string { return log.Filename }),
+	domain.FORMAT:      stringField(func(log *domain.LogData) string { return log.Format }),
+	domain.AGENTCLASS:  stringField(func(log *domain.LogData) string { return useragent.Classify(log.UserAgent).Device }),
+	domain.AGENTFAMILY: stringField(func(log *domain.LogData) string { return useragent.Classify(log.UserAgent).Family }),
+	domain.AGENTOS:     stringField(func(log *domain.LogData) string { return useragent.Classify(log.UserAgent).OS }),
+	domain.IP:          {kind: kindIP, text: func(log *domain.LogData) string { return log.IPAddress }},
+	domain.STATUS: {
+		kind: kindNumber,
+		text: func(log *domain.LogData) string { return log.StatusCode },
+		number: func(log *domain.LogData) (float64, bool) {
+			status, err := strconv.Atoi(log.StatusCode)
+			return float64(status), err == nil
+		},
+	},
+	domain.SIZE: {
+		kind: kindNumber,
+		text: func(log *domain.LogData) string { return strconv.FormatInt(log.ResponseSize, 10) },
+		number: func(log *domain.LogData) (float64, bool) {
+			return float64(log.ResponseSize), true
+		},
+	},
+	domain.REQUEST: {
+		kind:     kindNumber,
+		duration: true,
+		number: func(log *domain.LogData) (float64, bool) {
+			return log.RequestTime.Seconds(), log.HasRequestTime
+		},
+	},
+	domain.UPSTREAM: {
+		kind:     kindNumber,
+		duration: true,
+		number: func(log *domain.LogData) (float64, bool) {
+			return log.UpstreamResponseTime.Seconds(), log.HasUpstreamTime
+		},
+	},
+}
+
+func stringField(text func(*domain.LogData) string) field {
+	return field{kind: kindString, text: text}
+}
@@ -0,0 +1,129 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenIdent — имя поля или ключевое слово in.
+	tokenIdent
+	// tokenString — строка в двойных кавычках с экранированием как в Go.
+	tokenString
+	// tokenWord — значение без кавычек: число, длительность, IP-адрес или подсеть.
+	tokenWord
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// pos — смещение начала токена в выражении, в байтах.
+	pos int
+}
+
+// operators перечислены так, чтобы двухсимвольные проверялись раньше своих префиксов.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "..", "<", ">", "~", "!", "(", ")", ","}
+
+// tokenize разбивает выражение на токены; ошибка содержит позицию первого неразобранного символа.
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(expression); {
+		c := expression[pos]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '"':
+			end, err := stringEnd(expression, pos)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := strconv.Unquote(expression[pos:end])
+			if err != nil {
+				return nil, &syntaxError{pos: pos, reason: "неверная строка " + expression[pos:end]}
+			}
+
+			tokens = append(tokens, token{kind: tokenString, text: value, pos: pos})
+			pos = end
+		case isIdentStart(c):
+			end := pos + 1
+			for end < len(expression) && isIdentPart(expression[end]) {
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, text: expression[pos:end], pos: pos})
+			pos = end
+		case isWordStart(expression, pos):
+			end := pos + 1
+			for end < len(expression) && isWordPart(expression[end]) && !strings.HasPrefix(expression[end:], "..") {
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenWord, text: expression[pos:end], pos: pos})
+			pos = end
+		default:
+			operator := matchOperator(expression[pos:])
+			if operator == "" {
+				return nil, &syntaxError{pos: pos, reason: "неожиданный символ " + strconv.QuoteRune(rune(c))}
+			}
+
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: pos})
+			pos += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(expression)}), nil
+}
+
+// stringEnd возвращает позицию за закрывающей кавычкой строки, начинающейся в start.
+func stringEnd(expression string, start int) (int, error) {
+	for pos := start + 1; pos < len(expression); pos++ {
+		switch expression[pos] {
+		case '\\':
+			pos++
+		case '"':
+			return pos + 1, nil
+		}
+	}
+
+	return 0, &syntaxError{pos: start, reason: "незакрытая строка"}
+}
+
+func matchOperator(rest string) string {
+	for _, operator := range operators {
+		if strings.HasPrefix(rest, operator) {
+			return operator
+		}
+	}
+
+	return ""
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// isWordStart: значения без кавычек начинаются с цифры, минуса перед цифрой или двоеточия (IPv6).
+func isWordStart(expression string, pos int) bool {
+	c := expression[pos]
+
+	if c >= '0' && c <= '9' || c == ':' {
+		return true
+	}
+
+	return c == '-' && pos+1 < len(expression) && expression[pos+1] >= '0' && expression[pos+1] <= '9'
+}
+
+func isWordPart(c byte) bool {
+	return isIdentPart(c) || c == '.' || c == ':' || c == '/'
+}
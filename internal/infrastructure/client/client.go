@@ -4,75 +4,383 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/netip"
+	"os"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/filter"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/4domm/ngxstat/internal/urlpath"
 )
 
 const (
 	DateFormatWithTime = "2006-01-02T15:04:05-0700"
 	DateFormatNoTime   = "2006-01-02"
+	DefaultWindow      = 5 * time.Minute
+	DefaultRefresh     = 10 * time.Second
+	// DefaultSnapshotFile — файл, в который команда snapshot сохраняет состояние без флага --out.
+	DefaultSnapshotFile = "ngxstat.snapshot"
+	// DefaultListen — адрес сервера метрик команды serve без флага --listen.
+	DefaultListen = ":9113"
+	// DefaultAPIListen — адрес HTTP API команды api без флага --listen.
+	DefaultAPIListen = ":8080"
 )
 
+// ParseFlags разбирает os.Args: необязательную команду (domain.Commands) и флаги. Каждая команда
+// принимает только свои флаги (см. newFlagSet), неизвестный флаг завершает программу с подсказкой.
 func ParseFlags() (*domain.InputConfig, error) {
-	var path, outputFormat, filterValue string
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && slices.Contains(domain.Commands, args[0]) {
+		command, args = args[0], args[1:]
+	}
 
-	var filterField string
+	opts := newOptions(command)
+	flags := newFlagSet(command, opts)
 
-	flag.StringVar(&path, "path", "", "Путь к лог-файлам или URL")
-	flag.StringVar(&outputFormat, "format", "", "Формат вывода (adoc или markdown)")
-	flag.StringVar(&filterField, "filter-field", "", "Поле для фильтрации")
-	flag.StringVar(&filterValue, "filter-value", "", "Значение для фильтрации")
+	positional := parseArgs(flags, args)
 
-	var fromStr, toStr string
+	if err := validateCommand(command, opts.path, positional); err != nil {
+		return nil, err
+	}
 
-	flag.StringVar(&fromStr, "from", "", "Начало временного диапазона в формате ISO8601")
-	flag.StringVar(&toStr, "to", "", "Конец временного диапазона в формате ISO8601")
+	if err := opts.validate(command); err != nil {
+		return nil, err
+	}
+
+	config, err := opts.inputConfig(command, positional)
+	if err != nil {
+		return nil, err
+	}
 
-	flag.Parse()
+	// Выражение фильтра и правила --rewrite разбираются заранее, чтобы ошибка в них не откладывалась
+	// до чтения логов.
+	if _, err := filter.ForConfig(config); err != nil {
+		return nil, err
+	}
 
-	if path == "" {
-		return nil, errors.New("use path flag to define path to files")
+	if _, err := urlpath.ForConfig(config); err != nil {
+		return nil, err
 	}
 
-	var from, to time.Time
+	return config, nil
+}
 
-	var err error
-	from, err = ParseDate(fromStr)
+// parseArgs разбирает флаги вперемешку с позиционными аргументами и возвращает позиционные:
+// flag.Parse останавливается на первом из них, и в `merge a.snapshot --format json` флаг считался бы
+// файлом снимка. После «--» все аргументы позиционные.
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
 
+	for {
+		_ = flags.Parse(args)
+		rest := flags.Args()
+
+		if len(rest) == 0 {
+			return positional
+		}
+
+		if parsed := args[:len(args)-len(rest)]; len(parsed) > 0 && parsed[len(parsed)-1] == "--" {
+			return append(positional, rest...)
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// options — значения флагов до проверки. Поля флагов, которых у команды нет, сохраняют значения
+// по умолчанию из newOptions.
+type options struct {
+	path, outputFormat, logFormat                           string
+	parserName, jsonFields, jsonTimeLayout                  string
+	filterFields, filterValues, filterExpressions, rewrites repeatedFlag
+	detectLines                                             int
+	maxErrorRate                                            float64
+	follow                                                  bool
+	window, refresh                                         time.Duration
+	bucket                                                  string
+	approxTop, workers                                      int
+	clientIP, queryParam                                    string
+	from, to                                                string
+	output, listen                                          string
+	basePath, baseFrom, baseTo                              string
+}
+
+func newOptions(command string) *options {
+	return &options{
+		parserName:   domain.AUTO,
+		detectLines:  parser.DefaultDetectLines,
+		maxErrorRate: 1,
+		window:       DefaultWindow,
+		refresh:      DefaultRefresh,
+		output:       DefaultSnapshotFile,
+		listen:       defaultListen(command),
+	}
+}
+
+// newFlagSet регистрирует флаги команды: чтение логов, период, формат отчёта и его разделы —
+// только там, где команда их использует.
+func newFlagSet(command string, opts *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(strings.TrimSpace("ngxstat "+command), flag.ExitOnError)
+
+	switch command {
+	case domain.SNAPSHOT:
+		opts.addLogFlags(flags)
+		opts.addRangeFlags(flags)
+		flags.StringVar(&opts.output, "out", opts.output, "Файл снимка")
+	case domain.MERGE:
+		opts.addFormatFlags(flags)
+		opts.addSectionFlags(flags)
+	case domain.DIFF:
+		opts.addLogFlags(flags)
+		opts.addRangeFlags(flags)
+		opts.addFormatFlags(flags)
+		flags.StringVar(&opts.basePath, "base-path", "", "Логи базового периода (по умолчанию --path)")
+		flags.StringVar(&opts.baseFrom, "base-from", "", "Начало базового периода в формате ISO8601")
+		flags.StringVar(&opts.baseTo, "base-to", "", "Конец базового периода в формате ISO8601")
+	case domain.SERVE:
+		opts.addLogFlags(flags)
+		flags.StringVar(&opts.listen, "listen", opts.listen, "Адрес HTTP-сервера метрик Prometheus")
+	case domain.API:
+		opts.addLogFlags(flags)
+		opts.addRangeFlags(flags)
+		opts.addSectionFlags(flags)
+		flags.StringVar(&opts.listen, "listen", opts.listen, "Адрес HTTP API")
+	default:
+		opts.addLogFlags(flags)
+		opts.addRangeFlags(flags)
+		opts.addFormatFlags(flags)
+		opts.addSectionFlags(flags)
+		flags.BoolVar(&opts.follow, "follow", false, "Следить за файлами как tail -F и периодически обновлять отчёт")
+		flags.DurationVar(&opts.window, "window", opts.window, "Окно статистики в режиме --follow")
+		flags.DurationVar(&opts.refresh, "refresh", opts.refresh, "Период обновления отчёта в режиме --follow")
+	}
+
+	return flags
+}
+
+// addLogFlags регистрирует флаги чтения и разбора логов: источник, парсер, фильтры и подсчёт.
+func (o *options) addLogFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.path, "path", "", "Путь к лог-файлам или URL")
+	flags.Var(&o.filterFields, "filter-field", "Поле для фильтрации; флаг можно повторять в паре с --filter-value")
+	flags.Var(&o.filterValues, "filter-value", "Значение для фильтрации: 5xx, >1000000, 10.0.0.0/8, *.php, ~regex, !значение")
+	flags.Var(&o.filterExpressions, "filter", `Выражение фильтра, например 'status >= 500 && !(agent ~ "bot")'; флаг можно повторять`)
+	flags.Var(&o.rewrites, "rewrite", `Правило шаблона пути "выражение=>замена", например '^/static/.*=>/static/*'; флаг можно повторять`)
+	flags.StringVar(&o.logFormat, "log-format", "", "Строка log_format nginx, по которой разбираются логи")
+	flags.StringVar(&o.parserName, "parser", o.parserName, "Парсер логов (auto, nginx или json)")
+	flags.StringVar(&o.jsonFields, "json-fields", "", "Сопоставление полей JSON-лога, например timestamp=ts,status=code")
+	flags.StringVar(&o.jsonTimeLayout, "json-time-layout", "", "Формат времени в JSON-логе: layout Go, iso8601 или unix")
+	flags.IntVar(&o.detectLines, "detect-lines", o.detectLines, "Число первых строк файла для определения формата (parser=auto)")
+	flags.IntVar(&o.approxTop, "approx-top", 0, "Считать топы ресурсов и рефереров приближённо в памяти на N значений (0 — точно)")
+	flags.IntVar(&o.workers, "workers", 0, "Число горутин разбора и подсчёта (0 — по числу ядер)")
+}
+
+func (o *options) addRangeFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.from, "from", "", "Начало временного диапазона в формате ISO8601")
+	flags.StringVar(&o.to, "to", "", "Конец временного диапазона в формате ISO8601")
+}
+
+// addFormatFlags регистрирует флаги команд, которые пишут отчёт в файл.
+func (o *options) addFormatFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.outputFormat, "format", "", "Формат вывода (adoc, markdown, json или html)")
+	flags.Float64Var(&o.maxErrorRate, "max-error-rate", o.maxErrorRate, "Допустимая доля неразобранных строк (0..1), при превышении — ошибка")
+}
+
+// addSectionFlags регистрирует флаги разделов отчёта по логам.
+func (o *options) addSectionFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.bucket, "bucket", "", "Ширина интервала временного ряда в отчёте: 1m, 5m, 1h или 1d")
+	flags.StringVar(&o.queryParam, "query-param", "", "Вывести самые частые значения параметра query string, например utm_source")
+	flags.StringVar(&o.clientIP, "ip", "", "Построить отчёт по одному IP-адресу клиента: запросы по времени, ресурсы, ошибки")
+}
+
+// validate проверяет значения флагов, не зависящие от дат.
+func (o *options) validate(command string) error {
+	if err := validateBaseline(command, o.path, o.basePath, o.baseFrom, o.baseTo); err != nil {
+		return err
+	}
+
+	if o.outputFormat != "" && !slices.Contains([]string{domain.MARKDOWN, domain.ADOC, domain.JSON, domain.HTML}, o.outputFormat) {
+		return &domain.InvalidOutputFormatError{Format: o.outputFormat}
+	}
+
+	if !slices.Contains([]string{domain.AUTO, domain.NGINX, domain.JSON}, o.parserName) {
+		return &domain.InvalidParserError{Parser: o.parserName}
+	}
+
+	if o.follow && IsURL(o.path) {
+		return domain.ErrFollowURL
+	}
+
+	if command == domain.SERVE && IsURL(o.path) {
+		return domain.ErrServeURL
+	}
+
+	if o.follow && (o.window <= 0 || o.refresh <= 0) {
+		return &domain.InvalidFollowIntervalError{Window: o.window, Refresh: o.refresh}
+	}
+
+	if _, ok := domain.BucketWidths[o.bucket]; o.bucket != "" && !ok {
+		return &domain.InvalidBucketError{Bucket: o.bucket}
+	}
+
+	if o.approxTop < 0 {
+		return &domain.InvalidApproxTopError{Capacity: o.approxTop}
+	}
+
+	if o.workers < 0 {
+		return &domain.InvalidWorkersError{Workers: o.workers}
+	}
+
+	if o.maxErrorRate < 0 || o.maxErrorRate > 1 {
+		return &domain.InvalidErrorRateError{Rate: o.maxErrorRate}
+	}
+
+	if _, err := netip.ParseAddr(o.clientIP); o.clientIP != "" && err != nil {
+		return &domain.InvalidClientIPError{IP: o.clientIP}
+	}
+
+	return nil
+}
+
+// inputConfig разбирает даты и собирает настройки запуска; args — снимки команды merge.
+func (o *options) inputConfig(command string, args []string) (*domain.InputConfig, error) {
+	from, err := ParseDate(o.from)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := ParseDate(o.to)
 	if err != nil {
 		return nil, err
 	}
 
-	to, err = ParseDate(toStr)
+	baseFrom, err := ParseDate(o.baseFrom)
 	if err != nil {
 		return nil, err
 	}
 
-	if outputFormat != "" && !(outputFormat == domain.MARKDOWN || outputFormat == domain.ADOC) {
-		return nil, &domain.InvalidOutputFormatError{Format: outputFormat}
+	baseTo, err := ParseDate(o.baseTo)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldFilters, err := pairFieldFilters(o.filterFields, o.filterValues)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.InputConfig{
+		Command:        command,
+		Path:           o.path,
+		From:           from,
+		To:             to,
+		OutputFormat:   o.outputFormat,
+		Filters:        o.filterExpressions,
+		FieldFilters:   fieldFilters,
+		LogFormat:      o.logFormat,
+		Parser:         o.parserName,
+		JSONFields:     o.jsonFields,
+		JSONTimeLayout: o.jsonTimeLayout,
+		DetectLines:    o.detectLines,
+		MaxErrorRate:   o.maxErrorRate,
+		Follow:         o.follow,
+		Window:         o.window,
+		Refresh:        o.refresh,
+		Bucket:         domain.BucketWidths[o.bucket],
+		ApproxTop:      o.approxTop,
+		Workers:        o.workers,
+		Rewrites:       o.rewrites,
+		QueryParam:     o.queryParam,
+		ClientIP:       o.clientIP,
+		Output:         o.output,
+		Snapshots:      args,
+		Listen:         o.listen,
+		BasePath:       o.basePath,
+		BaseFrom:       baseFrom,
+		BaseTo:         baseTo,
+	}, nil
+}
+
+// validateCommand проверяет аргументы, которые зависят от команды: merge читает снимки вместо логов.
+func validateCommand(command, path string, args []string) error {
+	if command == domain.MERGE {
+		if len(args) == 0 {
+			return domain.ErrNoSnapshots
+		}
+
+		return nil
+	}
+
+	if path == "" {
+		return errors.New("use path flag to define path to files")
 	}
 
-	if filterField != "" && filterValue == "" {
+	return nil
+}
+
+// defaultListen возвращает адрес, который команда слушает без флага --listen.
+func defaultListen(command string) string {
+	if command == domain.API {
+		return DefaultAPIListen
+	}
+
+	return DefaultListen
+}
+
+// repeatedFlag собирает значения флага, указанного несколько раз.
+type repeatedFlag []string
+
+func (r *repeatedFlag) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *repeatedFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// pairFieldFilters сопоставляет повторяющиеся --filter-field и --filter-value по порядку.
+func pairFieldFilters(fields, values []string) ([]domain.FieldFilter, error) {
+	if len(fields) > len(values) {
 		return nil, &domain.MissingFilterValueError{}
 	}
 
-	if (filterField == "" && filterValue != "") || (filterField != "" && filterValue == "") {
+	if len(fields) < len(values) {
 		return nil, &domain.InvalidFilterCombinationError{}
 	}
 
-	if !slices.Contains(domain.FilterFields, domain.FilterField(filterField)) {
-		return nil, fmt.Errorf("не поддерживается фильтрация по данному полю, варианты:%v", domain.FilterFields)
+	fieldFilters := make([]domain.FieldFilter, 0, len(fields))
+
+	for i, field := range fields {
+		if values[i] == "" {
+			return nil, &domain.MissingFilterValueError{}
+		}
+
+		fieldFilters = append(fieldFilters, domain.FieldFilter{Field: domain.FilterField(field), Value: values[i]})
 	}
 
-	return &domain.InputConfig{
-			FilterField: domain.FilterField(filterField),
-			FilterValue: filterValue, From: from,
-			To:           to,
-			OutputFormat: outputFormat,
-			Path:         path},
-		nil
+	return fieldFilters, nil
+}
+
+// validateBaseline проверяет, что для diff задан базовый период, отличный от текущего,
+// и что оба периода читаются одним способом.
+func validateBaseline(command, path, basePath, baseFrom, baseTo string) error {
+	if command != domain.DIFF {
+		return nil
+	}
+
+	if basePath == "" && baseFrom == "" && baseTo == "" {
+		return domain.ErrNoBaseline
+	}
+
+	if basePath != "" && IsURL(basePath) != IsURL(path) {
+		return domain.ErrMixedSources
+	}
+
+	return nil
 }
 
 func ParseDate(dateStr string) (time.Time, error) {
@@ -92,3 +400,7 @@ func ParseDate(dateStr string) (time.Time, error) {
 
 	return time.Time{}, fmt.Errorf("неверный формат даты: %v", err)
 }
+
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
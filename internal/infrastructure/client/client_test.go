@@ -0,0 +1,54 @@
+package client_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlags_MergeFlagsAfterSnapshots(t *testing.T) {
+	tests := map[string]struct {
+		args      []string
+		snapshots []string
+		format    string
+	}{
+		"flags before snapshots": {
+			args:      []string{"merge", "--format", "json", "a.snap", "b.snap"},
+			snapshots: []string{"a.snap", "b.snap"},
+			format:    domain.JSON,
+		},
+		"flags after snapshots": {
+			args:      []string{"merge", "a.snap", "b.snap", "--format", "json"},
+			snapshots: []string{"a.snap", "b.snap"},
+			format:    domain.JSON,
+		},
+		"flags between snapshots": {
+			args:      []string{"merge", "a.snap", "--format=adoc", "b.snap"},
+			snapshots: []string{"a.snap", "b.snap"},
+			format:    domain.ADOC,
+		},
+		"snapshot named like a flag after --": {
+			args:      []string{"merge", "a.snap", "--", "--format"},
+			snapshots: []string{"a.snap", "--format"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := os.Args
+			defer func() { os.Args = args }()
+
+			os.Args = append([]string{"ngxstat"}, tt.args...)
+
+			config, err := client.ParseFlags()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.snapshots, config.Snapshots)
+			assert.Equal(t, tt.format, config.OutputFormat)
+		})
+	}
+}
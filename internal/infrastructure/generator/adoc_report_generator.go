@@ -3,6 +3,7 @@ package generator
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -26,14 +27,100 @@ func (arg *AdocReportGenerator) GenerateReport(result *domain.AnalysisResult) {
 
 	defer file.Close()
 
-	writer := bufio.NewWriter(file)
+	if err := arg.WriteReport(file, result); err != nil {
+		fmt.Printf("Ошибка при записи отчёта: %s\n", err.Error())
+	}
+}
 
-	defer writer.Flush()
+// WriteReport пишет отчёт в формате AsciiDoc в w.
+func (arg *AdocReportGenerator) WriteReport(w io.Writer, result *domain.AnalysisResult) error {
+	writer := bufio.NewWriter(w)
 
 	arg.writeGeneralInfo(writer, result)
+	arg.writeClient(writer, result)
+	arg.writeUniqueVisitors(writer, result)
+	arg.writeTopClients(writer, result)
+	arg.writeAgentClasses(writer, result)
 	arg.writeRequestedResources(writer, result)
+	arg.writeEndpoints(writer, result)
+	arg.writeQuery(writer, result)
 	arg.writeResponseCodes(writer, result)
+	arg.writeTimeSeries(writer, result)
+	arg.writeLatencies(writer, result)
 	arg.writeAdditionalInfo(writer, result)
+	arg.writeFileFormats(writer, result)
+	arg.writeParseErrors(writer, result)
+
+	return writer.Flush()
+}
+
+// GenerateDiffReport пишет в report.adoc сравнение текущего периода с базовым (ngxstat diff).
+func (arg *AdocReportGenerator) GenerateDiffReport(comparison *domain.Comparison) {
+	file, err := arg.writer.CreateFile(arg.GetFilePath())
+	if err != nil {
+		arg.GenerateExceptionReport(arg.GetErrorFilePath(), "Ошибка записи в файл")
+		return
+	}
+
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+
+	defer writer.Flush()
+
+	arg.writePeriods(writer, comparison)
+	arg.writeMetricChanges(writer, comparison)
+
+	arg.writeLine(writer, arg.getRequestedResourcesHeader())
+	arg.writeCountChanges(writer, "Ресурс", comparison.Resources)
+	arg.writeLine(writer, arg.getResponseCodesHeader())
+	arg.writeCountChanges(writer, "Код", comparison.StatusCodes)
+	arg.writeLine(writer, arg.getRefereesHeader())
+	arg.writeCountChanges(writer, "Реферер", comparison.Referrers)
+}
+
+func (arg *AdocReportGenerator) writePeriods(writer *bufio.Writer, comparison *domain.Comparison) {
+	separator := "---------------------"
+
+	arg.writeLine(writer, arg.getPeriodsHeader())
+	arg.writeLine(writer, formatColumns("Период", "Файлов", "Начальная дата", "Конечная дата"))
+	arg.writeLine(writer, formatColumns(separator, separator, separator, separator))
+
+	for _, period := range []struct {
+		name   string
+		result *domain.AnalysisResult
+	}{{"Базовый", comparison.Base}, {"Текущий", comparison.Current}} {
+		arg.writeLine(writer, formatColumns(period.name, len(period.result.Filenames),
+			arg.emptyTimeConverter(period.result.From), arg.emptyTimeConverter(period.result.To)))
+	}
+
+	arg.writeLine(writer, "")
+}
+
+func (arg *AdocReportGenerator) writeMetricChanges(writer *bufio.Writer, comparison *domain.Comparison) {
+	separator := "---------------------"
+
+	arg.writeLine(writer, arg.getGeneralInfoHeader())
+	arg.writeLine(writer, formatColumns("Метрика", "Базовый", "Текущий", "Разница", "Изменение"))
+	arg.writeLine(writer, formatColumns(separator, separator, separator, separator, separator))
+	arg.writeLine(writer, formatColumns(changeRow("Количество запросов", comparison.TotalRequests)...))
+	arg.writeLine(writer, formatColumns(changeRow("Кол-во отказов (5xx)", comparison.ServerErrors)...))
+	arg.writeLine(writer, formatColumns(changeRow("Средний размер ответа", comparison.AverageResponseSize)...))
+	arg.writeLine(writer, formatColumns(changeRow("95p размера ответа", comparison.Percentile95ResponseSize)...))
+	arg.writeLine(writer, "")
+}
+
+func (arg *AdocReportGenerator) writeCountChanges(writer *bufio.Writer, name string, changes []domain.CountChange) {
+	separator := "---------------------"
+
+	arg.writeLine(writer, formatColumns(name, "Базовый", "Текущий", "Разница", "Изменение"))
+	arg.writeLine(writer, formatColumns(separator, separator, separator, separator, separator))
+
+	for _, row := range countChangeRows(changes, map[domain.EntryStatus]string{domain.EntryNew: "новое", domain.EntryGone: "выпало"}) {
+		arg.writeLine(writer, formatColumns(row...))
+	}
+
+	arg.writeLine(writer, "")
 }
 
 func (arg *AdocReportGenerator) writeGeneralInfo(writer *bufio.Writer, result *domain.AnalysisResult) {
@@ -42,23 +129,133 @@ func (arg *AdocReportGenerator) writeGeneralInfo(writer *bufio.Writer, result *d
 	arg.writeLine(writer, arg.formatLine("---------------------", "---------------------"))
 
 	arg.writeLine(writer, arg.formatLine("Количество файлов", len(result.Filenames)))
-	arg.writeLine(writer, arg.getListFiles(result.Filenames))
+
+	// Если фильтры не оставили ни одной записи, файлов в результате нет.
+	if len(result.Filenames) > 0 {
+		arg.writeLine(writer, arg.getListFiles(result.Filenames))
+	}
 
 	arg.writeLine(writer, arg.formatLine("Начальная дата", arg.emptyTimeConverter(result.From)))
 	arg.writeLine(writer, arg.formatLine("Конечная дата", arg.emptyTimeConverter(result.To)))
 	arg.writeLine(writer, arg.formatLine("Количество запросов", result.TotalRequests))
 	arg.writeLine(writer, arg.formatLine("Средний размер ответа", result.AverageResponseSize))
 	arg.writeLine(writer, arg.formatLine("95p размера ответа", result.Percentile95ResponseSize))
+
+	if result.ApproxTop != nil {
+		arg.writeLine(writer, arg.formatLine("Ёмкость прибл. топа", result.ApproxTop.Capacity))
+	}
+
+	arg.writeLine(writer, "")
+}
+
+// writeClient пишет сводку по клиенту отчёта --ip.
+func (arg *AdocReportGenerator) writeClient(writer *bufio.Writer, result *domain.AnalysisResult) {
+	client := result.Client
+	if client == nil {
+		return
+	}
+
+	arg.writeLine(writer, arg.getClientHeader())
+	arg.writeLine(writer, arg.formatLine("Метрика", "Значение"))
+	arg.writeLine(writer, arg.formatLine("---------------------", "---------------------"))
+	arg.writeLine(writer, arg.formatLine("IP-адрес", client.IP))
+	arg.writeLine(writer, arg.formatLine("Первый запрос", arg.emptyTimeConverter(client.FirstRequest)))
+	arg.writeLine(writer, arg.formatLine("Последний запрос", arg.emptyTimeConverter(client.LastRequest)))
+	arg.writeLine(writer, arg.formatLine("Количество запросов", client.Requests))
+	arg.writeLine(writer, arg.formatLine("Байт отправлено", client.Bytes))
+	arg.writeLine(writer, arg.formatLine("Ошибки клиента (4xx)", client.ClientErrors))
+	arg.writeLine(writer, arg.formatLine("Кол-во отказов (5xx)", client.ServerErrors))
+	arg.writeLine(writer, arg.formatLine("Доля ошибок", formatRate(client.ErrorRate())))
+	arg.writeLine(writer, "")
+}
+
+// writeTopClients пишет топ клиентов; в отчёте --ip он не нужен.
+func (arg *AdocReportGenerator) writeTopClients(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.Client != nil || len(result.TopClients) == 0 {
+		return
+	}
+
+	separator := "---------------------"
+
+	arg.writeLine(writer, arg.getTopClientsHeader())
+	arg.writeLine(writer, formatColumns("IP-адрес", "Запросы", "Байты", "Доля ошибок", "Частые ресурсы"))
+	arg.writeLine(writer, formatColumns(separator, separator, separator, separator, separator))
+
+	for _, row := range topClientRows(result.TopClients) {
+		arg.writeLine(writer, formatColumns(row...))
+	}
+
+	arg.writeLine(writer, "")
+}
+
+func (arg *AdocReportGenerator) writeAgentClasses(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if len(result.AgentClasses.Devices) == 0 {
+		return
+	}
+
+	separator := "---------------------"
+
+	arg.writeLine(writer, arg.getAgentClassesHeader())
+	arg.writeLine(writer, formatColumns("Вид", "Значение", "Запросы", "Доля"))
+	arg.writeLine(writer, formatColumns(separator, separator, separator, separator))
+
+	for _, row := range agentClassRows(result, [3]string{"Устройство", "Семейство", "ОС"}) {
+		arg.writeLine(writer, formatColumns(row...))
+	}
+
 	arg.writeLine(writer, "")
 }
 
 func (arg *AdocReportGenerator) writeRequestedResources(writer *bufio.Writer, result *domain.AnalysisResult) {
 	arg.writeLine(writer, arg.getRequestedResourcesHeader())
-	arg.writeLine(writer, arg.formatLine("Ресурс", "Количество"))
-	arg.writeLine(writer, arg.formatLine("---------------------", "---------------------"))
+	arg.writeCounts(writer, "Ресурс", result.MostRequestedResources, resourceErrors(result))
+}
 
-	for key, value := range result.MostRequestedResources {
-		arg.writeLine(writer, arg.formatLine(key, value))
+func (arg *AdocReportGenerator) writeEndpoints(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if len(result.MostRequestedEndpoints) == 0 {
+		return
+	}
+
+	arg.writeLine(writer, arg.getEndpointsHeader())
+	arg.writeCounts(writer, "Шаблон", result.MostRequestedEndpoints, endpointErrors(result))
+}
+
+// writeQuery пишет топ путей без query string, имён параметров и значений параметра --query-param.
+func (arg *AdocReportGenerator) writeQuery(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if len(result.MostRequestedPaths) > 0 {
+		arg.writeLine(writer, arg.getPathsHeader())
+		arg.writeCounts(writer, "Путь", result.MostRequestedPaths, pathErrors(result))
+	}
+
+	if len(result.MostFrequentQueryParams) > 0 {
+		arg.writeLine(writer, arg.getQueryParamsHeader())
+		arg.writeCounts(writer, "Параметр", result.MostFrequentQueryParams, queryParamErrors(result))
+	}
+
+	if result.QueryParam != "" {
+		arg.writeLine(writer, arg.getQueryValuesHeader(result.QueryParam))
+		arg.writeCounts(writer, "Значение", result.QueryParamValues[result.QueryParam], queryValueErrors(result))
+	}
+}
+
+// writeCounts пишет таблицу топа; для приближённого топа добавляется колонка погрешности.
+func (arg *AdocReportGenerator) writeCounts(writer *bufio.Writer, name string, counts, countErrors map[string]int64) {
+	separator := "---------------------"
+
+	if countErrors == nil {
+		arg.writeLine(writer, arg.formatLine(name, "Количество"))
+		arg.writeLine(writer, arg.formatLine(separator, separator))
+
+		for key, value := range counts {
+			arg.writeLine(writer, arg.formatLine(key, value))
+		}
+	} else {
+		arg.writeLine(writer, formatColumns(name, "Количество", "Погрешность"))
+		arg.writeLine(writer, formatColumns(separator, separator, separator))
+
+		for key, value := range counts {
+			arg.writeLine(writer, formatColumns(key, value, fmt.Sprintf("±%d", countErrors[key])))
+		}
 	}
 
 	arg.writeLine(writer, "")
@@ -76,6 +273,73 @@ func (arg *AdocReportGenerator) writeResponseCodes(writer *bufio.Writer, result
 	arg.writeLine(writer, "")
 }
 
+func (arg *AdocReportGenerator) writeUniqueVisitors(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.UniqueVisitors.IPs.Estimate == 0 && result.UniqueVisitors.RemoteUsers.Estimate == 0 {
+		return
+	}
+
+	arg.writeLine(writer, arg.getUniqueVisitorsHeader())
+	arg.writeLine(writer, formatColumns("Метрика", "Оценка", "Ст. ошибка"))
+	arg.writeLine(writer, formatColumns("---------------------", "---------------------", "---------------------"))
+
+	for _, row := range uniqueVisitorRows(result.UniqueVisitors) {
+		arg.writeLine(writer, formatColumns(row...))
+	}
+
+	arg.writeLine(writer, "")
+}
+
+func (arg *AdocReportGenerator) writeTimeSeries(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if !showTimeSeries(result) {
+		return
+	}
+
+	series := result.TimeSeries
+
+	separator := "---------------------"
+
+	arg.writeLine(writer, arg.getTimeSeriesHeader())
+	arg.writeLine(writer, formatColumns("Начало (UTC, "+formatWidth(series.Width)+")", "Запросы", "Байты", "2xx", "3xx", "4xx", "5xx"))
+	arg.writeLine(writer, formatColumns(separator, separator, separator, separator, separator, separator, separator))
+
+	for _, row := range timeSeriesRows(series) {
+		arg.writeLine(writer, formatColumns(row...))
+	}
+
+	arg.writeLine(writer, "")
+}
+
+func (arg *AdocReportGenerator) writeLatencies(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.RequestLatency.Count == 0 && result.UpstreamLatency.Count == 0 {
+		return
+	}
+
+	arg.writeLine(writer, arg.getLatenciesHeader())
+	arg.writeLine(writer, formatColumns("Перцентиль", "Запрос", "Upstream"))
+	arg.writeLine(writer, formatColumns("---------------------", "---------------------", "---------------------"))
+
+	for _, row := range latencyRows(result.RequestLatency, result.UpstreamLatency) {
+		arg.writeLine(writer, formatColumns(row...))
+	}
+
+	arg.writeLine(writer, "")
+
+	if len(result.ResourceLatencies) == 0 {
+		return
+	}
+
+	arg.writeLine(writer, arg.getResourceLatenciesHeader())
+	arg.writeLine(writer, formatColumns("Ресурс", "p50", "p95", "p99", "Макс."))
+	arg.writeLine(writer, formatColumns("---------------------", "---------------------", "---------------------",
+		"---------------------", "---------------------"))
+
+	for resource, latency := range result.ResourceLatencies {
+		arg.writeLine(writer, formatColumns(resource, latency.P50, latency.P95, latency.P99, latency.Max))
+	}
+
+	arg.writeLine(writer, "")
+}
+
 func (arg *AdocReportGenerator) writeAdditionalInfo(writer *bufio.Writer, result *domain.AnalysisResult) {
 	arg.writeLine(writer, arg.getAdditionalInfoHeader())
 	arg.writeLine(writer, arg.formatLine("Метрика", "Значение"))
@@ -88,13 +352,49 @@ func (arg *AdocReportGenerator) writeAdditionalInfo(writer *bufio.Writer, result
 
 func (arg *AdocReportGenerator) writeMostFrequentReferrers(writer *bufio.Writer, result *domain.AnalysisResult) {
 	arg.writeLine(writer, arg.getRefereesHeader())
-	arg.writeLine(writer, arg.formatLine("Реферер", "Количество"))
+	arg.writeCounts(writer, "Реферер", result.MostFrequentReferrers, referrerErrors(result))
+}
+
+func (arg *AdocReportGenerator) writeFileFormats(writer *bufio.Writer, result *domain.AnalysisResult) {
+	rows := fileFormatRows(result)
+	if len(rows) == 0 {
+		return
+	}
+
+	arg.writeLine(writer, arg.getFileFormatsHeader())
+	arg.writeLine(writer, formatColumns("Файл", "Формат", "Ошибок разбора"))
+	arg.writeLine(writer, formatColumns("---------------------", "---------------------", "---------------------"))
+
+	for _, row := range rows {
+		arg.writeLine(writer, formatColumns(row...))
+	}
+
+	arg.writeLine(writer, "")
+}
+
+func (arg *AdocReportGenerator) writeParseErrors(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.ParseErrors.Total == 0 {
+		return
+	}
+
+	arg.writeLine(writer, arg.getParseErrorsHeader())
+	arg.writeLine(writer, arg.formatLine("Метрика", "Значение"))
 	arg.writeLine(writer, arg.formatLine("---------------------", "---------------------"))
+	arg.writeLine(writer, arg.formatLine("Прочитано строк", result.TotalLines))
+	arg.writeLine(writer, arg.formatLine("Не разобрано строк", result.ParseErrors.Total))
+	arg.writeLine(writer, arg.formatLine("Доля ошибок", formatRate(result.ParseErrorRate())))
 
-	for key, value := range result.MostFrequentReferrers {
-		arg.writeLine(writer, arg.formatLine(key, value))
+	for _, kind := range sortedKeys(result.ParseErrors.ByKind) {
+		arg.writeLine(writer, arg.formatLine("Тип: "+kind, result.ParseErrors.ByKind[kind]))
+	}
+
+	arg.writeLine(writer, "----")
+
+	for _, sample := range result.ParseErrors.Samples {
+		arg.writeLine(writer, formatParseErrorSample(sample))
 	}
 
+	arg.writeLine(writer, "----")
 	arg.writeLine(writer, "")
 }
 
@@ -148,10 +448,30 @@ func (arg *AdocReportGenerator) GetErrorFilePath() string {
 func (arg *AdocReportGenerator) getGeneralInfoHeader() string {
 	return "=== Общая информация\n\n"
 }
+func (arg *AdocReportGenerator) getPeriodsHeader() string {
+	return "=== Сравниваемые периоды\n\n"
+}
+
 func (arg *AdocReportGenerator) getRequestedResourcesHeader() string {
 	return "=== Запрашиваемые ресурсы\n\n"
 }
 
+func (arg *AdocReportGenerator) getEndpointsHeader() string {
+	return "=== Шаблоны запросов\n\n"
+}
+
+func (arg *AdocReportGenerator) getPathsHeader() string {
+	return "=== Пути запросов\n\n"
+}
+
+func (arg *AdocReportGenerator) getQueryParamsHeader() string {
+	return "=== Параметры запроса\n\n"
+}
+
+func (arg *AdocReportGenerator) getQueryValuesHeader(name string) string {
+	return "=== Значения параметра " + name + "\n\n"
+}
+
 func (arg *AdocReportGenerator) getResponseCodesHeader() string {
 	return "=== Коды ответа\n\n"
 }
@@ -164,6 +484,42 @@ func (arg *AdocReportGenerator) getRefereesHeader() string {
 	return "=== Ссылающиеся ресурсы\n\n"
 }
 
+func (arg *AdocReportGenerator) getUniqueVisitorsHeader() string {
+	return "=== Уникальные клиенты\n\n"
+}
+
+func (arg *AdocReportGenerator) getClientHeader() string {
+	return "=== Клиент\n\n"
+}
+
+func (arg *AdocReportGenerator) getTopClientsHeader() string {
+	return "=== Активные клиенты\n\n"
+}
+
+func (arg *AdocReportGenerator) getAgentClassesHeader() string {
+	return "=== Клиентские приложения\n\n"
+}
+
+func (arg *AdocReportGenerator) getTimeSeriesHeader() string {
+	return "=== Запросы по времени\n\n"
+}
+
+func (arg *AdocReportGenerator) getLatenciesHeader() string {
+	return "=== Время ответа\n\n"
+}
+
+func (arg *AdocReportGenerator) getResourceLatenciesHeader() string {
+	return "=== Время ответа по ресурсам\n\n"
+}
+
+func (arg *AdocReportGenerator) getFileFormatsHeader() string {
+	return "=== Форматы файлов\n\n"
+}
+
+func (arg *AdocReportGenerator) getParseErrorsHeader() string {
+	return "=== Ошибки разбора\n\n"
+}
+
 func (arg *AdocReportGenerator) getAdditionalInfoHeader() string {
 	return "=== Доп. метрики: \n\n"
 }
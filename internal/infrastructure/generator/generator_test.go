@@ -1,7 +1,9 @@
 package generator_test
 
 import (
+	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 
 	"github.com/4domm/ngxstat/internal/domain"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMarkdownReportGenerator_GenerateReport(t *testing.T) {
@@ -30,6 +33,12 @@ func TestMarkdownReportGenerator_GenerateReport(t *testing.T) {
 		TotalServerErrorsLogs:    2,
 		From:                     parseTestTime("2023-01-01T00:00:00+0000"),
 		To:                       parseTestTime("2023-01-01T23:59:59+0000"),
+		RequestLatency:           domain.LatencyPercentiles{Count: 10, P50: 20 * time.Millisecond, P99: time.Second},
+		ResourceLatencies:        map[string]domain.LatencyPercentiles{"/index.html": {Count: 5, P95: 150 * time.Millisecond}},
+		UniqueVisitors:           domain.UniqueVisitors{IPs: domain.Cardinality{Estimate: 4, RelativeError: 0.0081}},
+		TimeSeries: domain.TimeSeries{Width: time.Hour, Explicit: true, Buckets: []domain.TimeBucket{
+			{Start: parseTestTime("2023-01-01T00:00:00+0000"), Requests: 10, Bytes: 5000, Status2xx: 8, Status5xx: 2},
+		}},
 	}
 
 	reportGenerator.GenerateReport(result)
@@ -64,6 +73,17 @@ func TestMarkdownReportGenerator_GenerateReport(t *testing.T) {
 	assertContains(t, resStr, "### Ссылающиеся ресурсы")
 	assertContains(t, resStr, "| Referrer              |                 Count |")
 	assertContains(t, resStr, "| https://example.com   |                     7 |")
+	assertContains(t, resStr, "#### Время ответа")
+	assertContains(t, resStr, "| p50                   |                  20ms |                    0s |")
+	assertContains(t, resStr, "| p99                   |                    1s |                    0s |")
+	assertContains(t, resStr, "| /index.html           |                    0s |                 150ms |                    0s |")
+	assertContains(t, resStr, "#### Уникальные клиенты")
+	assertContains(t, resStr, "| IP                    |                     4 |                ±0.81% |")
+	assertContains(t, resStr, "| Remote User           |                     0 |                ±0.00% |")
+	assertContains(t, resStr, "#### Запросы по времени")
+	assertContains(t, resStr, "| Start (UTC, 1h)       |              Requests |                 Bytes |                   2xx |")
+	assertContains(t, resStr, "| 2023-01-01 00:00      |                    10 |                  5000 |                     8 |"+
+		"                     0 |                     0 |                     2 |")
 }
 
 func TestAdocReportGenerator_GenerateReport(t *testing.T) {
@@ -84,6 +104,18 @@ func TestAdocReportGenerator_GenerateReport(t *testing.T) {
 		TotalServerErrorsLogs:    2,
 		From:                     parseTestTime("2023-01-01T00:00:00+0000"),
 		To:                       parseTestTime("2023-01-01T23:59:59+0000"),
+		FileFormats:              map[string]map[string]int64{"file1.log": {"combined": 9, "nginx": 1}},
+		TotalLines:               14,
+		UniqueVisitors:           domain.UniqueVisitors{RemoteUsers: domain.Cardinality{Estimate: 3, RelativeError: 0.0081}},
+		TimeSeries: domain.TimeSeries{Width: 24 * time.Hour, Explicit: true, Buckets: []domain.TimeBucket{
+			{Start: parseTestTime("2023-01-01T00:00:00+0000"), Requests: 10, Bytes: 5000, Status2xx: 8, Status5xx: 2},
+		}},
+		ParseErrors: domain.ParseErrors{
+			Total:   4,
+			ByFile:  map[string]int64{"file2.log": 4},
+			ByKind:  map[string]int64{"format": 3, "data": 1},
+			Samples: []domain.ParseErrorSample{{Filename: "file2.log", LineNumber: 7, Line: "garbage", Kind: "format"}},
+		},
 	}
 
 	reportGenerator.GenerateReport(result)
@@ -118,6 +150,387 @@ func TestAdocReportGenerator_GenerateReport(t *testing.T) {
 	assertContains(t, resStr, "=== Ссылающиеся ресурсы")
 	assertContains(t, resStr, "| Реферер               |            Количество |")
 	assertContains(t, resStr, "| https://example.com   |                     7 |")
+	assertContains(t, resStr, "=== Форматы файлов")
+	assertContains(t, resStr, "| file1.log             | combined (9), nginx (1) |                     0 |")
+	assertContains(t, resStr, "| file2.log             |                     - |                     4 |")
+	assertContains(t, resStr, "=== Ошибки разбора")
+	assertContains(t, resStr, "| Прочитано строк       |                    14 |")
+	assertContains(t, resStr, "| Доля ошибок           |                28.57% |")
+	assertContains(t, resStr, "| Тип: format           |                     3 |")
+	assertContains(t, resStr, "----\nfile2.log:7 [format]: garbage\n----")
+	assertContains(t, resStr, "=== Уникальные клиенты")
+	assertContains(t, resStr, "| Remote User           |                     3 |                ±0.81% |")
+	assertContains(t, resStr, "=== Запросы по времени")
+	assertContains(t, resStr, "| Начало (UTC, 1d)      |               Запросы |                 Байты |")
+}
+
+func TestJSONReportGenerator_GenerateReport(t *testing.T) {
+	reportGenerator := generator.NewJSONReportGenerator(generator.FileWriter{})
+	defer os.Remove(reportGenerator.GetFilePath())
+
+	result := &domain.AnalysisResult{
+		Filenames:               []string{"file1.log"},
+		TotalRequests:           10,
+		MostRequestedResources:  map[string]int64{"/index.html": 5, "/about.html": 3, "/contact.html": 3},
+		MostFrequentStatusCodes: map[string]int64{"200": 8, "500": 2},
+		From:                    parseTestTime("2023-01-01T00:00:00+0000"),
+		RequestLatency:          domain.LatencyPercentiles{Count: 10, P50: 1500 * time.Microsecond},
+		TimeSeries: domain.TimeSeries{Width: 5 * time.Minute, Buckets: []domain.TimeBucket{
+			{Start: parseTestTime("2023-01-01T00:00:00+0000"), Requests: 10, Bytes: 5000, Status2xx: 8, Status5xx: 2},
+		}},
+		ResourceLatencies: map[string]domain.LatencyPercentiles{"/about.html": {Count: 3, P95: 150 * time.Millisecond}},
+		TotalLines:        12,
+		UniqueVisitors:    domain.UniqueVisitors{IPs: domain.Cardinality{Estimate: 7, RelativeError: 0.0081}},
+		ParseErrors: domain.ParseErrors{
+			Total:   2,
+			ByFile:  map[string]int64{"file1.log": 2},
+			ByKind:  map[string]int64{"format": 2},
+			Samples: []domain.ParseErrorSample{{Filename: "file1.log", LineNumber: 7, Line: "garbage", Kind: "format"}},
+		},
+	}
+
+	reportGenerator.GenerateReport(result)
+
+	output, err := os.ReadFile(reportGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	var report generator.JSONReport
+	require.NoError(t, json.Unmarshal(output, &report))
+
+	assert.Equal(t, generator.JSONSchemaVersion, report.SchemaVersion)
+	assert.Equal(t, []string{"file1.log"}, report.Files)
+	assert.Equal(t, int64(10), report.Requests.Total)
+	assert.Equal(t, []generator.JSONCount{
+		{Value: "/index.html", Count: 5}, {Value: "/about.html", Count: 3}, {Value: "/contact.html", Count: 3},
+	}, report.TopResources)
+	assert.Equal(t, []generator.JSONCount{{Value: "200", Count: 8}, {Value: "500", Count: 2}}, report.TopStatuses)
+	assert.Empty(t, report.TopReferrers)
+	assert.True(t, result.From.Equal(*report.TimeRange.From))
+	assert.Nil(t, report.TimeRange.To)
+	assert.InDelta(t, 1.5, report.Latency.Request.P50, 1e-9)
+	require.Len(t, report.Latency.Resources, 1)
+	assert.Equal(t, "/about.html", report.Latency.Resources[0].Resource)
+	assert.InDelta(t, 150.0, report.Latency.Resources[0].P95, 1e-9)
+	assert.Equal(t, []generator.JSONFileFormat{{File: "file1.log", Formats: map[string]int64{}, FailedLines: 2}}, report.FileFormats)
+	assert.InDelta(t, 2.0/12, report.ParseErrors.ErrorRate, 1e-9)
+	assert.Equal(t, []generator.JSONErrorSample{
+		{File: "file1.log", LineNumber: 7, Kind: "format", Line: "garbage"},
+	}, report.ParseErrors.Samples)
+
+	assert.Equal(t, int64(300), report.TimeSeries.BucketSeconds)
+	require.Len(t, report.TimeSeries.Buckets, 1)
+	assert.Equal(t, int64(2), report.TimeSeries.Buckets[0].Status5xx)
+
+	assert.Equal(t, generator.JSONCardinality{Estimate: 7, RelativeError: 0.0081}, report.Visitors.IPs)
+
+	assertContains(t, string(output), `"p50_ms": 1.5`)
+	assertContains(t, string(output), `"top_referrers": []`)
+}
+
+func TestReportGenerators_ApproxTop(t *testing.T) {
+	result := &domain.AnalysisResult{
+		Filenames:              []string{"access.log"},
+		TotalRequests:          10,
+		MostRequestedResources: map[string]int64{"/index.html": 7},
+		MostFrequentReferrers:  map[string]int64{"https://example.com": 4},
+		ApproxTop: &domain.ApproxTop{
+			Capacity:       100,
+			ResourceErrors: map[string]int64{"/index.html": 2},
+			ReferrerErrors: map[string]int64{"https://example.com": 0},
+		},
+	}
+
+	markdownGenerator := generator.NewMarkdownReportGenerator(generator.FileWriter{})
+	defer os.Remove(markdownGenerator.GetFilePath())
+
+	markdownGenerator.GenerateReport(result)
+
+	output, err := os.ReadFile(markdownGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	assertContains(t, string(output), "| Approx. Top Capacity  |                   100 |")
+	assertContains(t, string(output), "| Resource              |                 Count |                 Error |")
+	assertContains(t, string(output), "| /index.html           |                     7 |                    ±2 |")
+	assertContains(t, string(output), "| https://example.com   |                     4 |                    ±0 |")
+
+	report := generator.NewJSONReport(result, time.Now())
+	resourceError, referrerError := int64(2), int64(0)
+
+	assert.Equal(t, &generator.JSONApproxTop{Capacity: 100}, report.ApproxTop)
+	assert.Equal(t, []generator.JSONCount{{Value: "/index.html", Count: 7, Error: &resourceError}}, report.TopResources)
+	assert.Equal(t, []generator.JSONCount{{Value: "https://example.com", Count: 4, Error: &referrerError}}, report.TopReferrers)
+	assert.Nil(t, generator.NewJSONReport(&domain.AnalysisResult{}, time.Now()).ApproxTop)
+}
+
+func TestReportGenerators_Clients(t *testing.T) {
+	start := time.Date(2023, time.October, 10, 13, 55, 0, 0, time.UTC)
+	abuser := domain.Client{IP: "10.0.0.1", ClientStats: domain.ClientStats{
+		Requests: 4, Bytes: 100, ClientErrors: 2, ServerErrors: 1, FirstRequest: start, LastRequest: start.Add(3 * time.Minute),
+		Resources: map[string]int64{"/login": 2, "/admin": 1},
+	}}
+	result := &domain.AnalysisResult{
+		Filenames:  []string{"access.log"},
+		TopClients: []domain.Client{abuser, {IP: "10.0.0.2", ClientStats: domain.ClientStats{Requests: 2, Bytes: 200}}},
+		TimeSeries: domain.TimeSeries{Width: time.Minute, Buckets: []domain.TimeBucket{{Start: start, Requests: 4}}},
+	}
+
+	markdownGenerator := generator.NewMarkdownReportGenerator(generator.FileWriter{})
+	defer os.Remove(markdownGenerator.GetFilePath())
+
+	markdownGenerator.GenerateReport(result)
+
+	output, err := os.ReadFile(markdownGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	compact := strings.Join(strings.Fields(string(output)), " ")
+	assertContains(t, compact, "| 10.0.0.1 | 4 | 100 | 75.00% | /login (2), /admin (1) |")
+	assertContains(t, compact, "| 10.0.0.2 | 2 | 200 | 0.00% | - |")
+	assert.NotContains(t, compact, "Клиентские приложения")
+	assert.NotContains(t, compact, "Запросы по времени")
+
+	// В отчёте --ip вместо топа клиентов — сводка по адресу, а ряд выводится и без --bucket.
+	result.Client = &abuser
+	markdownGenerator.GenerateReport(result)
+
+	output, err = os.ReadFile(markdownGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	compact = strings.Join(strings.Fields(string(output)), " ")
+	assertContains(t, compact, "| First Request | 10/Oct/2023:13:55:00 +0000 |")
+	assertContains(t, compact, "| Error Rate | 75.00% |")
+	assertContains(t, compact, "Запросы по времени")
+	assert.NotContains(t, compact, "Активные клиенты")
+
+	report := generator.NewJSONReport(result, time.Now())
+	require.NotNil(t, report.Client)
+	assert.Equal(t, "10.0.0.1", report.Client.IP)
+	assert.InDelta(t, 0.75, report.Client.ErrorRate, 1e-9)
+	assert.Equal(t, []generator.JSONCount{{Value: "/login", Count: 2}, {Value: "/admin", Count: 1}}, report.Client.TopResources)
+	assert.Len(t, report.TopClients, 2)
+	assert.Nil(t, generator.NewJSONReport(&domain.AnalysisResult{}, time.Now()).Client)
+}
+
+func TestReportGenerators_AgentClasses(t *testing.T) {
+	result := &domain.AnalysisResult{
+		Filenames:     []string{"access.log"},
+		TotalRequests: 4,
+		AgentClasses: domain.AgentClasses{
+			Devices:  map[string]int64{"bot": 3, "desktop": 1},
+			Families: map[string]int64{"Googlebot": 3, "Chrome": 1},
+			OSes:     map[string]int64{"Other": 3, "Windows": 1},
+		},
+	}
+
+	adocGenerator := generator.NewAdocReportGenerator(generator.FileWriter{})
+	defer os.Remove(adocGenerator.GetFilePath())
+
+	adocGenerator.GenerateReport(result)
+
+	output, err := os.ReadFile(adocGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	compact := strings.Join(strings.Fields(string(output)), " ")
+	assertContains(t, compact, "| Устройство | bot | 3 | 75.00% | | Устройство | desktop | 1 | 25.00% |")
+	assertContains(t, compact, "| Семейство | Googlebot | 3 | 75.00% |")
+	assertContains(t, compact, "| ОС | Windows | 1 | 25.00% |")
+
+	report := generator.NewJSONReport(result, time.Now())
+	assert.Equal(t, []generator.JSONCount{{Value: "bot", Count: 3}, {Value: "desktop", Count: 1}}, report.AgentClasses.Devices)
+}
+
+func TestReportGenerators_Endpoints(t *testing.T) {
+	result := &domain.AnalysisResult{
+		Filenames:              []string{"access.log"},
+		TotalRequests:          5,
+		MostRequestedEndpoints: map[string]int64{"/users/{id}": 4, "/": 1},
+	}
+
+	markdownGenerator := generator.NewMarkdownReportGenerator(generator.FileWriter{})
+	defer os.Remove(markdownGenerator.GetFilePath())
+
+	markdownGenerator.GenerateReport(result)
+
+	output, err := os.ReadFile(markdownGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	assertContains(t, string(output), "#### Шаблоны запросов")
+	assertContains(t, string(output), "| /users/{id}           |                     4 |")
+
+	adocGenerator := generator.NewAdocReportGenerator(generator.FileWriter{})
+	defer os.Remove(adocGenerator.GetFilePath())
+
+	adocGenerator.GenerateReport(result)
+
+	output, err = os.ReadFile(adocGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	compact := strings.Join(strings.Fields(string(output)), " ")
+	assertContains(t, compact, "=== Шаблоны запросов")
+	assertContains(t, compact, "| /users/{id} | 4 |")
+
+	report := generator.NewJSONReport(result, time.Now())
+	assert.Equal(t, []generator.JSONCount{{Value: "/users/{id}", Count: 4}, {Value: "/", Count: 1}}, report.TopEndpoints)
+}
+
+func TestReportGenerators_Query(t *testing.T) {
+	result := &domain.AnalysisResult{
+		Filenames:               []string{"access.log"},
+		TotalRequests:           5,
+		MostRequestedPaths:      map[string]int64{"/search": 4},
+		MostFrequentQueryParams: map[string]int64{"utm_source": 3},
+		QueryParam:              "utm_source",
+		QueryParamValues:        map[string]map[string]int64{"utm_source": {"mail": 2, "ads": 1}},
+	}
+
+	markdownGenerator := generator.NewMarkdownReportGenerator(generator.FileWriter{})
+	defer os.Remove(markdownGenerator.GetFilePath())
+
+	markdownGenerator.GenerateReport(result)
+
+	output, err := os.ReadFile(markdownGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	assertContains(t, string(output), "#### Пути запросов")
+	assertContains(t, string(output), "| /search               |                     4 |")
+	assertContains(t, string(output), "| utm_source            |                     3 |")
+	assertContains(t, string(output), "#### Значения параметра utm_source")
+	assertContains(t, string(output), "| mail                  |                     2 |")
+
+	report := generator.NewJSONReport(result, time.Now())
+	assert.Equal(t, []generator.JSONCount{{Value: "/search", Count: 4}}, report.TopPaths)
+	assert.Equal(t, &generator.JSONQueryParam{
+		Name:   "utm_source",
+		Values: []generator.JSONCount{{Value: "mail", Count: 2}, {Value: "ads", Count: 1}},
+	}, report.QueryParam)
+
+	result.QueryParam = ""
+	assert.Nil(t, generator.NewJSONReport(result, time.Now()).QueryParam)
+}
+
+func TestReportGenerators_Diff(t *testing.T) {
+	comparison := domain.NewComparison(
+		&domain.AnalysisResult{
+			Filenames:                []string{"yesterday.log"},
+			TotalRequests:            200,
+			TotalServerErrorsLogs:    0,
+			AverageResponseSize:      100,
+			Percentile95ResponseSize: 400,
+			MostRequestedResources:   map[string]int64{"/index.html": 120, "/old": 50},
+			MostFrequentStatusCodes:  map[string]int64{"200": 200},
+		},
+		&domain.AnalysisResult{
+			Filenames:                []string{"today.log"},
+			TotalRequests:            250,
+			TotalServerErrorsLogs:    5,
+			AverageResponseSize:      90.5,
+			Percentile95ResponseSize: 400,
+			MostRequestedResources:   map[string]int64{"/index.html": 150, "/new": 60},
+			MostFrequentStatusCodes:  map[string]int64{"200": 245, "502": 5},
+		},
+		// Вне топа /new встречался и в базовом периоде, а /old — в текущем.
+		domain.TopCounts{
+			Resources:   map[string]int64{"/index.html": 120, "/old": 50, "/new": 10},
+			StatusCodes: map[string]int64{"200": 200},
+		},
+		domain.TopCounts{
+			Resources:   map[string]int64{"/index.html": 150, "/new": 60, "/old": 20},
+			StatusCodes: map[string]int64{"200": 245, "502": 5},
+		},
+	)
+
+	assert.Equal(t, []domain.CountChange{
+		{Value: "/index.html", Base: 120, Current: 150, Status: domain.EntryKept},
+		{Value: "/new", Base: 10, Current: 60, Status: domain.EntryNew},
+		{Value: "/old", Base: 50, Current: 20, Status: domain.EntryGone},
+	}, comparison.Resources)
+
+	markdownGenerator := generator.NewMarkdownReportGenerator(generator.FileWriter{})
+	defer os.Remove(markdownGenerator.GetFilePath())
+
+	markdownGenerator.GenerateDiffReport(comparison)
+
+	output, err := os.ReadFile(markdownGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	// Колонки выравниваются пробелами, сравниваются строки со схлопнутыми пробелами.
+	compact := strings.Join(strings.Fields(string(output)), " ")
+	assertContains(t, compact, "| Total Requests | 200 | 250 | +50 | +25.00% |")
+	assertContains(t, compact, "| Server Errors (5xx) | 0 | 5 | +5 | - |")
+	assertContains(t, compact, "| Average Response Size | 100 | 90.50 | -9.50 | -9.50% |")
+	assertContains(t, compact, "| /new | 10 | 60 | +50 | +500.00%, new |")
+	assertContains(t, compact, "| /old | 50 | 20 | -30 | -60.00%, gone |")
+	assertContains(t, compact, "| 502 | 0 | 5 | +5 | new |")
+
+	report := generator.NewJSONDiffReport(comparison, time.Now())
+	relativeChange := 0.25
+
+	assert.Equal(t, generator.JSONChange{Base: 200, Current: 250, Delta: 50, RelativeChange: &relativeChange}, report.Requests.Total)
+	assert.Nil(t, report.Requests.ServerErrors.RelativeChange)
+	assert.Equal(t, generator.JSONCountChange{Value: "/new", Base: 10, Current: 60, Status: "new"}, report.TopResources[1])
+	assert.Equal(t, []string{"yesterday.log"}, report.Base.Files)
+
+	htmlGenerator := generator.NewHTMLReportGenerator(generator.FileWriter{})
+	defer os.Remove(htmlGenerator.GetFilePath())
+
+	htmlGenerator.GenerateDiffReport(comparison)
+
+	output, err = os.ReadFile(htmlGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	assertContains(t, string(output), `<tr class="gone"><td>/old</td><td class="number">50</td>`)
+	assertContains(t, string(output), "<style>")
+}
+
+func TestJSONReportGenerator_GenerateExceptionReport(t *testing.T) {
+	reportGenerator := generator.NewJSONReportGenerator(generator.FileWriter{})
+	defer os.Remove(reportGenerator.GetErrorFilePath())
+
+	reportGenerator.GenerateExceptionReport(reportGenerator.GetErrorFilePath(), "no files")
+
+	output, err := os.ReadFile(reportGenerator.GetErrorFilePath())
+	require.NoError(t, err)
+
+	var report map[string]interface{}
+	require.NoError(t, json.Unmarshal(output, &report))
+
+	assert.Equal(t, map[string]interface{}{
+		"schema_version": float64(generator.JSONSchemaVersion),
+		"error":          map[string]interface{}{"message": "no files"},
+	}, report)
+}
+
+func TestHTMLReportGenerator_GenerateReport(t *testing.T) {
+	reportGenerator := generator.NewHTMLReportGenerator(generator.FileWriter{})
+	defer os.Remove(reportGenerator.GetFilePath())
+
+	start := parseTestTime("2023-01-01T00:00:00+0000")
+	result := &domain.AnalysisResult{
+		Filenames:               []string{"file1.log"},
+		TotalRequests:           10,
+		MostRequestedResources:  map[string]int64{"/index.html?q=<script>": 5, "/about.html": 3},
+		MostFrequentStatusCodes: map[string]int64{"200": 8},
+		TimeSeries: domain.TimeSeries{Width: time.Minute, Buckets: []domain.TimeBucket{
+			{Start: start, Requests: 4}, {Start: start.Add(time.Minute), Requests: 6},
+		}},
+		SizeDistribution: []domain.SizeBucket{{From: 512, To: 1023, Count: 7}, {From: 1024, To: 2047, Count: 3}},
+	}
+
+	reportGenerator.GenerateReport(result)
+
+	output, err := os.ReadFile(reportGenerator.GetFilePath())
+	require.NoError(t, err)
+
+	resStr := string(output)
+
+	assertContains(t, resStr, "<h2>Запросы по времени</h2>")
+	assertContains(t, resStr, "<title>2023-01-01 00:01:00 — 6, 5xx: 0</title>")
+	assertContains(t, resStr, "<h2>Коды ответа</h2>")
+	assertContains(t, resStr, "<title>200: 8</title>")
+	assertContains(t, resStr, "Прочие: 2 (20.00%)")
+	assertContains(t, resStr, "<title>/index.html?q=&lt;script&gt;: 5</title>")
+	assertContains(t, resStr, "<title>1 КиБ – 2 КиБ: 3</title>")
+	assert.NotContains(t, resStr, "<script")
+	assert.NotContains(t, resStr, "http")
 }
 
 func parseTestTime(value string) time.Time {
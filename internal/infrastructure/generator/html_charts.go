@@ -0,0 +1,316 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// Геометрия SVG-графиков HTML-отчёта. Координаты считаются здесь, шаблон только выводит их.
+const (
+	chartWidth       = 760.0
+	chartHeight      = 220.0
+	chartPaddingLeft = 56.0
+	chartPaddingTop  = 12.0
+	chartAxisHeight  = 24.0
+	pieRadius        = 90.0
+	barRowHeight     = 26.0
+	barLabelWidth    = 260.0
+	maxBarLabelRunes = 40
+)
+
+// chartPalette — цвета секторов и столбцов; повторяются по кругу.
+var chartPalette = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#9c755f"}
+
+type svgRect struct {
+	X, Y, Width, Height float64
+	Title               string
+}
+
+type svgLabel struct {
+	X, Y   float64
+	Text   string
+	Anchor string
+}
+
+// columnChart — столбчатая диаграмма: ряд по времени или распределение размеров ответа.
+type columnChart struct {
+	Width, Height float64
+	Bars          []svgRect
+	Labels        []svgLabel
+	Baseline      float64
+}
+
+type pieSlice struct {
+	Path    string
+	Color   string
+	Label   string
+	Percent string
+	// Full — единственный сектор на весь круг: дугу в 360° SVG не рисует, выводится окружность.
+	Full bool
+}
+
+type pieChart struct {
+	Size, Center, Radius float64
+	Slices               []pieSlice
+}
+
+type barRow struct {
+	Y, Width, LabelWidth, ValueX float64
+	Label, Title                 string
+	Value                        int64
+	Color                        string
+}
+
+// barChart — горизонтальная диаграмма топа ресурсов.
+type barChart struct {
+	Width, Height float64
+	Rows          []barRow
+}
+
+func newTimelineChart(series domain.TimeSeries) *columnChart {
+	if len(series.Buckets) == 0 {
+		return nil
+	}
+
+	values := make([]int64, len(series.Buckets))
+	titles := make([]string, len(series.Buckets))
+
+	for i, bucket := range series.Buckets {
+		values[i] = bucket.Requests
+		titles[i] = fmt.Sprintf("%s — %d, 5xx: %d", bucket.Start.Format(time.DateTime), bucket.Requests, bucket.Status5xx)
+	}
+
+	chart := newColumnChart(values, titles)
+
+	for _, i := range labelIndexes(len(series.Buckets)) {
+		chart.addAxisLabel(i, len(series.Buckets), series.Buckets[i].Start.Format(timeLabelLayout(series.Width)))
+	}
+
+	return chart
+}
+
+func newSizeChart(distribution []domain.SizeBucket) *columnChart {
+	if len(distribution) == 0 {
+		return nil
+	}
+
+	values := make([]int64, len(distribution))
+	titles := make([]string, len(distribution))
+
+	for i, bucket := range distribution {
+		values[i] = bucket.Count
+		titles[i] = fmt.Sprintf("%s – %s: %d", formatBytes(bucket.From), formatBytes(bucket.To), bucket.Count)
+	}
+
+	chart := newColumnChart(values, titles)
+
+	for _, i := range labelIndexes(len(distribution)) {
+		chart.addAxisLabel(i, len(distribution), formatBytes(distribution[i].From))
+	}
+
+	return chart
+}
+
+func newColumnChart(values []int64, titles []string) *columnChart {
+	chart := &columnChart{Width: chartWidth, Height: chartHeight + chartAxisHeight, Baseline: chartPaddingTop + chartHeight}
+	maxValue := int64(1)
+
+	for _, value := range values {
+		maxValue = max(maxValue, value)
+	}
+
+	step := (chartWidth - chartPaddingLeft) / float64(len(values))
+	gap := math.Min(2, step/4)
+
+	for i, value := range values {
+		height := chartHeight * float64(value) / float64(maxValue)
+		chart.Bars = append(chart.Bars, svgRect{
+			X:      chartPaddingLeft + float64(i)*step + gap/2,
+			Y:      chart.Baseline - height,
+			Width:  step - gap,
+			Height: height,
+			Title:  titles[i],
+		})
+	}
+
+	chart.Labels = append(chart.Labels,
+		svgLabel{X: chartPaddingLeft - 6, Y: chartPaddingTop + 10, Text: fmt.Sprint(maxValue), Anchor: "end"},
+		svgLabel{X: chartPaddingLeft - 6, Y: chart.Baseline, Text: "0", Anchor: "end"},
+	)
+
+	return chart
+}
+
+func (c *columnChart) addAxisLabel(index, count int, text string) {
+	step := (chartWidth - chartPaddingLeft) / float64(count)
+	c.Labels = append(c.Labels, svgLabel{
+		X:      chartPaddingLeft + (float64(index)+0.5)*step,
+		Y:      c.Baseline + chartAxisHeight - 6,
+		Text:   text,
+		Anchor: "middle",
+	})
+}
+
+// labelIndexes выбирает до пяти равномерно расположенных подписей оси.
+func labelIndexes(count int) []int {
+	const maxLabels = 5
+
+	if count <= maxLabels {
+		indexes := make([]int, count)
+		for i := range indexes {
+			indexes[i] = i
+		}
+
+		return indexes
+	}
+
+	indexes := make([]int, maxLabels)
+	for i := range indexes {
+		indexes[i] = i * (count - 1) / (maxLabels - 1)
+	}
+
+	return indexes
+}
+
+func timeLabelLayout(width time.Duration) string {
+	if width >= 24*time.Hour {
+		return time.DateOnly
+	}
+
+	return "02.01 15:04"
+}
+
+// newStatusChart строит круговую диаграмму по топу кодов ответа; остальные коды — отдельный сектор.
+func newStatusChart(result *domain.AnalysisResult) *pieChart {
+	counts := sortedCounts(result.MostFrequentStatusCodes)
+
+	var listed int64
+	for _, item := range counts {
+		listed += item.Count
+	}
+
+	if rest := result.TotalRequests - listed; rest > 0 {
+		counts = append(counts, JSONCount{Value: "Прочие", Count: rest})
+		listed += rest
+	}
+
+	if listed == 0 {
+		return nil
+	}
+
+	chart := &pieChart{Size: 2*pieRadius + 4, Center: pieRadius + 2, Radius: pieRadius}
+	angle := 0.0
+
+	for i, item := range counts {
+		share := float64(item.Count) / float64(listed)
+		next := angle + share*2*math.Pi
+
+		chart.Slices = append(chart.Slices, pieSlice{
+			Path:    arcPath(chart.Center, pieRadius, angle, next),
+			Color:   chartPalette[i%len(chartPalette)],
+			Label:   fmt.Sprintf("%s: %d", item.Value, item.Count),
+			Percent: formatRate(share),
+			Full:    item.Count == listed,
+		})
+
+		angle = next
+	}
+
+	return chart
+}
+
+// arcPath описывает сектор круга от угла from до to, отсчёт от вертикали по часовой стрелке.
+func arcPath(center, radius, from, to float64) string {
+	largeArc := 0
+	if to-from > math.Pi {
+		largeArc = 1
+	}
+
+	return fmt.Sprintf("M %.2f %.2f L %.2f %.2f A %.2f %.2f 0 %d 1 %.2f %.2f Z",
+		center, center,
+		center+radius*math.Sin(from), center-radius*math.Cos(from),
+		radius, radius, largeArc,
+		center+radius*math.Sin(to), center-radius*math.Cos(to))
+}
+
+func newResourcesChart(result *domain.AnalysisResult) *barChart {
+	return newBarChart(withErrors(sortedCounts(result.MostRequestedResources), resourceErrors(result)))
+}
+
+func newEndpointsChart(result *domain.AnalysisResult) *barChart {
+	return newBarChart(withErrors(sortedCounts(result.MostRequestedEndpoints), endpointErrors(result)))
+}
+
+func newPathsChart(result *domain.AnalysisResult) *barChart {
+	return newBarChart(withErrors(sortedCounts(result.MostRequestedPaths), pathErrors(result)))
+}
+
+func newQueryParamsChart(result *domain.AnalysisResult) *barChart {
+	return newBarChart(withErrors(sortedCounts(result.MostFrequentQueryParams), queryParamErrors(result)))
+}
+
+func newQueryValuesChart(result *domain.AnalysisResult) *barChart {
+	return newBarChart(withErrors(sortedCounts(result.QueryParamValues[result.QueryParam]), queryValueErrors(result)))
+}
+
+// newBarChart строит горизонтальную диаграмму топа; counts упорядочены по убыванию.
+func newBarChart(counts []JSONCount) *barChart {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	chart := &barChart{Width: chartWidth, Height: barRowHeight * float64(len(counts))}
+	scale := (chartWidth - barLabelWidth - 60) / float64(counts[0].Count)
+
+	for i, item := range counts {
+		width := math.Max(1, float64(item.Count)*scale)
+		title := item.Value
+
+		if item.Error != nil {
+			title += fmt.Sprintf(" (±%d)", *item.Error)
+		}
+
+		chart.Rows = append(chart.Rows, barRow{
+			Y:          float64(i) * barRowHeight,
+			Width:      width,
+			LabelWidth: barLabelWidth,
+			ValueX:     barLabelWidth + width,
+			Label:      shortenLabel(item.Value),
+			Title:      title,
+			Value:      item.Count,
+			Color:      chartPalette[0],
+		})
+	}
+
+	return chart
+}
+
+func shortenLabel(label string) string {
+	runes := []rune(label)
+	if len(runes) <= maxBarLabelRunes {
+		return label
+	}
+
+	return string(runes[:maxBarLabelRunes-1]) + "…"
+}
+
+func formatBytes(size int64) string {
+	units := []string{"Б", "КиБ", "МиБ", "ГиБ"}
+	value := float64(size)
+	unit := 0
+
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + " " + units[unit]
+}
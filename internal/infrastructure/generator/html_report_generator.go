@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+)
+
+//go:embed html_report.tmpl
+var htmlReportTemplate string
+
+//go:embed html_diff_report.tmpl
+var htmlDiffReportTemplate string
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlReportTemplate))
+
+// htmlDiffTemplate берёт стили из html_report.tmpl.
+var htmlDiffTemplate = template.Must(template.Must(htmlTemplate.Clone()).New("diff").Parse(htmlDiffReportTemplate))
+
+// HTMLReportGenerator пишет отчёт одним HTML-файлом без внешних ресурсов: стили встроены,
+// графики нарисованы в SVG, поэтому файл открывается офлайн.
+type HTMLReportGenerator struct {
+	writer FileWriter
+}
+
+// htmlReport — данные шаблона html_report.tmpl.
+type htmlReport struct {
+	GeneratedAt       string
+	Summary           [][2]interface{}
+	Files             []string
+	Client            [][2]interface{}
+	TopClients        [][]interface{}
+	AgentClasses      [][]interface{}
+	Timeline          *columnChart
+	TimelineWidth     string
+	Statuses          *pieChart
+	Resources         *barChart
+	Endpoints         *barChart
+	Paths             *barChart
+	QueryParams       *barChart
+	QueryParam        string
+	QueryValues       *barChart
+	Sizes             *columnChart
+	Latencies         [][]interface{}
+	ParseErrors       [][2]interface{}
+	ParseErrorSamples []string
+}
+
+// htmlDiffReport — данные шаблона html_diff_report.tmpl.
+type htmlDiffReport struct {
+	GeneratedAt string
+	Periods     [][]interface{}
+	Metrics     []htmlChangeRow
+	Resources   []htmlChangeRow
+	StatusCodes []htmlChangeRow
+	Referrers   []htmlChangeRow
+}
+
+// htmlChangeRow — строка таблицы сравнения; Status — CSS-класс строки для новых и выпавших значений.
+type htmlChangeRow struct {
+	Cells  []interface{}
+	Status string
+}
+
+func NewHTMLReportGenerator(writer FileWriter) *HTMLReportGenerator {
+	return &HTMLReportGenerator{writer: writer}
+}
+
+func (hrg HTMLReportGenerator) GenerateReport(result *domain.AnalysisResult) {
+	file, err := hrg.writer.CreateFile(hrg.GetFilePath())
+	if err != nil {
+		hrg.GenerateExceptionReport(hrg.GetErrorFilePath(), "Error writing to file")
+		return
+	}
+
+	defer file.Close()
+
+	if err := hrg.WriteReport(file, result); err != nil {
+		fmt.Printf("Error writing report: %s\n", err.Error())
+	}
+}
+
+// WriteReport заполняет шаблон отчёта и пишет страницу в w.
+func (hrg HTMLReportGenerator) WriteReport(w io.Writer, result *domain.AnalysisResult) error {
+	writer := bufio.NewWriter(w)
+
+	if err := htmlTemplate.Execute(writer, newHTMLReport(result, time.Now())); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func (hrg HTMLReportGenerator) GenerateDiffReport(comparison *domain.Comparison) {
+	file, err := hrg.writer.CreateFile(hrg.GetFilePath())
+	if err != nil {
+		hrg.GenerateExceptionReport(hrg.GetErrorFilePath(), "Error writing to file")
+		return
+	}
+
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+
+	defer writer.Flush()
+
+	if err := htmlDiffTemplate.Execute(writer, newHTMLDiffReport(comparison, time.Now())); err != nil {
+		fmt.Printf("Error writing report: %s\n", err.Error())
+	}
+}
+
+func newHTMLDiffReport(comparison *domain.Comparison, generatedAt time.Time) *htmlDiffReport {
+	return &htmlDiffReport{
+		GeneratedAt: generatedAt.Format(parser.NginxDateFormat),
+		Periods: [][]interface{}{
+			{"Базовый", len(comparison.Base.Filenames), formatOptionalTime(comparison.Base.From), formatOptionalTime(comparison.Base.To)},
+			{"Текущий", len(comparison.Current.Filenames), formatOptionalTime(comparison.Current.From), formatOptionalTime(comparison.Current.To)},
+		},
+		Metrics: []htmlChangeRow{
+			{Cells: changeRow("Количество запросов", comparison.TotalRequests)},
+			{Cells: changeRow("Кол-во отказов (5xx)", comparison.ServerErrors)},
+			{Cells: changeRow("Средний размер ответа", comparison.AverageResponseSize)},
+			{Cells: changeRow("95p размера ответа", comparison.Percentile95ResponseSize)},
+		},
+		Resources:   htmlCountChanges(comparison.Resources),
+		StatusCodes: htmlCountChanges(comparison.StatusCodes),
+		Referrers:   htmlCountChanges(comparison.Referrers),
+	}
+}
+
+func htmlCountChanges(changes []domain.CountChange) []htmlChangeRow {
+	cells := countChangeRows(changes, map[domain.EntryStatus]string{domain.EntryNew: "новое", domain.EntryGone: "выпало"})
+	rows := make([]htmlChangeRow, 0, len(changes))
+
+	for i, change := range changes {
+		row := htmlChangeRow{Cells: cells[i]}
+		if change.Status != domain.EntryKept {
+			row.Status = string(change.Status)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func newHTMLReport(result *domain.AnalysisResult, generatedAt time.Time) *htmlReport {
+	report := &htmlReport{
+		GeneratedAt: generatedAt.Format(parser.NginxDateFormat),
+		Summary: [][2]interface{}{
+			{"Количество файлов", len(result.Filenames)},
+			{"Начальная дата", formatOptionalTime(result.From)},
+			{"Конечная дата", formatOptionalTime(result.To)},
+			{"Количество запросов", result.TotalRequests},
+			{"Кол-во отказов (5xx)", result.TotalServerErrorsLogs},
+			{"Средний размер ответа", fmt.Sprintf("%.0f", result.AverageResponseSize)},
+			{"95p размера ответа", result.Percentile95ResponseSize},
+			{"Уникальных IP", formatCardinality(result.UniqueVisitors.IPs)},
+			{"Уникальных IP + User-Agent", formatCardinality(result.UniqueVisitors.IPUserAgents)},
+			{"Уникальных пользователей", formatCardinality(result.UniqueVisitors.RemoteUsers)},
+		},
+		Files:         result.Filenames,
+		Timeline:      newTimelineChart(result.TimeSeries),
+		TimelineWidth: formatWidth(result.TimeSeries.Width),
+		Statuses:      newStatusChart(result),
+		Resources:     newResourcesChart(result),
+		Endpoints:     newEndpointsChart(result),
+		Paths:         newPathsChart(result),
+		QueryParams:   newQueryParamsChart(result),
+		QueryParam:    result.QueryParam,
+		QueryValues:   newQueryValuesChart(result),
+		Sizes:         newSizeChart(result.SizeDistribution),
+		AgentClasses:  agentClassRows(result, [3]string{"Устройство", "Семейство", "ОС"}),
+	}
+
+	if client := result.Client; client != nil {
+		report.Client = [][2]interface{}{
+			{"IP-адрес", client.IP},
+			{"Первый запрос", formatOptionalTime(client.FirstRequest)},
+			{"Последний запрос", formatOptionalTime(client.LastRequest)},
+			{"Количество запросов", client.Requests},
+			{"Байт отправлено", client.Bytes},
+			{"Ошибки клиента (4xx)", client.ClientErrors},
+			{"Кол-во отказов (5xx)", client.ServerErrors},
+			{"Доля ошибок", formatRate(client.ErrorRate())},
+		}
+	} else {
+		report.TopClients = topClientRows(result.TopClients)
+	}
+
+	if result.ApproxTop != nil {
+		report.Summary = append(report.Summary, [2]interface{}{"Ёмкость приближённого топа", result.ApproxTop.Capacity})
+	}
+
+	if result.RequestLatency.Count > 0 || result.UpstreamLatency.Count > 0 {
+		report.Latencies = latencyRows(result.RequestLatency, result.UpstreamLatency)
+	}
+
+	if result.ParseErrors.Total > 0 {
+		report.ParseErrors = [][2]interface{}{
+			{"Прочитано строк", result.TotalLines},
+			{"Не разобрано строк", result.ParseErrors.Total},
+			{"Доля ошибок", formatRate(result.ParseErrorRate())},
+		}
+
+		for _, sample := range result.ParseErrors.Samples {
+			report.ParseErrorSamples = append(report.ParseErrorSamples, formatParseErrorSample(sample))
+		}
+	}
+
+	return report
+}
+
+func formatCardinality(cardinality domain.Cardinality) string {
+	return fmt.Sprintf("≈%d (±%s)", cardinality.Estimate, formatRate(cardinality.RelativeError))
+}
+
+func formatOptionalTime(value time.Time) string {
+	if value.IsZero() {
+		return "-"
+	}
+
+	return value.Format(parser.NginxDateFormat)
+}
+
+func (hrg HTMLReportGenerator) GenerateExceptionReport(filePath, message string) {
+	file, err := hrg.writer.CreateFile(filePath)
+	if err != nil {
+		fmt.Printf("Error writing exception report: %s\n", err.Error())
+		return
+	}
+	defer file.Close()
+
+	_, _ = fmt.Fprintf(file, "<!DOCTYPE html>\n<html lang=\"ru\">\n<meta charset=\"utf-8\">\n<h1>Произошла ошибка</h1>\n<p>%s</p>\n</html>\n",
+		template.HTMLEscapeString(message))
+}
+
+func (hrg HTMLReportGenerator) GetFilePath() string {
+	return "report.html"
+}
+
+func (hrg HTMLReportGenerator) GetErrorFilePath() string {
+	return "error.html"
+}
@@ -0,0 +1,481 @@
+package generator
+
+import (
+	"sort"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// JSONSchemaVersion — версия схемы report.json и error.json. Увеличивается при любом несовместимом
+// изменении: переименовании или удалении поля, смене единиц измерения. Новые поля версию не меняют.
+const JSONSchemaVersion = 1
+
+// JSONReport — корневой объект report.json.
+type JSONReport struct {
+	SchemaVersion  int              `json:"schema_version"`
+	GeneratedAt    time.Time        `json:"generated_at"`
+	Files          []string         `json:"files"`
+	TimeRange      JSONTimeRange    `json:"time_range"`
+	Requests       JSONRequests     `json:"requests"`
+	TopResources   []JSONCount      `json:"top_resources"`
+	TopEndpoints   []JSONCount      `json:"top_endpoints"`
+	TopPaths       []JSONCount      `json:"top_paths"`
+	TopQueryParams []JSONCount      `json:"top_query_params"`
+	QueryParam     *JSONQueryParam  `json:"query_param,omitempty"`
+	TopStatuses    []JSONCount      `json:"top_status_codes"`
+	TopReferrers   []JSONCount      `json:"top_referrers"`
+	ApproxTop      *JSONApproxTop   `json:"approx_top,omitempty"`
+	Visitors       JSONVisitors     `json:"unique_visitors"`
+	TopClients     []JSONClient     `json:"top_clients"`
+	Client         *JSONClient      `json:"client,omitempty"`
+	AgentClasses   JSONAgentClasses `json:"agent_classes"`
+	Latency        JSONLatency      `json:"latency"`
+	TimeSeries     JSONTimeSeries   `json:"time_series"`
+	FileFormats    []JSONFileFormat `json:"file_formats"`
+	ParseErrors    JSONParseErrors  `json:"parse_errors"`
+}
+
+// JSONTimeRange — границы анализа в RFC 3339; null, если граница не задана.
+type JSONTimeRange struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+type JSONRequests struct {
+	Total               int64   `json:"total"`
+	ServerErrors        int64   `json:"server_errors"`
+	TotalResponseSize   int64   `json:"total_response_size"`
+	AverageResponseSize float64 `json:"average_response_size"`
+	P95ResponseSize     int64   `json:"p95_response_size"`
+}
+
+// JSONCount — элемент списка top-N; списки упорядочены по убыванию count, при равенстве — по value.
+// Error есть только у приближённых топов: точное число лежит в [count-error, count].
+type JSONCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+	Error *int64 `json:"error,omitempty"`
+}
+
+// JSONApproxTop — параметры --approx-top; в точном режиме поле отсутствует.
+type JSONApproxTop struct {
+	Capacity int `json:"capacity"`
+}
+
+// JSONCardinality — оценка HyperLogLog и её стандартная относительная ошибка (0.0081 — 0,81%).
+type JSONCardinality struct {
+	Estimate      int64   `json:"estimate"`
+	RelativeError float64 `json:"relative_error"`
+}
+
+type JSONVisitors struct {
+	IPs          JSONCardinality `json:"ip"`
+	IPUserAgents JSONCardinality `json:"ip_user_agent"`
+	RemoteUsers  JSONCardinality `json:"remote_user"`
+}
+
+// JSONClient — статистика клиента по IP-адресу; error_rate — доля ответов 4xx и 5xx (0.25 — 25%).
+// client есть только в отчёте --ip.
+type JSONClient struct {
+	IP           string      `json:"ip"`
+	Requests     int64       `json:"requests"`
+	Bytes        int64       `json:"bytes"`
+	ClientErrors int64       `json:"client_errors"`
+	ServerErrors int64       `json:"server_errors"`
+	ErrorRate    float64     `json:"error_rate"`
+	FirstRequest *time.Time  `json:"first_request"`
+	LastRequest  *time.Time  `json:"last_request"`
+	TopResources []JSONCount `json:"top_resources"`
+}
+
+// JSONQueryParam — самые частые значения параметра --query-param, упорядочены как top_resources.
+type JSONQueryParam struct {
+	Name   string      `json:"name"`
+	Values []JSONCount `json:"values"`
+}
+
+// JSONAgentClasses — запросы по классам User-Agent, списки упорядочены как top_resources.
+type JSONAgentClasses struct {
+	Devices  []JSONCount `json:"devices"`
+	Families []JSONCount `json:"families"`
+	OSes     []JSONCount `json:"os"`
+}
+
+// JSONTimeSeries — запросы по интервалам времени UTC без пропусков; ширина задаётся --bucket,
+// иначе выбирается автоматически.
+type JSONTimeSeries struct {
+	BucketSeconds int64            `json:"bucket_seconds"`
+	Buckets       []JSONTimeBucket `json:"buckets"`
+}
+
+type JSONTimeBucket struct {
+	Start     time.Time `json:"start"`
+	Requests  int64     `json:"requests"`
+	Bytes     int64     `json:"bytes"`
+	Status2xx int64     `json:"status_2xx"`
+	Status3xx int64     `json:"status_3xx"`
+	Status4xx int64     `json:"status_4xx"`
+	Status5xx int64     `json:"status_5xx"`
+}
+
+// JSONPercentiles — перцентили времени ответа в миллисекундах.
+type JSONPercentiles struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+type JSONResourceLatency struct {
+	Resource string `json:"resource"`
+	JSONPercentiles
+}
+
+// JSONLatency — время ответа; resources упорядочены так же, как top_resources.
+type JSONLatency struct {
+	Request   JSONPercentiles       `json:"request"`
+	Upstream  JSONPercentiles       `json:"upstream"`
+	Resources []JSONResourceLatency `json:"resources"`
+}
+
+type JSONFileFormat struct {
+	File        string           `json:"file"`
+	Formats     map[string]int64 `json:"formats"`
+	FailedLines int64            `json:"failed_lines"`
+}
+
+type JSONParseErrors struct {
+	LinesRead   int64             `json:"lines_read"`
+	FailedLines int64             `json:"failed_lines"`
+	ErrorRate   float64           `json:"error_rate"`
+	ByKind      map[string]int64  `json:"by_kind"`
+	ByFile      map[string]int64  `json:"by_file"`
+	Samples     []JSONErrorSample `json:"samples"`
+}
+
+type JSONErrorSample struct {
+	File       string `json:"file"`
+	LineNumber int64  `json:"line_number"`
+	Kind       string `json:"kind"`
+	Line       string `json:"line"`
+}
+
+// JSONDiffReport — корневой объект report.json команды diff.
+type JSONDiffReport struct {
+	SchemaVersion int               `json:"schema_version"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+	Base          JSONPeriod        `json:"base"`
+	Current       JSONPeriod        `json:"current"`
+	Requests      JSONRequestsDiff  `json:"requests"`
+	TopResources  []JSONCountChange `json:"top_resources"`
+	TopStatuses   []JSONCountChange `json:"top_status_codes"`
+	TopReferrers  []JSONCountChange `json:"top_referrers"`
+}
+
+type JSONPeriod struct {
+	Files     []string      `json:"files"`
+	TimeRange JSONTimeRange `json:"time_range"`
+}
+
+type JSONRequestsDiff struct {
+	Total               JSONChange `json:"total"`
+	ServerErrors        JSONChange `json:"server_errors"`
+	AverageResponseSize JSONChange `json:"average_response_size"`
+	P95ResponseSize     JSONChange `json:"p95_response_size"`
+}
+
+// JSONChange — значения метрики в двух периодах; relative_change — изменение в долях базового
+// значения (0.25 — рост на 25%), null при нулевом базовом значении.
+type JSONChange struct {
+	Base           float64  `json:"base"`
+	Current        float64  `json:"current"`
+	Delta          float64  `json:"delta"`
+	RelativeChange *float64 `json:"relative_change"`
+}
+
+// JSONCountChange — значение из топа хотя бы одного периода; status — kept, new или gone.
+// base и current — числа вхождений в периодах, в том числе вне топа.
+type JSONCountChange struct {
+	Value   string `json:"value"`
+	Base    int64  `json:"base"`
+	Current int64  `json:"current"`
+	Status  string `json:"status"`
+}
+
+// JSONError — корневой объект error.json.
+type JSONError struct {
+	SchemaVersion int           `json:"schema_version"`
+	Error         JSONErrorBody `json:"error"`
+}
+
+type JSONErrorBody struct {
+	Message string `json:"message"`
+}
+
+// NewJSONReport переводит результат анализа в объект схемы JSONSchemaVersion.
+func NewJSONReport(result *domain.AnalysisResult, generatedAt time.Time) *JSONReport {
+	topResources := withErrors(sortedCounts(result.MostRequestedResources), resourceErrors(result))
+
+	return &JSONReport{
+		SchemaVersion: JSONSchemaVersion,
+		GeneratedAt:   generatedAt,
+		Files:         append([]string{}, result.Filenames...),
+		TimeRange:     JSONTimeRange{From: optionalTime(result.From), To: optionalTime(result.To)},
+		Requests: JSONRequests{
+			Total:               result.TotalRequests,
+			ServerErrors:        result.TotalServerErrorsLogs,
+			TotalResponseSize:   result.TotalResponseSize,
+			AverageResponseSize: result.AverageResponseSize,
+			P95ResponseSize:     result.Percentile95ResponseSize,
+		},
+		TopResources:   topResources,
+		TopEndpoints:   withErrors(sortedCounts(result.MostRequestedEndpoints), endpointErrors(result)),
+		TopPaths:       withErrors(sortedCounts(result.MostRequestedPaths), pathErrors(result)),
+		TopQueryParams: withErrors(sortedCounts(result.MostFrequentQueryParams), queryParamErrors(result)),
+		QueryParam:     newJSONQueryParam(result),
+		TopStatuses:    sortedCounts(result.MostFrequentStatusCodes),
+		TopReferrers:   withErrors(sortedCounts(result.MostFrequentReferrers), referrerErrors(result)),
+		ApproxTop:      newJSONApproxTop(result.ApproxTop),
+		Visitors: JSONVisitors{
+			IPs:          JSONCardinality(result.UniqueVisitors.IPs),
+			IPUserAgents: JSONCardinality(result.UniqueVisitors.IPUserAgents),
+			RemoteUsers:  JSONCardinality(result.UniqueVisitors.RemoteUsers),
+		},
+		TopClients: newJSONClients(result.TopClients),
+		Client:     newJSONClient(result.Client),
+		AgentClasses: JSONAgentClasses{
+			Devices:  sortedCounts(result.AgentClasses.Devices),
+			Families: sortedCounts(result.AgentClasses.Families),
+			OSes:     sortedCounts(result.AgentClasses.OSes),
+		},
+		Latency: JSONLatency{
+			Request:   newJSONPercentiles(result.RequestLatency),
+			Upstream:  newJSONPercentiles(result.UpstreamLatency),
+			Resources: resourceLatencies(topResources, result.ResourceLatencies),
+		},
+		TimeSeries:  newJSONTimeSeries(result.TimeSeries),
+		FileFormats: jsonFileFormats(result),
+		ParseErrors: newJSONParseErrors(result),
+	}
+}
+
+// NewJSONDiffReport переводит сравнение периодов в объект схемы JSONSchemaVersion.
+func NewJSONDiffReport(comparison *domain.Comparison, generatedAt time.Time) *JSONDiffReport {
+	return &JSONDiffReport{
+		SchemaVersion: JSONSchemaVersion,
+		GeneratedAt:   generatedAt,
+		Base:          newJSONPeriod(comparison.Base),
+		Current:       newJSONPeriod(comparison.Current),
+		Requests: JSONRequestsDiff{
+			Total:               newJSONChange(comparison.TotalRequests),
+			ServerErrors:        newJSONChange(comparison.ServerErrors),
+			AverageResponseSize: newJSONChange(comparison.AverageResponseSize),
+			P95ResponseSize:     newJSONChange(comparison.Percentile95ResponseSize),
+		},
+		TopResources: newJSONCountChanges(comparison.Resources),
+		TopStatuses:  newJSONCountChanges(comparison.StatusCodes),
+		TopReferrers: newJSONCountChanges(comparison.Referrers),
+	}
+}
+
+func newJSONPeriod(result *domain.AnalysisResult) JSONPeriod {
+	return JSONPeriod{
+		Files:     append([]string{}, result.Filenames...),
+		TimeRange: JSONTimeRange{From: optionalTime(result.From), To: optionalTime(result.To)},
+	}
+}
+
+func newJSONChange(change domain.Change) JSONChange {
+	item := JSONChange{Base: change.Base, Current: change.Current, Delta: change.Delta()}
+
+	if percent, ok := change.Percent(); ok {
+		item.RelativeChange = &percent
+	}
+
+	return item
+}
+
+func newJSONCountChanges(changes []domain.CountChange) []JSONCountChange {
+	items := make([]JSONCountChange, 0, len(changes))
+
+	for _, change := range changes {
+		items = append(items, JSONCountChange{Value: change.Value, Base: change.Base, Current: change.Current, Status: string(change.Status)})
+	}
+
+	return items
+}
+
+func sortedCounts(counts map[string]int64) []JSONCount {
+	items := make([]JSONCount, 0, len(counts))
+	for value, count := range counts {
+		items = append(items, JSONCount{Value: value, Count: count})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+
+		return items[i].Value < items[j].Value
+	})
+
+	return items
+}
+
+// withErrors добавляет к элементам приближённого топа погрешности; countErrors == nil — топ точный.
+func withErrors(items []JSONCount, countErrors map[string]int64) []JSONCount {
+	if countErrors == nil {
+		return items
+	}
+
+	for i := range items {
+		countError := countErrors[items[i].Value]
+		items[i].Error = &countError
+	}
+
+	return items
+}
+
+func newJSONClients(clients []domain.Client) []JSONClient {
+	items := make([]JSONClient, 0, len(clients))
+	for i := range clients {
+		items = append(items, *newJSONClient(&clients[i]))
+	}
+
+	return items
+}
+
+func newJSONClient(client *domain.Client) *JSONClient {
+	if client == nil {
+		return nil
+	}
+
+	return &JSONClient{
+		IP:           client.IP,
+		Requests:     client.Requests,
+		Bytes:        client.Bytes,
+		ClientErrors: client.ClientErrors,
+		ServerErrors: client.ServerErrors,
+		ErrorRate:    client.ErrorRate(),
+		FirstRequest: optionalTime(client.FirstRequest),
+		LastRequest:  optionalTime(client.LastRequest),
+		TopResources: sortedCounts(client.Resources),
+	}
+}
+
+func newJSONApproxTop(approxTop *domain.ApproxTop) *JSONApproxTop {
+	if approxTop == nil {
+		return nil
+	}
+
+	return &JSONApproxTop{Capacity: approxTop.Capacity}
+}
+
+func optionalTime(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+
+	return &value
+}
+
+func newJSONPercentiles(latency domain.LatencyPercentiles) JSONPercentiles {
+	return JSONPercentiles{
+		Count: latency.Count,
+		P50:   milliseconds(latency.P50),
+		P90:   milliseconds(latency.P90),
+		P95:   milliseconds(latency.P95),
+		P99:   milliseconds(latency.P99),
+		Max:   milliseconds(latency.Max),
+	}
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+func newJSONQueryParam(result *domain.AnalysisResult) *JSONQueryParam {
+	if result.QueryParam == "" {
+		return nil
+	}
+
+	return &JSONQueryParam{
+		Name:   result.QueryParam,
+		Values: withErrors(sortedCounts(result.QueryParamValues[result.QueryParam]), queryValueErrors(result)),
+	}
+}
+
+func resourceLatencies(topResources []JSONCount, latencies map[string]domain.LatencyPercentiles) []JSONResourceLatency {
+	items := make([]JSONResourceLatency, 0, len(latencies))
+
+	for _, resource := range topResources {
+		if latency, ok := latencies[resource.Value]; ok {
+			items = append(items, JSONResourceLatency{Resource: resource.Value, JSONPercentiles: newJSONPercentiles(latency)})
+		}
+	}
+
+	return items
+}
+
+func newJSONTimeSeries(series domain.TimeSeries) JSONTimeSeries {
+	buckets := make([]JSONTimeBucket, 0, len(series.Buckets))
+	for _, bucket := range series.Buckets {
+		buckets = append(buckets, JSONTimeBucket{
+			Start:     bucket.Start,
+			Requests:  bucket.Requests,
+			Bytes:     bucket.Bytes,
+			Status2xx: bucket.Status2xx,
+			Status3xx: bucket.Status3xx,
+			Status4xx: bucket.Status4xx,
+			Status5xx: bucket.Status5xx,
+		})
+	}
+
+	return JSONTimeSeries{BucketSeconds: int64(series.Width / time.Second), Buckets: buckets}
+}
+
+func jsonFileFormats(result *domain.AnalysisResult) []JSONFileFormat {
+	rows := fileFormatRows(result)
+	items := make([]JSONFileFormat, 0, len(rows))
+
+	for _, row := range rows {
+		file := row[0].(string)
+
+		formats := make(map[string]int64, len(result.FileFormats[file]))
+		for name, count := range result.FileFormats[file] {
+			formats[name] = count
+		}
+
+		items = append(items, JSONFileFormat{File: file, Formats: formats, FailedLines: result.ParseErrors.ByFile[file]})
+	}
+
+	return items
+}
+
+func newJSONParseErrors(result *domain.AnalysisResult) JSONParseErrors {
+	samples := make([]JSONErrorSample, 0, len(result.ParseErrors.Samples))
+	for _, sample := range result.ParseErrors.Samples {
+		samples = append(samples, JSONErrorSample{File: sample.Filename, LineNumber: sample.LineNumber, Kind: sample.Kind, Line: sample.Line})
+	}
+
+	return JSONParseErrors{
+		LinesRead:   result.TotalLines,
+		FailedLines: result.ParseErrors.Total,
+		ErrorRate:   result.ParseErrorRate(),
+		ByKind:      nonNilCounts(result.ParseErrors.ByKind),
+		ByFile:      nonNilCounts(result.ParseErrors.ByFile),
+		Samples:     samples,
+	}
+}
+
+// nonNilCounts заменяет nil на пустую карту, чтобы в JSON был {} вместо null.
+func nonNilCounts(counts map[string]int64) map[string]int64 {
+	if counts == nil {
+		return map[string]int64{}
+	}
+
+	return counts
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// JSONReportGenerator пишет отчёт и ошибки в JSON по схеме JSONSchemaVersion.
+type JSONReportGenerator struct {
+	writer FileWriter
+}
+
+func NewJSONReportGenerator(writer FileWriter) *JSONReportGenerator {
+	return &JSONReportGenerator{writer: writer}
+}
+
+func (jrg JSONReportGenerator) GenerateReport(result *domain.AnalysisResult) {
+	if err := jrg.writeJSON(jrg.GetFilePath(), NewJSONReport(result, time.Now())); err != nil {
+		jrg.GenerateExceptionReport(jrg.GetErrorFilePath(), "Error writing to file")
+	}
+}
+
+// WriteReport кодирует отчёт в w по схеме JSONSchemaVersion.
+func (jrg JSONReportGenerator) WriteReport(w io.Writer, result *domain.AnalysisResult) error {
+	return encodeJSON(w, NewJSONReport(result, time.Now()))
+}
+
+func (jrg JSONReportGenerator) GenerateDiffReport(comparison *domain.Comparison) {
+	if err := jrg.writeJSON(jrg.GetFilePath(), NewJSONDiffReport(comparison, time.Now())); err != nil {
+		jrg.GenerateExceptionReport(jrg.GetErrorFilePath(), "Error writing to file")
+	}
+}
+
+func (jrg JSONReportGenerator) GenerateExceptionReport(filePath, message string) {
+	report := JSONError{SchemaVersion: JSONSchemaVersion, Error: JSONErrorBody{Message: message}}
+
+	if err := jrg.writeJSON(filePath, report); err != nil {
+		fmt.Printf("Error writing exception report: %s\n", err.Error())
+	}
+}
+
+func (jrg JSONReportGenerator) writeJSON(filePath string, value interface{}) error {
+	file, err := jrg.writer.CreateFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return encodeJSON(file, value)
+}
+
+func encodeJSON(w io.Writer, value interface{}) error {
+	writer := bufio.NewWriter(w)
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func (jrg JSONReportGenerator) GetFilePath() string {
+	return "report.json"
+}
+
+func (jrg JSONReportGenerator) GetErrorFilePath() string {
+	return "error.json"
+}
@@ -3,6 +3,7 @@ package generator
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -26,14 +27,94 @@ func (mrg MarkdownReportGenerator) GenerateReport(result *domain.AnalysisResult)
 
 	defer file.Close()
 
-	writer := bufio.NewWriter(file)
+	if err := mrg.WriteReport(file, result); err != nil {
+		fmt.Printf("Error writing report: %s\n", err.Error())
+	}
+}
 
-	defer writer.Flush()
+// WriteReport пишет отчёт в w: GenerateReport передаёт файл, команда api — ответ HTTP.
+func (mrg MarkdownReportGenerator) WriteReport(w io.Writer, result *domain.AnalysisResult) error {
+	writer := bufio.NewWriter(w)
 
 	mrg.writeGeneralInfo(writer, result)
+	mrg.writeClient(writer, result)
+	mrg.writeUniqueVisitors(writer, result)
+	mrg.writeTopClients(writer, result)
+	mrg.writeAgentClasses(writer, result)
 	mrg.writeRequestedResources(writer, result)
+	mrg.writeEndpoints(writer, result)
+	mrg.writeQuery(writer, result)
 	mrg.writeResponseCodes(writer, result)
+	mrg.writeTimeSeries(writer, result)
+	mrg.writeLatencies(writer, result)
 	mrg.writeAdditionalInfo(writer, result)
+	mrg.writeFileFormats(writer, result)
+	mrg.writeParseErrors(writer, result)
+
+	return writer.Flush()
+}
+
+// GenerateDiffReport пишет в report.md сравнение текущего периода с базовым (ngxstat diff).
+func (mrg MarkdownReportGenerator) GenerateDiffReport(comparison *domain.Comparison) {
+	file, err := mrg.writer.CreateFile(mrg.GetFilePath())
+	if err != nil {
+		mrg.GenerateExceptionReport(mrg.GetErrorFilePath(), "Error writing to file")
+		return
+	}
+
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+
+	defer writer.Flush()
+
+	mrg.writePeriods(writer, comparison)
+	mrg.writeMetricChanges(writer, comparison)
+
+	mrg.writeLine(writer, mrg.getRequestedResourcesHeader())
+	mrg.writeCountChanges(writer, "Resource", comparison.Resources)
+	mrg.writeLine(writer, mrg.getResponseCodesHeader())
+	mrg.writeCountChanges(writer, "Code", comparison.StatusCodes)
+	mrg.writeLine(writer, mrg.getReferrersHeader())
+	mrg.writeCountChanges(writer, "Referrer", comparison.Referrers)
+}
+
+func (mrg MarkdownReportGenerator) writePeriods(writer *bufio.Writer, comparison *domain.Comparison) {
+	mrg.writeLine(writer, mrg.getPeriodsHeader())
+	mrg.writeLine(writer, formatColumns("Period", "Files", "Start Date", "End Date"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---", "---"))
+
+	for _, period := range []struct {
+		name   string
+		result *domain.AnalysisResult
+	}{{"Base", comparison.Base}, {"Current", comparison.Current}} {
+		mrg.writeLine(writer, formatColumns(period.name, len(period.result.Filenames),
+			mrg.emptyTimeConverter(period.result.From), mrg.emptyTimeConverter(period.result.To)))
+	}
+
+	mrg.writeLine(writer, "")
+}
+
+func (mrg MarkdownReportGenerator) writeMetricChanges(writer *bufio.Writer, comparison *domain.Comparison) {
+	mrg.writeLine(writer, mrg.getGeneralInfoHeader())
+	mrg.writeLine(writer, formatColumns("Metric", "Base", "Current", "Delta", "Change"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---", "---", "---"))
+	mrg.writeLine(writer, formatColumns(changeRow("Total Requests", comparison.TotalRequests)...))
+	mrg.writeLine(writer, formatColumns(changeRow("Server Errors (5xx)", comparison.ServerErrors)...))
+	mrg.writeLine(writer, formatColumns(changeRow("Average Response Size", comparison.AverageResponseSize)...))
+	mrg.writeLine(writer, formatColumns(changeRow("95th Percentile Response Size", comparison.Percentile95ResponseSize)...))
+	mrg.writeLine(writer, "")
+}
+
+func (mrg MarkdownReportGenerator) writeCountChanges(writer *bufio.Writer, name string, changes []domain.CountChange) {
+	mrg.writeLine(writer, formatColumns(name, "Base", "Current", "Delta", "Change"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---", "---", "---"))
+
+	for _, row := range countChangeRows(changes, map[domain.EntryStatus]string{domain.EntryNew: "new", domain.EntryGone: "gone"}) {
+		mrg.writeLine(writer, formatColumns(row...))
+	}
+
+	mrg.writeLine(writer, "")
 }
 
 func (mrg MarkdownReportGenerator) writeGeneralInfo(writer *bufio.Writer, result *domain.AnalysisResult) {
@@ -41,22 +122,143 @@ func (mrg MarkdownReportGenerator) writeGeneralInfo(writer *bufio.Writer, result
 	mrg.writeLine(writer, mrg.formatLine("Metric", "Value"))
 	mrg.writeLine(writer, mrg.formatLine("---", "---"))
 	mrg.writeLine(writer, mrg.formatLine("Number of Files", len(result.Filenames)))
-	mrg.writeLine(writer, mrg.getListFiles(result.Filenames))
+
+	// Если фильтры не оставили ни одной записи, файлов в результате нет.
+	if len(result.Filenames) > 0 {
+		mrg.writeLine(writer, mrg.getListFiles(result.Filenames))
+	}
+
 	mrg.writeLine(writer, mrg.formatLine("Start Date", mrg.emptyTimeConverter(result.From)))
 	mrg.writeLine(writer, mrg.formatLine("End Date", mrg.emptyTimeConverter(result.To)))
 	mrg.writeLine(writer, mrg.formatLine("Total Requests", result.TotalRequests))
 	mrg.writeLine(writer, mrg.formatLine("Average Response Size", result.AverageResponseSize))
 	mrg.writeLine(writer, mrg.formatLine("95th Percentile Response Size", result.Percentile95ResponseSize))
+
+	if result.ApproxTop != nil {
+		mrg.writeLine(writer, mrg.formatLine("Approx. Top Capacity", result.ApproxTop.Capacity))
+	}
+
+	mrg.writeLine(writer, "")
+}
+
+func (mrg MarkdownReportGenerator) writeUniqueVisitors(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.UniqueVisitors.IPs.Estimate == 0 && result.UniqueVisitors.RemoteUsers.Estimate == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getUniqueVisitorsHeader())
+	mrg.writeLine(writer, formatColumns("Metric", "Estimate", "Std. Error"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---"))
+
+	for _, row := range uniqueVisitorRows(result.UniqueVisitors) {
+		mrg.writeLine(writer, formatColumns(row...))
+	}
+
+	mrg.writeLine(writer, "")
+}
+
+// writeClient пишет сводку по клиенту отчёта --ip.
+func (mrg MarkdownReportGenerator) writeClient(writer *bufio.Writer, result *domain.AnalysisResult) {
+	client := result.Client
+	if client == nil {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getClientHeader())
+	mrg.writeLine(writer, mrg.formatLine("Metric", "Value"))
+	mrg.writeLine(writer, mrg.formatLine("---", "---"))
+	mrg.writeLine(writer, mrg.formatLine("IP", client.IP))
+	mrg.writeLine(writer, mrg.formatLine("First Request", mrg.emptyTimeConverter(client.FirstRequest)))
+	mrg.writeLine(writer, mrg.formatLine("Last Request", mrg.emptyTimeConverter(client.LastRequest)))
+	mrg.writeLine(writer, mrg.formatLine("Requests", client.Requests))
+	mrg.writeLine(writer, mrg.formatLine("Bytes", client.Bytes))
+	mrg.writeLine(writer, mrg.formatLine("Client Errors (4xx)", client.ClientErrors))
+	mrg.writeLine(writer, mrg.formatLine("Server Errors (5xx)", client.ServerErrors))
+	mrg.writeLine(writer, mrg.formatLine("Error Rate", formatRate(client.ErrorRate())))
+	mrg.writeLine(writer, "")
+}
+
+// writeTopClients пишет топ клиентов; в отчёте --ip он не нужен.
+func (mrg MarkdownReportGenerator) writeTopClients(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.Client != nil || len(result.TopClients) == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getTopClientsHeader())
+	mrg.writeLine(writer, formatColumns("IP", "Requests", "Bytes", "Error Rate", "Top Resources"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---", "---", "---"))
+
+	for _, row := range topClientRows(result.TopClients) {
+		mrg.writeLine(writer, formatColumns(row...))
+	}
+
+	mrg.writeLine(writer, "")
+}
+
+func (mrg MarkdownReportGenerator) writeAgentClasses(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if len(result.AgentClasses.Devices) == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getAgentClassesHeader())
+	mrg.writeLine(writer, formatColumns("Class", "Value", "Requests", "Share"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---", "---"))
+
+	for _, row := range agentClassRows(result, [3]string{"Device", "Family", "OS"}) {
+		mrg.writeLine(writer, formatColumns(row...))
+	}
+
 	mrg.writeLine(writer, "")
 }
 
 func (mrg MarkdownReportGenerator) writeRequestedResources(writer *bufio.Writer, result *domain.AnalysisResult) {
 	mrg.writeLine(writer, mrg.getRequestedResourcesHeader())
-	mrg.writeLine(writer, mrg.formatLine("Resource", "Count"))
-	mrg.writeLine(writer, mrg.formatLine("---", "---"))
+	mrg.writeCounts(writer, "Resource", result.MostRequestedResources, resourceErrors(result))
+}
 
-	for key, value := range result.MostRequestedResources {
-		mrg.writeLine(writer, mrg.formatLine(key, value))
+func (mrg MarkdownReportGenerator) writeEndpoints(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if len(result.MostRequestedEndpoints) == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getEndpointsHeader())
+	mrg.writeCounts(writer, "Endpoint", result.MostRequestedEndpoints, endpointErrors(result))
+}
+
+// writeQuery пишет топ путей без query string, имён параметров и значений параметра --query-param.
+func (mrg MarkdownReportGenerator) writeQuery(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if len(result.MostRequestedPaths) > 0 {
+		mrg.writeLine(writer, mrg.getPathsHeader())
+		mrg.writeCounts(writer, "Path", result.MostRequestedPaths, pathErrors(result))
+	}
+
+	if len(result.MostFrequentQueryParams) > 0 {
+		mrg.writeLine(writer, mrg.getQueryParamsHeader())
+		mrg.writeCounts(writer, "Parameter", result.MostFrequentQueryParams, queryParamErrors(result))
+	}
+
+	if result.QueryParam != "" {
+		mrg.writeLine(writer, mrg.getQueryValuesHeader(result.QueryParam))
+		mrg.writeCounts(writer, "Value", result.QueryParamValues[result.QueryParam], queryValueErrors(result))
+	}
+}
+
+// writeCounts пишет таблицу топа; для приближённого топа добавляется колонка погрешности.
+func (mrg MarkdownReportGenerator) writeCounts(writer *bufio.Writer, name string, counts, countErrors map[string]int64) {
+	if countErrors == nil {
+		mrg.writeLine(writer, mrg.formatLine(name, "Count"))
+		mrg.writeLine(writer, mrg.formatLine("---", "---"))
+
+		for key, value := range counts {
+			mrg.writeLine(writer, mrg.formatLine(key, value))
+		}
+	} else {
+		mrg.writeLine(writer, formatColumns(name, "Count", "Error"))
+		mrg.writeLine(writer, formatColumns("---", "---", "---"))
+
+		for key, value := range counts {
+			mrg.writeLine(writer, formatColumns(key, value, fmt.Sprintf("±%d", countErrors[key])))
+		}
 	}
 
 	mrg.writeLine(writer, "")
@@ -74,6 +276,54 @@ func (mrg MarkdownReportGenerator) writeResponseCodes(writer *bufio.Writer, resu
 	mrg.writeLine(writer, "")
 }
 
+func (mrg MarkdownReportGenerator) writeTimeSeries(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if !showTimeSeries(result) {
+		return
+	}
+
+	series := result.TimeSeries
+
+	mrg.writeLine(writer, mrg.getTimeSeriesHeader())
+	mrg.writeLine(writer, formatColumns("Start (UTC, "+formatWidth(series.Width)+")", "Requests", "Bytes", "2xx", "3xx", "4xx", "5xx"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---", "---", "---", "---", "---"))
+
+	for _, row := range timeSeriesRows(series) {
+		mrg.writeLine(writer, formatColumns(row...))
+	}
+
+	mrg.writeLine(writer, "")
+}
+
+func (mrg MarkdownReportGenerator) writeLatencies(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.RequestLatency.Count == 0 && result.UpstreamLatency.Count == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getLatenciesHeader())
+	mrg.writeLine(writer, formatColumns("Percentile", "Request", "Upstream"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---"))
+
+	for _, row := range latencyRows(result.RequestLatency, result.UpstreamLatency) {
+		mrg.writeLine(writer, formatColumns(row...))
+	}
+
+	mrg.writeLine(writer, "")
+
+	if len(result.ResourceLatencies) == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getResourceLatenciesHeader())
+	mrg.writeLine(writer, formatColumns("Resource", "p50", "p95", "p99", "Max"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---", "---", "---"))
+
+	for resource, latency := range result.ResourceLatencies {
+		mrg.writeLine(writer, formatColumns(resource, latency.P50, latency.P95, latency.P99, latency.Max))
+	}
+
+	mrg.writeLine(writer, "")
+}
+
 func (mrg MarkdownReportGenerator) getListFiles(filenames []string) string {
 	var builder strings.Builder
 	for _, filename := range filenames {
@@ -95,14 +345,51 @@ func (mrg MarkdownReportGenerator) writeAdditionalInfo(writer *bufio.Writer, res
 
 func (mrg MarkdownReportGenerator) writeMostFrequentReferrers(writer *bufio.Writer, result *domain.AnalysisResult) {
 	mrg.writeLine(writer, mrg.getReferrersHeader())
-	mrg.writeLine(writer, mrg.formatLine("Referrer", "Count"))
+	mrg.writeCounts(writer, "Referrer", result.MostFrequentReferrers, referrerErrors(result))
+}
+
+func (mrg MarkdownReportGenerator) writeFileFormats(writer *bufio.Writer, result *domain.AnalysisResult) {
+	rows := fileFormatRows(result)
+	if len(rows) == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getFileFormatsHeader())
+	mrg.writeLine(writer, formatColumns("File", "Format", "Failed Lines"))
+	mrg.writeLine(writer, formatColumns("---", "---", "---"))
+
+	for _, row := range rows {
+		mrg.writeLine(writer, formatColumns(row...))
+	}
+
+	mrg.writeLine(writer, "")
+}
+
+func (mrg MarkdownReportGenerator) writeParseErrors(writer *bufio.Writer, result *domain.AnalysisResult) {
+	if result.ParseErrors.Total == 0 {
+		return
+	}
+
+	mrg.writeLine(writer, mrg.getParseErrorsHeader())
+	mrg.writeLine(writer, mrg.formatLine("Metric", "Value"))
 	mrg.writeLine(writer, mrg.formatLine("---", "---"))
+	mrg.writeLine(writer, mrg.formatLine("Lines Read", result.TotalLines))
+	mrg.writeLine(writer, mrg.formatLine("Failed Lines", result.ParseErrors.Total))
+	mrg.writeLine(writer, mrg.formatLine("Error Rate", formatRate(result.ParseErrorRate())))
 
-	for key, value := range result.MostFrequentReferrers {
-		mrg.writeLine(writer, mrg.formatLine(key, value))
+	for _, kind := range sortedKeys(result.ParseErrors.ByKind) {
+		mrg.writeLine(writer, mrg.formatLine("- "+kind, result.ParseErrors.ByKind[kind]))
 	}
 
 	mrg.writeLine(writer, "")
+	mrg.writeLine(writer, "```")
+
+	for _, sample := range result.ParseErrors.Samples {
+		mrg.writeLine(writer, formatParseErrorSample(sample))
+	}
+
+	mrg.writeLine(writer, "```")
+	mrg.writeLine(writer, "")
 }
 
 func (mrg MarkdownReportGenerator) GenerateExceptionReport(filePath, message string) {
@@ -148,10 +435,30 @@ func (mrg MarkdownReportGenerator) getGeneralInfoHeader() string {
 	return "#### Общая информация \n"
 }
 
+func (mrg MarkdownReportGenerator) getPeriodsHeader() string {
+	return "#### Сравниваемые периоды\n"
+}
+
 func (mrg MarkdownReportGenerator) getRequestedResourcesHeader() string {
 	return "#### Запрашиваемые ресурсы\n\n"
 }
 
+func (mrg MarkdownReportGenerator) getEndpointsHeader() string {
+	return "#### Шаблоны запросов\n\n"
+}
+
+func (mrg MarkdownReportGenerator) getPathsHeader() string {
+	return "#### Пути запросов\n\n"
+}
+
+func (mrg MarkdownReportGenerator) getQueryParamsHeader() string {
+	return "#### Параметры запроса\n\n"
+}
+
+func (mrg MarkdownReportGenerator) getQueryValuesHeader(name string) string {
+	return "#### Значения параметра " + name + "\n\n"
+}
+
 func (mrg MarkdownReportGenerator) getResponseCodesHeader() string {
 	return "\n#### Коды ответа\n"
 }
@@ -164,6 +471,42 @@ func (mrg MarkdownReportGenerator) getReferrersHeader() string {
 	return "### Ссылающиеся ресурсы\n\n"
 }
 
+func (mrg MarkdownReportGenerator) getUniqueVisitorsHeader() string {
+	return "#### Уникальные клиенты\n"
+}
+
+func (mrg MarkdownReportGenerator) getClientHeader() string {
+	return "#### Клиент\n"
+}
+
+func (mrg MarkdownReportGenerator) getTopClientsHeader() string {
+	return "#### Активные клиенты\n"
+}
+
+func (mrg MarkdownReportGenerator) getAgentClassesHeader() string {
+	return "#### Клиентские приложения\n"
+}
+
+func (mrg MarkdownReportGenerator) getTimeSeriesHeader() string {
+	return "#### Запросы по времени\n"
+}
+
+func (mrg MarkdownReportGenerator) getLatenciesHeader() string {
+	return "#### Время ответа\n"
+}
+
+func (mrg MarkdownReportGenerator) getResourceLatenciesHeader() string {
+	return "#### Время ответа по ресурсам\n"
+}
+
+func (mrg MarkdownReportGenerator) getFileFormatsHeader() string {
+	return "### Форматы файлов\n\n"
+}
+
+func (mrg MarkdownReportGenerator) getParseErrorsHeader() string {
+	return "### Ошибки разбора\n\n"
+}
+
 func (mrg MarkdownReportGenerator) getAdditionalInfoHeader() string {
 	return "### Дополнительная информация: \n"
 }
@@ -0,0 +1,303 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const timeSeriesLayout = "2006-01-02 15:04"
+
+// formatColumns форматирует строку таблицы с произвольным числом колонок
+// в той же ширине, что и двухколоночные таблицы отчётов.
+func formatColumns(values ...interface{}) string {
+	var builder strings.Builder
+
+	for i, value := range values {
+		if i == 0 {
+			builder.WriteString(fmt.Sprintf("| %-21v ", value))
+			continue
+		}
+
+		builder.WriteString(fmt.Sprintf("| %21v ", value))
+	}
+
+	builder.WriteString("|")
+
+	return builder.String()
+}
+
+// timeSeriesRows возвращает строки таблицы временного ряда: начало интервала, запросы, байты и классы кодов.
+func timeSeriesRows(series domain.TimeSeries) [][]interface{} {
+	rows := make([][]interface{}, 0, len(series.Buckets))
+
+	for _, bucket := range series.Buckets {
+		rows = append(rows, []interface{}{
+			bucket.Start.Format(timeSeriesLayout), bucket.Requests, bucket.Bytes,
+			bucket.Status2xx, bucket.Status3xx, bucket.Status4xx, bucket.Status5xx,
+		})
+	}
+
+	return rows
+}
+
+// formatWidth записывает ширину интервала так же, как она задаётся флагом --bucket.
+func formatWidth(width time.Duration) string {
+	switch {
+	case width%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", width/(24*time.Hour))
+	case width%time.Hour == 0:
+		return fmt.Sprintf("%dh", width/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", width/time.Minute)
+	}
+}
+
+// resourceErrors возвращает погрешности топа ресурсов при --approx-top и nil для точного топа.
+func resourceErrors(result *domain.AnalysisResult) map[string]int64 {
+	if result.ApproxTop == nil {
+		return nil
+	}
+
+	return result.ApproxTop.ResourceErrors
+}
+
+func endpointErrors(result *domain.AnalysisResult) map[string]int64 {
+	if result.ApproxTop == nil {
+		return nil
+	}
+
+	return result.ApproxTop.EndpointErrors
+}
+
+func pathErrors(result *domain.AnalysisResult) map[string]int64 {
+	if result.ApproxTop == nil {
+		return nil
+	}
+
+	return result.ApproxTop.PathErrors
+}
+
+func queryParamErrors(result *domain.AnalysisResult) map[string]int64 {
+	if result.ApproxTop == nil {
+		return nil
+	}
+
+	return result.ApproxTop.QueryParamErrors
+}
+
+// queryValueErrors возвращает погрешности значений параметра --query-param; в приближённом режиме
+// словарь не nil, даже если значений нет, чтобы у таблицы была колонка погрешности.
+func queryValueErrors(result *domain.AnalysisResult) map[string]int64 {
+	if result.ApproxTop == nil {
+		return nil
+	}
+
+	if errors, ok := result.ApproxTop.QueryValueErrors[result.QueryParam]; ok {
+		return errors
+	}
+
+	return map[string]int64{}
+}
+
+func referrerErrors(result *domain.AnalysisResult) map[string]int64 {
+	if result.ApproxTop == nil {
+		return nil
+	}
+
+	return result.ApproxTop.ReferrerErrors
+}
+
+// uniqueVisitorRows возвращает оценки числа уникальных клиентов со стандартной ошибкой.
+func uniqueVisitorRows(visitors domain.UniqueVisitors) [][]interface{} {
+	return [][]interface{}{
+		{"IP", visitors.IPs.Estimate, "±" + formatRate(visitors.IPs.RelativeError)},
+		{"IP + User-Agent", visitors.IPUserAgents.Estimate, "±" + formatRate(visitors.IPUserAgents.RelativeError)},
+		{"Remote User", visitors.RemoteUsers.Estimate, "±" + formatRate(visitors.RemoteUsers.RelativeError)},
+	}
+}
+
+// topClientRows возвращает строки топа клиентов: адрес, запросы, байты, доля ошибок и самые частые ресурсы.
+func topClientRows(clients []domain.Client) [][]interface{} {
+	rows := make([][]interface{}, 0, len(clients))
+
+	for _, client := range clients {
+		rows = append(rows, []interface{}{
+			client.IP, client.Requests, client.Bytes, formatRate(client.ErrorRate()), describeCounts(client.Resources),
+		})
+	}
+
+	return rows
+}
+
+// showTimeSeries сообщает, выводить ли временной ряд в текстовых отчётах: его печатают при заданном
+// --bucket и всегда в отчёте --ip, где активность клиента по времени — главное.
+func showTimeSeries(result *domain.AnalysisResult) bool {
+	return (result.TimeSeries.Explicit || result.Client != nil) && len(result.TimeSeries.Buckets) > 0
+}
+
+func latencyRows(request, upstream domain.LatencyPercentiles) [][]interface{} {
+	return [][]interface{}{
+		{"p50", request.P50, upstream.P50},
+		{"p90", request.P90, upstream.P90},
+		{"p95", request.P95, upstream.P95},
+		{"p99", request.P99, upstream.P99},
+		{"max", request.Max, upstream.Max},
+		{"count", request.Count, upstream.Count},
+	}
+}
+
+// fileFormatRows собирает по каждому файлу определённые форматы и число неразобранных строк.
+func fileFormatRows(result *domain.AnalysisResult) [][]interface{} {
+	files := make([]string, 0, len(result.FileFormats)+len(result.ParseErrors.ByFile))
+
+	for file := range result.FileFormats {
+		files = append(files, file)
+	}
+
+	for file := range result.ParseErrors.ByFile {
+		if _, ok := result.FileFormats[file]; !ok {
+			files = append(files, file)
+		}
+	}
+
+	sort.Strings(files)
+
+	rows := make([][]interface{}, 0, len(files))
+	for _, file := range files {
+		rows = append(rows, []interface{}{file, describeCounts(result.FileFormats[file]), result.ParseErrors.ByFile[file]})
+	}
+
+	return rows
+}
+
+// describeCounts перечисляет значения с числом вхождений по убыванию: "a (3), b (1)".
+func describeCounts(counts map[string]int64) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+
+	names := keysByCount(counts)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s (%d)", name, counts[name]))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// keysByCount возвращает ключи по убыванию счётчиков, при равенстве — по алфавиту.
+func keysByCount(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// agentClassRows возвращает строки таблицы классов User-Agent: вид класса из kinds (устройство, семейство, ОС),
+// значение, число запросов и их долю от всех запросов.
+func agentClassRows(result *domain.AnalysisResult, kinds [3]string) [][]interface{} {
+	classes := result.AgentClasses
+	rows := make([][]interface{}, 0, len(classes.Devices)+len(classes.Families)+len(classes.OSes))
+
+	for i, counts := range []map[string]int64{classes.Devices, classes.Families, classes.OSes} {
+		for _, value := range keysByCount(counts) {
+			share := 0.0
+			if result.TotalRequests > 0 {
+				share = float64(counts[value]) / float64(result.TotalRequests)
+			}
+
+			rows = append(rows, []interface{}{kinds[i], value, counts[value], formatRate(share)})
+		}
+	}
+
+	return rows
+}
+
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatParseErrorSample(sample domain.ParseErrorSample) string {
+	return fmt.Sprintf("%s:%d [%s]: %s", sample.Filename, sample.LineNumber, sample.Kind, sample.Line)
+}
+
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%.2f%%", rate*100)
+}
+
+// changeRow возвращает строку сравнения метрики: базовое и текущее значения, разница и изменение в процентах.
+func changeRow(name string, change domain.Change) []interface{} {
+	return []interface{}{name, formatNumber(change.Base), formatNumber(change.Current), formatDelta(change), formatPercent(change)}
+}
+
+// countChangeRows возвращает строки сравнения топов. У значений, вошедших в топ или выпавших из него,
+// вместо изменения в процентах стоит statusLabels, если процент не определён, иначе — рядом с ним.
+func countChangeRows(changes []domain.CountChange, statusLabels map[domain.EntryStatus]string) [][]interface{} {
+	rows := make([][]interface{}, 0, len(changes))
+
+	for _, change := range changes {
+		row := changeRow(change.Value, change.Change())
+
+		if label, ok := statusLabels[change.Status]; ok {
+			if _, defined := change.Change().Percent(); defined {
+				label = formatPercent(change.Change()) + ", " + label
+			}
+
+			row[len(row)-1] = label
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func formatNumber(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+
+	return fmt.Sprintf("%.2f", value)
+}
+
+func formatDelta(change domain.Change) string {
+	delta := change.Delta()
+	if delta == math.Trunc(delta) {
+		return fmt.Sprintf("%+.0f", delta)
+	}
+
+	return fmt.Sprintf("%+.2f", delta)
+}
+
+// formatPercent записывает изменение в процентах со знаком; при нулевом базовом значении — прочерк.
+func formatPercent(change domain.Change) string {
+	percent, ok := change.Percent()
+	if !ok {
+		return "-"
+	}
+
+	return fmt.Sprintf("%+.2f%%", percent*100)
+}
@@ -0,0 +1,139 @@
+// Package metrics отдаёт статистику команды serve в текстовом формате Prometheus (exposition format 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// Path — путь, по которому serve отдаёт метрики.
+	Path        = "/metrics"
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+	Namespace   = "ngxstat"
+
+	microsecondsPerSecond = 1e6
+)
+
+var (
+	// SizeBuckets — верхние границы интервалов гистограммы размеров ответа, байты.
+	SizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+	// LatencyBuckets — верхние границы интервалов гистограмм задержек, секунды (как в клиентах Prometheus).
+	LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// NewHandler возвращает обработчик, который на каждый запрос берёт свежие счётчики из sample.
+func NewHandler(sample func() *domain.MetricsSample) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = Write(w, sample())
+	})
+}
+
+// Write пишет метрики sample. Метки упорядочены, поэтому вывод при одних и тех же счётчиках одинаков.
+// Сумма гистограмм задержек оценивается по среднему HdrHistogram: точные задержки не хранятся.
+func Write(w io.Writer, sample *domain.MetricsSample) error {
+	writer := bufio.NewWriter(w)
+
+	writeCounter(writer, "lines_total", "Прочитанные строки логов.", sample.TotalLines)
+	writeCounter(writer, "parse_errors_total", "Строки, которые не удалось разобрать.", sample.ParseErrors)
+	writeCounter(writer, "requests_total", "Запросы, прошедшие фильтры.", sample.TotalRequests)
+	writeLabeledCounter(writer, "requests_by_status_total", "Запросы по коду ответа.", "status", sample.StatusCodes)
+	writeLabeledCounter(writer, "requests_by_method_total", "Запросы по HTTP-методу.", "method", sample.Methods)
+	writeLabeledCounter(writer, "requests_by_endpoint_total", "Запросы по шаблону пути.", "endpoint", sample.Endpoints)
+	writeCounter(writer, "response_bytes_total", "Отправлено байт в ответах.", sample.TotalResponseSize)
+	writeHistogram(writer, "response_size_bytes", "Размер ответа, байты.",
+		sample.ResponseSizes, SizeBuckets, 1, float64(sample.TotalResponseSize))
+	writeHistogram(writer, "request_duration_seconds", "Время обработки запроса ($request_time), секунды.",
+		sample.RequestLatency, LatencyBuckets, microsecondsPerSecond, latencySum(sample.RequestLatency))
+	writeHistogram(writer, "upstream_duration_seconds", "Время ответа бэкенда ($upstream_response_time), секунды.",
+		sample.UpstreamLatency, LatencyBuckets, microsecondsPerSecond, latencySum(sample.UpstreamLatency))
+
+	return writer.Flush()
+}
+
+func writeHeader(writer *bufio.Writer, name, help, kind string) string {
+	name = Namespace + "_" + name
+	fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+
+	return name
+}
+
+func writeCounter(writer *bufio.Writer, name, help string, value int64) {
+	name = writeHeader(writer, name, help, "counter")
+	fmt.Fprintf(writer, "%s %d\n", name, value)
+}
+
+func writeLabeledCounter(writer *bufio.Writer, name, help, label string, counts map[string]int64) {
+	name = writeHeader(writer, name, help, "counter")
+
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+
+	sort.Strings(values)
+
+	for _, value := range values {
+		fmt.Fprintf(writer, "%s{%s=\"%s\"} %d\n", name, label, escapeLabel(value), counts[value])
+	}
+}
+
+// writeHistogram пишет гистограмму с накопленными счётчиками по bounds; значения histogram делятся
+// на scale, чтобы перейти к единицам метрики.
+func writeHistogram(
+	writer *bufio.Writer,
+	name, help string,
+	histogram *hdrhistogram.Histogram,
+	bounds []float64,
+	scale, sum float64,
+) {
+	name = writeHeader(writer, name, help, "histogram")
+	counts := make([]int64, len(bounds))
+
+	for _, bar := range histogram.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+
+		// Интервалы HdrHistogram не шире 0,1% значения, поэтому интервал относится к группе по нижней границе.
+		value := float64(bar.From) / scale
+
+		for i, bound := range bounds {
+			if value <= bound {
+				counts[i] += bar.Count
+			}
+		}
+	}
+
+	for i, bound := range bounds {
+		fmt.Fprintf(writer, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), counts[i])
+	}
+
+	fmt.Fprintf(writer, "%s_bucket{le=\"+Inf\"} %d\n", name, histogram.TotalCount())
+	fmt.Fprintf(writer, "%s_sum %s\n", name, formatFloat(sum))
+	fmt.Fprintf(writer, "%s_count %d\n", name, histogram.TotalCount())
+}
+
+// latencySum оценивает сумму задержек в секундах по среднему гистограммы в микросекундах.
+func latencySum(histogram *hdrhistogram.Histogram) float64 {
+	return histogram.Mean() * float64(histogram.TotalCount()) / microsecondsPerSecond
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
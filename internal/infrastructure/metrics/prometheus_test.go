@@ -0,0 +1,97 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/metrics"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	sample := &domain.MetricsSample{
+		TotalLines:        4,
+		ParseErrors:       1,
+		TotalRequests:     3,
+		TotalResponseSize: 5150,
+		StatusCodes:       map[string]int64{"200": 2, "502": 1},
+		Methods:           map[string]int64{"GET": 2, "POST": 1},
+		Endpoints:         map[string]int64{"/users/{id}": 2, "/orders": 1},
+		ResponseSizes:     hdrhistogram.New(0, 1000000, 3),
+		RequestLatency:    hdrhistogram.New(1, 60000000, 3),
+		UpstreamLatency:   hdrhistogram.New(1, 60000000, 3),
+	}
+
+	for _, size := range []int64{100, 5000, 50} {
+		require.NoError(t, sample.ResponseSizes.RecordValue(size))
+	}
+
+	// Задержки в микросекундах: 4 мс, 200 мс и 3 с.
+	for _, latency := range []int64{4000, 200000, 3000000} {
+		require.NoError(t, sample.RequestLatency.RecordValue(latency))
+	}
+
+	server := httptest.NewServer(metrics.NewHandler(func() *domain.MetricsSample { return sample }))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + metrics.Path)
+	require.NoError(t, err)
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, metrics.ContentType, response.Header.Get("Content-Type"))
+
+	for _, line := range []string{
+		"# TYPE ngxstat_requests_total counter",
+		"ngxstat_lines_total 4",
+		"ngxstat_parse_errors_total 1",
+		"ngxstat_requests_total 3",
+		`ngxstat_requests_by_status_total{status="200"} 2`,
+		`ngxstat_requests_by_status_total{status="502"} 1`,
+		`ngxstat_requests_by_method_total{method="GET"} 2`,
+		`ngxstat_requests_by_endpoint_total{endpoint="/orders"} 1`,
+		`ngxstat_requests_by_endpoint_total{endpoint="/users/{id}"} 2`,
+		"ngxstat_response_bytes_total 5150",
+		"# TYPE ngxstat_response_size_bytes histogram",
+		`ngxstat_response_size_bytes_bucket{le="100"} 2`,
+		`ngxstat_response_size_bytes_bucket{le="10000"} 3`,
+		`ngxstat_response_size_bytes_bucket{le="+Inf"} 3`,
+		"ngxstat_response_size_bytes_sum 5150",
+		"ngxstat_response_size_bytes_count 3",
+		`ngxstat_request_duration_seconds_bucket{le="0.005"} 1`,
+		`ngxstat_request_duration_seconds_bucket{le="0.25"} 2`,
+		`ngxstat_request_duration_seconds_bucket{le="2.5"} 2`,
+		`ngxstat_request_duration_seconds_bucket{le="5"} 3`,
+		"ngxstat_request_duration_seconds_count 3",
+		"ngxstat_upstream_duration_seconds_count 0",
+	} {
+		assert.Contains(t, string(body), "\n"+line+"\n")
+	}
+
+	assert.Contains(t, string(body), "\nngxstat_request_duration_seconds_sum 3.20")
+}
+
+func TestWrite_EscapesLabels(t *testing.T) {
+	sample := &domain.MetricsSample{
+		Methods:         map[string]int64{`GET "x"\y` + "\n": 1},
+		ResponseSizes:   hdrhistogram.New(0, 1000, 3),
+		RequestLatency:  hdrhistogram.New(1, 1000, 3),
+		UpstreamLatency: hdrhistogram.New(1, 1000, 3),
+	}
+
+	var output strings.Builder
+
+	require.NoError(t, metrics.Write(&output, sample))
+	assert.Contains(t, output.String(), `ngxstat_requests_by_method_total{method="GET \"x\"\\y\n"} 1`)
+	assert.Contains(t, output.String(), "ngxstat_requests_total 0\n")
+}
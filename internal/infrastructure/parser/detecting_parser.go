@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const (
+	FormatCustom   = "custom"
+	FormatCombined = "combined"
+	FormatCommon   = "common"
+	FormatJSON     = "json"
+	FormatNginx    = "nginx"
+
+	DefaultDetectLines = 100
+)
+
+type Candidate struct {
+	Name   string
+	Parser LogParser
+}
+
+// DetectingParser выбирает формат отдельно для каждого файла: первые sampleSize строк
+// разбираются всеми кандидатами, после чего закрепляется кандидат с наибольшим числом
+// успешно разобранных строк. При равенстве побеждает кандидат, зарегистрированный раньше.
+type DetectingParser struct {
+	candidates []Candidate
+	sampleSize int
+	mu         sync.RWMutex
+	files      map[string]*detection
+}
+
+type detection struct {
+	hits    []int
+	sampled int
+	chosen  int
+}
+
+func NewDetectingParser(sampleSize int, candidates ...Candidate) *DetectingParser {
+	if sampleSize <= 0 {
+		sampleSize = DefaultDetectLines
+	}
+
+	return &DetectingParser{
+		candidates: candidates,
+		sampleSize: sampleSize,
+		files:      make(map[string]*detection),
+	}
+}
+
+// NewRun возвращает DetectingParser с теми же кандидатами, но без определённых форматов: иначе
+// повторный проход по тем же файлам разбирал бы первые строки уже закреплённым форматом.
+func (dp *DetectingParser) NewRun() LogParser {
+	return NewDetectingParser(dp.sampleSize, dp.candidates...)
+}
+
+// DefaultCandidates возвращает встроенные форматы; пользовательский log_format, если задан, проверяется первым.
+func DefaultCandidates(logFormat string) ([]Candidate, error) {
+	var candidates []Candidate
+
+	if logFormat != "" {
+		custom, err := NewFormatParser(logFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, Candidate{Name: FormatCustom, Parser: custom})
+	}
+
+	combined, err := NewFormatParser(CombinedLogFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	common, err := NewFormatParser(CommonLogFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(candidates,
+		Candidate{Name: FormatCombined, Parser: combined},
+		Candidate{Name: FormatCommon, Parser: common},
+		Candidate{Name: FormatJSON, Parser: NewJSONParser(DefaultJSONFieldMapping())},
+		Candidate{Name: FormatNginx, Parser: NginxParser{}},
+	), nil
+}
+
+func (dp *DetectingParser) ParseLogLine(record domain.LogRecord) (*domain.LogData, error) {
+	if chosen := dp.chosen(record.Source); chosen >= 0 {
+		return dp.parseWith(chosen, record)
+	}
+
+	var (
+		result   *domain.LogData
+		firstErr error
+	)
+
+	matched := make([]bool, len(dp.candidates))
+
+	for i := range dp.candidates {
+		logData, err := dp.parseWith(i, record)
+		if err != nil {
+			if firstErr == nil || errors.Is(err, ErrLogData) {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		matched[i] = true
+
+		if result == nil {
+			result = logData
+		}
+	}
+
+	dp.record(record.Source, matched)
+
+	if result == nil {
+		return nil, firstErr
+	}
+
+	return result, nil
+}
+
+func (dp *DetectingParser) parseWith(index int, record domain.LogRecord) (*domain.LogData, error) {
+	logData, err := dp.candidates[index].Parser.ParseLogLine(record)
+	if err != nil {
+		return nil, err
+	}
+
+	logData.Format = dp.candidates[index].Name
+
+	return logData, nil
+}
+
+// chosen берёт только блокировку на чтение: после определения формата файла её делят все горутины разбора.
+func (dp *DetectingParser) chosen(filename string) int {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+
+	if file, ok := dp.files[filename]; ok {
+		return file.chosen
+	}
+
+	return -1
+}
+
+func (dp *DetectingParser) record(filename string, matched []bool) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+
+	file, ok := dp.files[filename]
+	if !ok {
+		file = &detection{hits: make([]int, len(dp.candidates)), chosen: -1}
+		dp.files[filename] = file
+	}
+
+	if file.chosen >= 0 {
+		return
+	}
+
+	file.sampled++
+
+	for i, ok := range matched {
+		if ok {
+			file.hits[i]++
+		}
+	}
+
+	if file.sampled < dp.sampleSize {
+		return
+	}
+
+	best := 0
+	for i, hits := range file.hits {
+		if hits > file.hits[best] {
+			best = i
+		}
+	}
+
+	// Пока ни один кандидат не подошёл, продолжаем проверять всех.
+	if file.hits[best] > 0 {
+		file.chosen = best
+	}
+}
@@ -0,0 +1,68 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	combinedLine = `127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10 "-" "curl/8.0"`
+	commonLine   = `127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10`
+	jsonLine     = `{"time_local":"10/Oct/2023:13:55:36 +0000","remote_addr":"127.0.0.1","request":"GET / HTTP/1.1","status":"200"}`
+)
+
+func TestDetectingParser_ParseLogLine(t *testing.T) {
+	candidates, err := parser.DefaultCandidates("")
+	require.NoError(t, err)
+
+	detectingParser := parser.NewDetectingParser(2, candidates...)
+
+	t.Run("Detects Format Per File", func(t *testing.T) {
+		lines := map[string]string{"a.log": combinedLine, "b.log": commonLine, "c.json": jsonLine}
+		formats := map[string]string{"a.log": parser.FormatCombined, "b.log": parser.FormatCommon, "c.json": parser.FormatJSON}
+
+		for file, line := range lines {
+			for i := 0; i < 3; i++ {
+				logData, err := detectingParser.ParseLogLine(newRecord(file, line))
+
+				require.NoError(t, err, file)
+				assert.Equal(t, file, logData.Filename)
+				assert.Equal(t, formats[file], logData.Format, file)
+			}
+		}
+	})
+
+	t.Run("Locked Format Rejects Other Formats", func(t *testing.T) {
+		logData, err := detectingParser.ParseLogLine(newRecord("b.log", jsonLine))
+
+		assert.Equal(t, parser.ErrLogFormat, err)
+		assert.Nil(t, logData)
+	})
+
+	t.Run("Lenient Fallback For Extended Combined", func(t *testing.T) {
+		logData, err := detectingParser.ParseLogLine(newRecord("d.log", combinedLine+` "10.0.0.1"`))
+
+		require.NoError(t, err)
+		assert.Equal(t, parser.FormatNginx, logData.Format)
+	})
+
+	t.Run("Custom Format Has Priority", func(t *testing.T) {
+		candidates, err := parser.DefaultCandidates(parser.CommonLogFormat)
+		require.NoError(t, err)
+
+		logData, err := parser.NewDetectingParser(1, candidates...).ParseLogLine(newRecord("e.log", commonLine))
+
+		require.NoError(t, err)
+		assert.Equal(t, parser.FormatCustom, logData.Format)
+	})
+
+	t.Run("Unknown Format", func(t *testing.T) {
+		logData, err := detectingParser.ParseLogLine(newRecord("f.log", "INVALID LOG FORMAT"))
+
+		assert.Error(t, err)
+		assert.Nil(t, logData)
+	})
+}
@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const (
+	CombinedLogFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+	CommonLogFormat   = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`
+)
+
+// variablePatterns сужает группы захвата для переменных с заранее известным видом значения.
+var variablePatterns = map[string]string{
+	"status":          `(\d{3}|-)`,
+	"body_bytes_sent": `(\d+|-)`,
+	"bytes_sent":      `(\d+|-)`,
+	"msec":            `(\d+(?:\.\d+)?)`,
+	"request_time":    `(\d+(?:\.\d+)?|-)`,
+}
+
+// FormatParser разбирает строки лога, описанные произвольной директивой nginx log_format.
+type FormatParser struct {
+	pattern   *regexp.Regexp
+	variables []string
+	hasIP     bool
+}
+
+func NewFormatParser(logFormat string) (*FormatParser, error) {
+	variables, expr, err := compileLogFormat(logFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateVariables(logFormat, variables); err != nil {
+		return nil, err
+	}
+
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, &domain.InvalidLogFormatError{Format: logFormat, Reason: err.Error()}
+	}
+
+	return &FormatParser{
+		pattern:   pattern,
+		variables: variables,
+		hasIP:     containsAny(variables, "remote_addr"),
+	}, nil
+}
+
+func (fp *FormatParser) ParseLogLine(record domain.LogRecord) (*domain.LogData, error) {
+	matches := fp.pattern.FindStringSubmatch(strings.TrimRight(string(record.Raw), "\r\n"))
+	if matches == nil {
+		return nil, ErrLogFormat
+	}
+
+	logData := &domain.LogData{Filename: record.Source}
+
+	for i, name := range fp.variables {
+		if !fp.setField(logData, name, matches[i+1]) {
+			return nil, ErrLogData
+		}
+	}
+
+	if (fp.hasIP && logData.IPAddress == "") || !isValidRequestFields(logData) || !isValidStatusCode(logData.StatusCode) {
+		return nil, ErrLogData
+	}
+
+	return logData, nil
+}
+
+func (fp *FormatParser) setField(logData *domain.LogData, name, value string) bool {
+	switch name {
+	case "remote_addr":
+		logData.IPAddress = ParseIPAddress(value)
+	case "remote_user":
+		logData.RemoteUser = ParseRemoteUser(value)
+	case "time_local":
+		logData.Timestamp = ParseTimestamp(value)
+	case "time_iso8601":
+		logData.Timestamp = ParseISO8601Timestamp(value)
+	case "msec":
+		logData.Timestamp = ParseMsecTimestamp(value)
+	case "request":
+		parts := strings.Fields(value)
+		if len(parts) < 2 {
+			return false
+		}
+
+		logData.Method, logData.Resource = parts[0], parts[1]
+	case "request_method":
+		logData.Method = ParseOptionalField(value)
+	case "request_uri", "uri":
+		if logData.Resource == "" {
+			logData.Resource = ParseOptionalField(value)
+		}
+	case "status":
+		logData.StatusCode = ParseStatusCode(value)
+	case "body_bytes_sent", "bytes_sent":
+		if value == "-" {
+			return true
+		}
+
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+
+		logData.ResponseSize = size
+	case "request_time":
+		logData.RequestTime, logData.HasRequestTime = ParseLatency(value)
+	case "upstream_response_time":
+		logData.UpstreamResponseTime, logData.HasUpstreamTime = ParseLatency(value)
+	case "http_referer":
+		logData.Referer = ParseOptionalField(value)
+	case "http_user_agent":
+		logData.UserAgent = ParseOptionalField(value)
+	default:
+		if logData.Extras == nil {
+			logData.Extras = make(map[string]string)
+		}
+
+		logData.Extras[name] = ParseOptionalField(value)
+	}
+
+	return true
+}
+
+// ParseLatency разбирает время в секундах с миллисекундами. В $upstream_response_time nginx
+// перечисляет времена так же, как адреса в $upstream_addr: через запятую — попытки обращения
+// к серверам одной группы upstream, они идут друг за другом и складываются; через двоеточие —
+// группы после внутреннего перенаправления (X-Accel-Redirect, error_page). Ответ клиенту отдала
+// последняя группа, поэтому берётся её время: сумма по группам учла бы прежние ответы,
+// которые клиент не получил. Группа без времён («-») пропускается.
+func ParseLatency(field string) (time.Duration, bool) {
+	groups := strings.Split(field, ":")
+
+	for i := len(groups) - 1; i >= 0; i-- {
+		if total, found := sumLatencies(groups[i]); found {
+			return total, true
+		}
+	}
+
+	return 0, false
+}
+
+// sumLatencies складывает времена попыток одной группы upstream.
+func sumLatencies(group string) (time.Duration, bool) {
+	var total time.Duration
+
+	found := false
+
+	for _, part := range strings.FieldsFunc(group, func(r rune) bool { return r == ',' || r == ' ' }) {
+		seconds, err := strconv.ParseFloat(part, 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+
+		total += time.Duration(seconds * float64(time.Second))
+		found = true
+	}
+
+	return total, found
+}
+
+func ParseISO8601Timestamp(field string) time.Time {
+	timestamp, err := time.Parse(time.RFC3339, field)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return timestamp
+}
+
+func ParseMsecTimestamp(field string) time.Time {
+	msec, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(int64(msec * 1000)).UTC()
+}
+
+// compileLogFormat переводит log_format в регулярное выражение и возвращает
+// имена переменных в порядке их групп захвата.
+func compileLogFormat(logFormat string) (variables []string, expr string, err error) {
+	var builder strings.Builder
+
+	builder.WriteString("^")
+
+	for i := 0; i < len(logFormat); {
+		if logFormat[i] != '$' {
+			next := strings.IndexByte(logFormat[i:], '$')
+			if next == -1 {
+				next = len(logFormat) - i
+			}
+
+			builder.WriteString(regexp.QuoteMeta(logFormat[i : i+next]))
+			i += next
+
+			continue
+		}
+
+		name, end := readVariable(logFormat, i+1)
+		if name == "" {
+			return nil, "", &domain.InvalidLogFormatError{Format: logFormat, Reason: "пустое имя переменной на позиции " + strconv.Itoa(i)}
+		}
+
+		variables = append(variables, name)
+		i = end
+
+		switch {
+		case variablePatterns[name] != "":
+			builder.WriteString(variablePatterns[name])
+		case i == len(logFormat):
+			builder.WriteString("(.*)")
+		case logFormat[i] == '$':
+			builder.WriteString("(.*?)")
+		default:
+			builder.WriteString("([^" + regexp.QuoteMeta(logFormat[i:i+1]) + "]*)")
+		}
+	}
+
+	builder.WriteString("$")
+
+	return variables, builder.String(), nil
+}
+
+func readVariable(logFormat string, start int) (name string, end int) {
+	if start < len(logFormat) && logFormat[start] == '{' {
+		closing := strings.IndexByte(logFormat[start:], '}')
+		if closing == -1 {
+			return "", start
+		}
+
+		return logFormat[start+1 : start+closing], start + closing + 1
+	}
+
+	end = start
+	for end < len(logFormat) && isVariableChar(logFormat[end]) {
+		end++
+	}
+
+	return logFormat[start:end], end
+}
+
+func isVariableChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func validateVariables(logFormat string, variables []string) error {
+	if !containsAny(variables, "time_local", "time_iso8601", "msec") {
+		return &domain.InvalidLogFormatError{Format: logFormat, Reason: "нет переменной времени ($time_local, $time_iso8601 или $msec)"}
+	}
+
+	if !containsAny(variables, "request") && !(containsAny(variables, "request_method") && containsAny(variables, "request_uri", "uri")) {
+		return &domain.InvalidLogFormatError{Format: logFormat, Reason: "нет переменной запроса ($request или $request_method и $request_uri)"}
+	}
+
+	if !containsAny(variables, "status") {
+		return &domain.InvalidLogFormatError{Format: logFormat, Reason: "нет переменной $status"}
+	}
+
+	return nil
+}
+
+func containsAny(variables []string, names ...string) bool {
+	for _, variable := range variables {
+		for _, name := range names {
+			if variable == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatParser_ParseLogLine(t *testing.T) {
+	t.Run("Combined Format", func(t *testing.T) {
+		formatParser, err := parser.NewFormatParser(parser.CombinedLogFormat)
+		require.NoError(t, err)
+
+		logLine := `127.0.0.1 - admin [10/Oct/2023:13:55:36 +0000] "GET /index.html HTTP/1.1" 200 1234 ` +
+			`"http://example.com" "Mozilla/5.0"` + "\n"
+		logData, err := formatParser.ParseLogLine(newRecord("access.log", logLine))
+
+		require.NoError(t, err)
+		assert.Equal(t, "access.log", logData.Filename)
+		assert.Equal(t, "127.0.0.1", logData.IPAddress)
+		assert.Equal(t, "admin", logData.RemoteUser)
+		assert.Equal(t, "GET", logData.Method)
+		assert.Equal(t, "/index.html", logData.Resource)
+		assert.Equal(t, "200", logData.StatusCode)
+		assert.Equal(t, int64(1234), logData.ResponseSize)
+		assert.Equal(t, "http://example.com", logData.Referer)
+		assert.Equal(t, "Mozilla/5.0", logData.UserAgent)
+
+		expectedTime, _ := time.Parse(parser.NginxDateFormat, "10/Oct/2023:13:55:36 +0000")
+		assert.Equal(t, expectedTime, logData.Timestamp)
+	})
+
+	t.Run("Custom Format With Extras", func(t *testing.T) {
+		formatParser, err := parser.NewFormatParser(
+			`$host ${remote_addr} [$time_iso8601] "$request_method $request_uri" $status $bytes_sent rt=$request_time ua="$upstream_addr"`,
+		)
+		require.NoError(t, err)
+
+		logLine := `example.com 10.0.0.1 [2023-10-10T13:55:36+00:00] "POST /api/v1/items" 201 512 rt=0.042 ua="10.1.1.1:8080"`
+		logData, err := formatParser.ParseLogLine(newRecord("access.log", logLine))
+
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.1", logData.IPAddress)
+		assert.Equal(t, "POST", logData.Method)
+		assert.Equal(t, "/api/v1/items", logData.Resource)
+		assert.Equal(t, "201", logData.StatusCode)
+		assert.Equal(t, int64(512), logData.ResponseSize)
+		assert.Equal(t, map[string]string{"host": "example.com", "upstream_addr": "10.1.1.1:8080"}, logData.Extras)
+		assert.True(t, logData.HasRequestTime)
+		assert.Equal(t, 42*time.Millisecond, logData.RequestTime)
+		assert.False(t, logData.HasUpstreamTime)
+		assert.Equal(t, time.Date(2023, 10, 10, 13, 55, 36, 0, time.UTC), logData.Timestamp.UTC())
+	})
+
+	t.Run("Upstream Response Time", func(t *testing.T) {
+		formatParser, err := parser.NewFormatParser(parser.CommonLogFormat + ` $request_time "$upstream_response_time"`)
+		require.NoError(t, err)
+
+		logLine := `127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 502 10 1.500 "0.500, 0.750"`
+		logData, err := formatParser.ParseLogLine(newRecord("access.log", logLine))
+
+		require.NoError(t, err)
+		assert.Equal(t, 1500*time.Millisecond, logData.RequestTime)
+		assert.True(t, logData.HasUpstreamTime)
+		assert.Equal(t, 1250*time.Millisecond, logData.UpstreamResponseTime)
+
+		logLine = `127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10 0.001 "-"`
+		logData, err = formatParser.ParseLogLine(newRecord("access.log", logLine))
+
+		require.NoError(t, err)
+		assert.False(t, logData.HasUpstreamTime)
+	})
+
+	t.Run("Format Without Remote Address", func(t *testing.T) {
+		formatParser, err := parser.NewFormatParser(`[$time_local] "$request" $status`)
+		require.NoError(t, err)
+
+		logData, err := formatParser.ParseLogLine(newRecord("access.log", `[10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 404`))
+
+		require.NoError(t, err)
+		assert.Equal(t, "", logData.IPAddress)
+		assert.Equal(t, "404", logData.StatusCode)
+	})
+
+	t.Run("Line Does Not Match Format", func(t *testing.T) {
+		formatParser, err := parser.NewFormatParser(parser.CommonLogFormat)
+		require.NoError(t, err)
+
+		logLine := `127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10 "-" "curl/8.0"`
+		logData, err := formatParser.ParseLogLine(newRecord("access.log", logLine))
+
+		assert.Equal(t, parser.ErrLogFormat, err)
+		assert.Nil(t, logData)
+	})
+
+	t.Run("Invalid Field Values", func(t *testing.T) {
+		formatParser, err := parser.NewFormatParser(parser.CommonLogFormat)
+		require.NoError(t, err)
+
+		logLine := `127.0.0.1 - - [INVALID_TIMESTAMP] "GET / HTTP/1.1" 200 10`
+		logData, err := formatParser.ParseLogLine(newRecord("access.log", logLine))
+
+		assert.Equal(t, parser.ErrLogData, err)
+		assert.Nil(t, logData)
+	})
+}
+
+func TestParseLatency(t *testing.T) {
+	tests := map[string]struct {
+		field    string
+		expected time.Duration
+		found    bool
+	}{
+		"single":               {field: "0.120", expected: 120 * time.Millisecond, found: true},
+		"retries are summed":   {field: "0.500, 0.750", expected: 1250 * time.Millisecond, found: true},
+		"last group after :":   {field: "0.500, 0.750 : 0.100", expected: 100 * time.Millisecond, found: true},
+		"last group retries":   {field: "0.300 : 0.100, 0.200", expected: 300 * time.Millisecond, found: true},
+		"empty last group":     {field: "0.300 : -", expected: 300 * time.Millisecond, found: true},
+		"failed retry ignored": {field: "-, 0.050", expected: 50 * time.Millisecond, found: true},
+		"no upstream":          {field: "-"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			latency, found := parser.ParseLatency(tt.field)
+
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, latency)
+		})
+	}
+}
+
+func TestNewFormatParser_InvalidFormat(t *testing.T) {
+	formats := []string{
+		`$remote_addr "$request" $status`,
+		`[$time_local] $remote_addr $status`,
+		`[$time_local] "$request"`,
+		`[$time_local] "$request" $status ${unterminated`,
+	}
+
+	for _, format := range formats {
+		_, err := parser.NewFormatParser(format)
+
+		var formatErr *domain.InvalidLogFormatError
+		assert.ErrorAs(t, err, &formatErr, format)
+	}
+}
@@ -0,0 +1,228 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const (
+	ISO8601TimeLayout = "iso8601"
+	UnixTimeLayout    = "unix"
+)
+
+// JSONFieldMapping задаёт, из каких ключей JSON-строки берутся поля domain.LogData.
+// Пустое имя ключа отключает поле.
+type JSONFieldMapping struct {
+	Timestamp            string
+	TimestampLayout      string
+	IPAddress            string
+	RemoteUser           string
+	Request              string
+	Method               string
+	Resource             string
+	Status               string
+	ResponseSize         string
+	Referer              string
+	UserAgent            string
+	RequestTime          string
+	UpstreamResponseTime string
+}
+
+// DefaultJSONFieldMapping соответствует log_format с escape=json, где ключи совпадают с именами переменных nginx.
+func DefaultJSONFieldMapping() JSONFieldMapping {
+	return JSONFieldMapping{
+		Timestamp:            "time_local",
+		TimestampLayout:      NginxDateFormat,
+		IPAddress:            "remote_addr",
+		RemoteUser:           "remote_user",
+		Request:              "request",
+		Method:               "request_method",
+		Resource:             "request_uri",
+		Status:               "status",
+		ResponseSize:         "body_bytes_sent",
+		Referer:              "http_referer",
+		UserAgent:            "http_user_agent",
+		RequestTime:          "request_time",
+		UpstreamResponseTime: "upstream_response_time",
+	}
+}
+
+// ParseJSONFieldMapping применяет к маппингу по умолчанию переопределения вида "timestamp=ts,status=code".
+func ParseJSONFieldMapping(spec, timestampLayout string) (JSONFieldMapping, error) {
+	mapping := DefaultJSONFieldMapping()
+	fields := mapping.fields()
+
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, key, ok := strings.Cut(pair, "=")
+		target, known := fields[strings.TrimSpace(name)]
+
+		if !ok || !known {
+			return JSONFieldMapping{}, &domain.InvalidFieldMappingError{Mapping: pair}
+		}
+
+		*target = strings.TrimSpace(key)
+	}
+
+	if timestampLayout != "" {
+		mapping.TimestampLayout = timestampLayout
+	}
+
+	return mapping, nil
+}
+
+func (m *JSONFieldMapping) fields() map[string]*string {
+	return map[string]*string{
+		"timestamp":              &m.Timestamp,
+		"ip":                     &m.IPAddress,
+		"remote_user":            &m.RemoteUser,
+		"request":                &m.Request,
+		"method":                 &m.Method,
+		"resource":               &m.Resource,
+		"status":                 &m.Status,
+		"size":                   &m.ResponseSize,
+		"referer":                &m.Referer,
+		"agent":                  &m.UserAgent,
+		"request_time":           &m.RequestTime,
+		"upstream_response_time": &m.UpstreamResponseTime,
+	}
+}
+
+// JSONParser разбирает строки лога в формате JSON, по одному объекту на строку.
+type JSONParser struct {
+	mapping JSONFieldMapping
+}
+
+func NewJSONParser(mapping JSONFieldMapping) *JSONParser {
+	return &JSONParser{mapping: mapping}
+}
+
+func (jp *JSONParser) ParseLogLine(record domain.LogRecord) (*domain.LogData, error) {
+	decoder := json.NewDecoder(bytes.NewReader(record.Raw))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, ErrLogFormat
+	}
+
+	// После объекта допустимы только пробелы: строка вида {"status":200}garbage — не запись лога.
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, ErrLogFormat
+	}
+
+	values := make(map[string]string, len(document))
+	for key, value := range document {
+		values[key] = jsonValueToString(value)
+	}
+
+	logData, ok := jp.buildLogData(record.Source, values)
+	if !ok {
+		return nil, ErrLogData
+	}
+
+	if (jp.mapping.IPAddress != "" && logData.IPAddress == "") ||
+		!isValidRequestFields(logData) || !isValidStatusCode(logData.StatusCode) {
+		return nil, ErrLogData
+	}
+
+	return logData, nil
+}
+
+func (jp *JSONParser) buildLogData(filename string, values map[string]string) (*domain.LogData, bool) {
+	m := jp.mapping
+	logData := &domain.LogData{
+		Filename:   filename,
+		Timestamp:  ParseTimestampWithLayout(take(values, m.Timestamp), m.TimestampLayout),
+		IPAddress:  ParseIPAddress(take(values, m.IPAddress)),
+		RemoteUser: ParseRemoteUser(take(values, m.RemoteUser)),
+		Method:     ParseOptionalField(take(values, m.Method)),
+		Resource:   ParseOptionalField(take(values, m.Resource)),
+		StatusCode: ParseStatusCode(take(values, m.Status)),
+		Referer:    ParseOptionalField(take(values, m.Referer)),
+		UserAgent:  ParseOptionalField(take(values, m.UserAgent)),
+	}
+
+	if request := take(values, m.Request); request != "" {
+		parts := strings.Fields(request)
+		if len(parts) < 2 {
+			return nil, false
+		}
+
+		logData.Method, logData.Resource = parts[0], parts[1]
+	}
+
+	if size := take(values, m.ResponseSize); size != "" && size != "-" {
+		parsedSize, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		logData.ResponseSize = parsedSize
+	}
+
+	logData.RequestTime, logData.HasRequestTime = ParseLatency(take(values, m.RequestTime))
+	logData.UpstreamResponseTime, logData.HasUpstreamTime = ParseLatency(take(values, m.UpstreamResponseTime))
+
+	for key, value := range values {
+		if logData.Extras == nil {
+			logData.Extras = make(map[string]string, len(values))
+		}
+
+		logData.Extras[key] = ParseOptionalField(value)
+	}
+
+	return logData, true
+}
+
+// take возвращает значение ключа и удаляет его, чтобы оставшиеся ключи попали в Extras.
+func take(values map[string]string, key string) string {
+	if key == "" {
+		return ""
+	}
+
+	value := values[key]
+	delete(values, key)
+
+	return value
+}
+
+func jsonValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func ParseTimestampWithLayout(field, layout string) time.Time {
+	switch layout {
+	case ISO8601TimeLayout:
+		return ParseISO8601Timestamp(field)
+	case UnixTimeLayout:
+		return ParseMsecTimestamp(field)
+	}
+
+	timestamp, err := time.Parse(layout, field)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return timestamp
+}
@@ -0,0 +1,97 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONParser_ParseLogLine(t *testing.T) {
+	t.Run("Default Mapping", func(t *testing.T) {
+		jsonParser := parser.NewJSONParser(parser.DefaultJSONFieldMapping())
+
+		logLine := `{"time_local":"10/Oct/2023:13:55:36 +0000","remote_addr":"127.0.0.1","remote_user":"",` +
+			`"request":"GET /index.html HTTP/1.1","status":200,"body_bytes_sent":"1234","http_referer":"http://example.com",` +
+			`"http_user_agent":"Mozilla/5.0","request_time":"0.120","upstream_response_time":"-","host":"example.com"}` + "\n"
+		logData, err := jsonParser.ParseLogLine(newRecord("access.json", logLine))
+
+		require.NoError(t, err)
+		assert.Equal(t, "access.json", logData.Filename)
+		assert.Equal(t, "127.0.0.1", logData.IPAddress)
+		assert.Equal(t, "", logData.RemoteUser)
+		assert.Equal(t, "GET", logData.Method)
+		assert.Equal(t, "/index.html", logData.Resource)
+		assert.Equal(t, "200", logData.StatusCode)
+		assert.Equal(t, int64(1234), logData.ResponseSize)
+		assert.Equal(t, "http://example.com", logData.Referer)
+		assert.Equal(t, "Mozilla/5.0", logData.UserAgent)
+		assert.Equal(t, 120*time.Millisecond, logData.RequestTime)
+		assert.False(t, logData.HasUpstreamTime)
+		assert.Equal(t, map[string]string{"host": "example.com"}, logData.Extras)
+
+		expectedTime, _ := time.Parse(parser.NginxDateFormat, "10/Oct/2023:13:55:36 +0000")
+		assert.Equal(t, expectedTime, logData.Timestamp)
+	})
+
+	t.Run("Custom Mapping", func(t *testing.T) {
+		mapping, err := parser.ParseJSONFieldMapping("timestamp=ts, ip=client, request=, method=verb,resource=path,status=code", "unix")
+		require.NoError(t, err)
+
+		jsonParser := parser.NewJSONParser(mapping)
+		logLine := `{"ts":1696946136.5,"client":"10.0.0.1","verb":"POST","path":"/api","code":"503"}`
+		logData, err := jsonParser.ParseLogLine(newRecord("access.json", logLine))
+
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.1", logData.IPAddress)
+		assert.Equal(t, "POST", logData.Method)
+		assert.Equal(t, "/api", logData.Resource)
+		assert.Equal(t, "503", logData.StatusCode)
+		assert.Equal(t, time.UnixMilli(1696946136500).UTC(), logData.Timestamp)
+	})
+
+	t.Run("Not JSON", func(t *testing.T) {
+		jsonParser := parser.NewJSONParser(parser.DefaultJSONFieldMapping())
+		logData, err := jsonParser.ParseLogLine(newRecord("access.json", `127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10`))
+
+		assert.Equal(t, parser.ErrLogFormat, err)
+		assert.Nil(t, logData)
+	})
+
+	t.Run("Trailing Data", func(t *testing.T) {
+		jsonParser := parser.NewJSONParser(parser.DefaultJSONFieldMapping())
+		logLine := `{"time_local":"10/Oct/2023:13:55:36 +0000","remote_addr":"127.0.0.1","request":"GET / HTTP/1.1",` +
+			`"status":200,"body_bytes_sent":"10"}`
+
+		for _, trailer := range []string{"garbage", `{"status":500}`, `,`} {
+			logData, err := jsonParser.ParseLogLine(newRecord("access.json", logLine+trailer))
+
+			assert.Equal(t, parser.ErrLogFormat, err, trailer)
+			assert.Nil(t, logData)
+		}
+
+		logData, err := jsonParser.ParseLogLine(newRecord("access.json", logLine+" \r"))
+		require.NoError(t, err)
+		assert.Equal(t, "200", logData.StatusCode)
+	})
+
+	t.Run("Missing Required Fields", func(t *testing.T) {
+		jsonParser := parser.NewJSONParser(parser.DefaultJSONFieldMapping())
+		logData, err := jsonParser.ParseLogLine(newRecord("access.json", `{"remote_addr":"127.0.0.1","status":"200"}`))
+
+		assert.Equal(t, parser.ErrLogData, err)
+		assert.Nil(t, logData)
+	})
+}
+
+func TestParseJSONFieldMapping_Invalid(t *testing.T) {
+	for _, spec := range []string{"unknown=key", "timestamp"} {
+		_, err := parser.ParseJSONFieldMapping(spec, "")
+
+		var mappingErr *domain.InvalidFieldMappingError
+		assert.ErrorAs(t, err, &mappingErr, spec)
+	}
+}
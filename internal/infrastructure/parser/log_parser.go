@@ -1,7 +1,49 @@
 package parser
 
-import "github.com/4domm/ngxstat/internal/domain"
+import (
+	"errors"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const (
+	ErrorKindFormat  = "format"
+	ErrorKindData    = "data"
+	ErrorKindRead    = "read"
+	ErrorKindUnknown = "unknown"
+)
 
 type LogParser interface {
-	ParseLogLine(string) (*domain.LogData, error)
+	ParseLogLine(domain.LogRecord) (*domain.LogData, error)
+}
+
+// RunParser — парсер, который копит состояние по ходу разбора (например, форматы файлов в DetectingParser).
+// NewRun возвращает парсер с чистым состоянием для нового прохода по логам.
+type RunParser interface {
+	LogParser
+	NewRun() LogParser
+}
+
+// ForRun возвращает парсер для одного прохода по логам: у RunParser — новый, остальные парсеры
+// состояния не хранят и возвращаются как есть.
+func ForRun(logParser LogParser) LogParser {
+	if runParser, ok := logParser.(RunParser); ok {
+		return runParser.NewRun()
+	}
+
+	return logParser
+}
+
+// ErrorKind сводит ошибку разбора к короткому имени для статистики.
+func ErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrLogFormat):
+		return ErrorKindFormat
+	case errors.Is(err, ErrLogData):
+		return ErrorKindData
+	case errors.Is(err, domain.ErrReadLog):
+		return ErrorKindRead
+	default:
+		return ErrorKindUnknown
+	}
 }
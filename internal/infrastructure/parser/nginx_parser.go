@@ -2,10 +2,8 @@ package parser
 
 import (
 	"errors"
-	"fmt"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/4domm/ngxstat/internal/domain"
@@ -23,19 +21,14 @@ var (
 type NginxParser struct {
 }
 
-func (np NginxParser) ParseLogLine(logLine string) (*domain.LogData, error) {
-	data := strings.Split(logLine, "$")
-	if len(data) != 2 {
-		return nil, ErrLogFormat
-	}
-
-	matches := pattern.FindStringSubmatch(data[1])
+func (np NginxParser) ParseLogLine(record domain.LogRecord) (*domain.LogData, error) {
+	matches := pattern.FindStringSubmatch(string(record.Raw))
 	if matches == nil {
 		return nil, ErrLogFormat
 	}
 
 	logData := &domain.LogData{
-		Filename:     data[0],
+		Filename:     record.Source,
 		IPAddress:    ParseIPAddress(matches[1]),
 		RemoteUser:   ParseRemoteUser(matches[3]),
 		Timestamp:    ParseTimestamp(matches[4]),
@@ -104,8 +97,11 @@ func ParseOptionalField(field string) string {
 }
 
 func isValidRequiredFields(logData *domain.LogData) bool {
-	return logData.IPAddress != "" &&
-		!logData.Timestamp.IsZero() &&
+	return logData.IPAddress != "" && isValidRequestFields(logData)
+}
+
+func isValidRequestFields(logData *domain.LogData) bool {
+	return !logData.Timestamp.IsZero() &&
 		logData.Method != "" &&
 		logData.Resource != "" &&
 		logData.StatusCode != "" &&
@@ -120,34 +116,3 @@ func isValidStatusCode(statusCode string) bool {
 
 	return strStatusCode >= 100 && strStatusCode < 600
 }
-
-func GetFilterFunction(filterField domain.FilterField, filterValue string) func(*domain.LogData) bool {
-	switch filterField {
-	case domain.AGENT:
-		return func(log *domain.LogData) bool {
-			return log.UserAgent != "" && strings.EqualFold(log.UserAgent, filterValue)
-		}
-	case domain.METHOD:
-		return func(log *domain.LogData) bool {
-			return log.Method != "" && strings.EqualFold(log.Method, filterValue)
-		}
-	case domain.STATUS:
-		return func(log *domain.LogData) bool { return log.StatusCode == filterValue }
-	case domain.RESOURCE:
-		return func(log *domain.LogData) bool {
-			return log.Resource != "" && strings.EqualFold(log.Resource, filterValue)
-		}
-	case domain.REFERER:
-		return func(log *domain.LogData) bool {
-			return log.Referer != "" && strings.EqualFold(log.Referer, filterValue)
-		}
-	case domain.REMOTEUSER:
-		return func(log *domain.LogData) bool {
-			return log.RemoteUser != "" && strings.EqualFold(log.RemoteUser, filterValue)
-		}
-	case domain.SIZE:
-		return func(log *domain.LogData) bool { return fmt.Sprintf("%d", log.ResponseSize) == filterValue }
-	}
-
-	return nil
-}
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/4domm/ngxstat/internal/domain"
 	"github.com/4domm/ngxstat/internal/infrastructure/parser"
 	"github.com/stretchr/testify/assert"
 )
@@ -12,8 +13,8 @@ func TestParseLogLine(t *testing.T) {
 	nginxParser := parser.NginxParser{}
 
 	t.Run("Valid Log Line", func(t *testing.T) {
-		logLine := `$127.0.0.1 - admin [10/Oct/2023:13:55:36 +0000] "GET /index.html HTTP/1.1" 200 1234 "http://example.com" "Mozilla/5.0"`
-		logData, err := nginxParser.ParseLogLine(logLine)
+		logLine := `127.0.0.1 - admin [10/Oct/2023:13:55:36 +0000] "GET /index.html HTTP/1.1" 200 1234 "http://example.com" "Mozilla/5.0"`
+		logData, err := nginxParser.ParseLogLine(newRecord("", logLine))
 
 		assert.NoError(t, err)
 		assert.NotNil(t, logData)
@@ -31,8 +32,8 @@ func TestParseLogLine(t *testing.T) {
 	})
 
 	t.Run("Log Line Missing Optional Fields", func(t *testing.T) {
-		logLine := `$192.168.1.1 - - [11/Nov/2023:10:10:10 +0000] "POST /submit HTTP/1.1" 201 0`
-		logData, err := nginxParser.ParseLogLine(logLine)
+		logLine := `192.168.1.1 - - [11/Nov/2023:10:10:10 +0000] "POST /submit HTTP/1.1" 201 0`
+		logData, err := nginxParser.ParseLogLine(newRecord("", logLine))
 
 		assert.NoError(t, err)
 		assert.NotNil(t, logData)
@@ -51,7 +52,7 @@ func TestParseLogLine(t *testing.T) {
 
 	t.Run("Invalid Log Line Format", func(t *testing.T) {
 		logLine := `INVALID LOG FORMAT`
-		logData, err := nginxParser.ParseLogLine(logLine)
+		logData, err := nginxParser.ParseLogLine(newRecord("", logLine))
 
 		assert.Error(t, err)
 		assert.Equal(t, parser.ErrLogFormat, err)
@@ -59,8 +60,8 @@ func TestParseLogLine(t *testing.T) {
 	})
 
 	t.Run("Invalid Timestamp Format", func(t *testing.T) {
-		logLine := `$127.0.0.1 - admin [INVALID_TIMESTAMP] "GET /index.html HTTP/1.1" 200 1234`
-		logData, err := nginxParser.ParseLogLine(logLine)
+		logLine := `127.0.0.1 - admin [INVALID_TIMESTAMP] "GET /index.html HTTP/1.1" 200 1234`
+		logData, err := nginxParser.ParseLogLine(newRecord("", logLine))
 
 		assert.Error(t, err)
 		assert.Equal(t, parser.ErrLogData, err)
@@ -69,7 +70,7 @@ func TestParseLogLine(t *testing.T) {
 
 	t.Run("Missing Required Fields", func(t *testing.T) {
 		logLine := `- - - [-] "-" "-" - -`
-		logData, err := nginxParser.ParseLogLine(logLine)
+		logData, err := nginxParser.ParseLogLine(newRecord("", logLine))
 
 		assert.Error(t, err)
 		assert.Equal(t, parser.ErrLogFormat, err)
@@ -77,8 +78,8 @@ func TestParseLogLine(t *testing.T) {
 	})
 
 	t.Run("Invalid Status Code", func(t *testing.T) {
-		logLine := `$127.0.0.1 - admin [10/Oct/2023:13:55:36 +0000] "GET /index.html HTTP/1.1" 9999 1234`
-		logData, err := nginxParser.ParseLogLine(logLine)
+		logLine := `127.0.0.1 - admin [10/Oct/2023:13:55:36 +0000] "GET /index.html HTTP/1.1" 9999 1234`
+		logData, err := nginxParser.ParseLogLine(newRecord("", logLine))
 
 		assert.Error(t, err)
 		assert.Equal(t, parser.ErrLogFormat, err)
@@ -86,11 +87,25 @@ func TestParseLogLine(t *testing.T) {
 	})
 
 	t.Run("Negative Response Size", func(t *testing.T) {
-		logLine := `$127.0.0.1 - admin [10/Oct/2023:13:55:36 +0000] "GET /index.html HTTP/1.1" 200 -1234`
-		logData, err := nginxParser.ParseLogLine(logLine)
+		logLine := `127.0.0.1 - admin [10/Oct/2023:13:55:36 +0000] "GET /index.html HTTP/1.1" 200 -1234`
+		logData, err := nginxParser.ParseLogLine(newRecord("", logLine))
 
 		assert.Error(t, err)
 		assert.Equal(t, parser.ErrLogFormat, err)
 		assert.Nil(t, logData)
 	})
+	t.Run("Dollar Sign In Line", func(t *testing.T) {
+		logLine := `127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /search?q=$price HTTP/1.1" 200 10 "-" "agent$1"`
+		logData, err := nginxParser.ParseLogLine(newRecord("access.log", logLine))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "access.log", logData.Filename)
+		assert.Equal(t, "/search?q=$price", logData.Resource)
+		assert.Equal(t, "agent$1", logData.UserAgent)
+	})
+}
+
+// newRecord оборачивает строку лога в запись, как её отдаёт читатель.
+func newRecord(source, line string) domain.LogRecord {
+	return domain.LogRecord{Source: source, Line: 1, Raw: []byte(line)}
 }
@@ -0,0 +1,114 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// Decompress определяет сжатие по сигнатуре в начале потока и возвращает распакованный поток.
+// Несжатые данные возвращаются как есть. Close освобождает только распаковщик, но не исходный поток.
+func Decompress(source io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(source)
+
+	// Ошибка Peek означает короткий поток, сигнатуры ниже сравниваются с тем, что удалось прочитать.
+	header, _ := buffered.Peek(len(xzMagic))
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(header, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(buffered)), nil
+	case bytes.HasPrefix(header, zstdMagic):
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.IOReadCloser(), nil
+	case bytes.HasPrefix(header, xzMagic):
+		decoder, err := xz.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+
+		return io.NopCloser(decoder), nil
+	}
+
+	return io.NopCloser(buffered), nil
+}
+
+// isCompressed сообщает, начинается ли header с сигнатуры одного из поддерживаемых архивов.
+func isCompressed(header []byte) bool {
+	for _, magic := range [][]byte{gzipMagic, bzip2Magic, zstdMagic, xzMagic} {
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LinesBufferSize — буфер канала строк: разбор идёт в нескольких горутинах, и без буфера они
+// простаивают, ожидая каждую строку от читателя.
+const LinesBufferSize = 1024
+
+// readLines отправляет строки потока в канал вместе с именем источника, номером строки и смещением
+// в распакованных данных; последняя строка без перевода строки тоже передаётся. Чтение прекращается
+// на первой ошибке, в том числе битом архиве; ошибка уходит в канал записью с Err.
+func readLines(source io.Reader, name string, lines chan<- domain.LogRecord) {
+	number, offset, err := sendLines(source, name, lines)
+	if err != nil {
+		sendReadError(lines, domain.LogRecord{Source: name, Offset: offset, Line: number + 1}, err)
+	}
+}
+
+// sendReadError передаёт ошибку чтения источника отдельной записью, чтобы повреждённый архив
+// попал в статистику ошибок разбора, а не пропал молча.
+func sendReadError(lines chan<- domain.LogRecord, record domain.LogRecord, err error) {
+	record.Err = fmt.Errorf("%w: %w", domain.ErrReadLog, err)
+	lines <- record
+}
+
+// sendLines возвращает номер последней отправленной строки и смещение, на котором остановилось чтение.
+func sendLines(source io.Reader, name string, lines chan<- domain.LogRecord) (number, offset int64, err error) {
+	data, err := Decompress(source)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer data.Close()
+
+	reader := bufio.NewReader(data)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			number++
+			lines <- domain.LogRecord{Source: name, Offset: offset, Line: number, Raw: bytes.TrimSuffix(line, []byte{'\n'})}
+			offset += int64(len(line))
+		}
+
+		if err == io.EOF {
+			return number, offset, nil
+		}
+
+		if err != nil {
+			return number, offset, err
+		}
+	}
+}
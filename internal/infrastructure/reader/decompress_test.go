@@ -0,0 +1,198 @@
+package reader_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/reader"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	plainContent = "line1\nline2\nline3"
+	// bzip2 -c <<< "line1\nline2\nline3", в стандартной библиотеке нет упаковщика bzip2.
+	bzip2Content = "QlpoOTFBWSZTWZnQ61MAAAXJAAAQOAACJSAAIj1BiEMCLziEG6h4u5IpwoSEzodamA=="
+)
+
+func TestDecompress(t *testing.T) {
+	bzip2Data, err := base64.StdEncoding.DecodeString(bzip2Content)
+	require.NoError(t, err)
+
+	tests := map[string][]byte{
+		"plain": []byte(plainContent),
+		"gzip":  compressGzip(t, plainContent),
+		"bzip2": bzip2Data,
+		"zstd":  compressZstd(t, plainContent),
+		"xz":    compressXz(t, plainContent),
+		"empty": {},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			decompressed, err := reader.Decompress(bytes.NewReader(data))
+			require.NoError(t, err)
+
+			defer decompressed.Close()
+
+			content, err := io.ReadAll(decompressed)
+			require.NoError(t, err)
+
+			if name == "empty" {
+				assert.Empty(t, content)
+				return
+			}
+
+			assert.Equal(t, plainContent, string(content))
+		})
+	}
+}
+
+func TestReadLines_RotatedCompressedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "access.log"), []byte("current\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "access.log.1.gz"), compressGzip(t, "rotated1\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "access.log.2.zst"), compressZstd(t, "rotated2"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "access.log.3.gz"), []byte{0x1f, 0x8b, 0x08, 0x00, 0x01}, 0o600))
+
+	lines, err := (&reader.FileReader{}).ReadLines(&domain.InputConfig{Path: filepath.Join(tmpDir, "access.log*")})
+	require.NoError(t, err)
+
+	var collected []string
+
+	var readErrors []domain.LogRecord
+
+	for line := range lines {
+		if line.Err != nil {
+			readErrors = append(readErrors, line)
+			continue
+		}
+
+		collected = append(collected, recordText(line))
+	}
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(tmpDir, "access.log") + ":1:0 current",
+		filepath.Join(tmpDir, "access.log.1.gz") + ":1:0 rotated1",
+		filepath.Join(tmpDir, "access.log.2.zst") + ":1:0 rotated2",
+	}, collected)
+
+	require.Len(t, readErrors, 1)
+	assert.Equal(t, filepath.Join(tmpDir, "access.log.3.gz"), readErrors[0].Source)
+	assert.Equal(t, int64(1), readErrors[0].Line)
+	assert.ErrorIs(t, readErrors[0].Err, domain.ErrReadLog)
+	assert.Empty(t, readErrors[0].Raw)
+}
+
+func TestReadLines_TruncatedArchive(t *testing.T) {
+	data := compressGzip(t, "first\nsecond\n")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(data[:len(data)-4])
+	}))
+	defer server.Close()
+
+	lines, err := (&reader.URLReader{}).ReadLines(&domain.InputConfig{Path: server.URL + "/access.log.gz"})
+	require.NoError(t, err)
+
+	var collected []domain.LogRecord
+	for line := range lines {
+		collected = append(collected, line)
+	}
+
+	require.NotEmpty(t, collected)
+
+	last := collected[len(collected)-1]
+	assert.ErrorIs(t, last.Err, domain.ErrReadLog)
+	assert.Equal(t, int64(len(collected)), last.Line)
+}
+
+func TestReadLines_SameNameInDifferentDirectories(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, dir := range []string{"a", "b"} {
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, dir), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, dir, "access.log"), []byte(dir+"\n"), 0o600))
+	}
+
+	lines, err := (&reader.FileReader{}).ReadLines(&domain.InputConfig{Path: filepath.Join(tmpDir, "*", "access.log")})
+	require.NoError(t, err)
+
+	var collected []string
+	for line := range lines {
+		collected = append(collected, recordText(line))
+	}
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(tmpDir, "a", "access.log") + ":1:0 a",
+		filepath.Join(tmpDir, "b", "access.log") + ":1:0 b",
+	}, collected)
+}
+
+func TestReadLines_CompressedURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(compressXz(t, plainContent))
+	}))
+	defer server.Close()
+
+	lines, err := (&reader.URLReader{}).ReadLines(&domain.InputConfig{Path: server.URL + "/access.log.xz"})
+	require.NoError(t, err)
+
+	var collected []string
+	for line := range lines {
+		collected = append(collected, recordText(line))
+	}
+
+	prefix := "access.log.xz(from url)"
+	assert.Equal(t, []string{prefix + ":1:0 line1", prefix + ":2:6 line2", prefix + ":3:12 line3"}, collected)
+}
+
+// recordText описывает запись как "источник:строка:смещение содержимое".
+func recordText(record domain.LogRecord) string {
+	return fmt.Sprintf("%s:%d:%d %s", record.Source, record.Line, record.Offset, record.Raw)
+}
+
+func compressGzip(t *testing.T, content string) []byte {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return buffer.Bytes()
+}
+
+func compressZstd(t *testing.T, content string) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+
+	defer encoder.Close()
+
+	return encoder.EncodeAll([]byte(content), nil)
+}
+
+func compressXz(t *testing.T, content string) []byte {
+	var buffer bytes.Buffer
+
+	writer, err := xz.NewWriter(&buffer)
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return buffer.Bytes()
+}
@@ -1,8 +1,6 @@
 package reader
 
 import (
-	"bufio"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,7 +11,7 @@ import (
 type FileReader struct {
 }
 
-func (fr *FileReader) ReadLines(inputConfig *domain.InputConfig) (lines chan string, err error) {
+func (fr *FileReader) ReadLines(inputConfig *domain.InputConfig) (lines chan domain.LogRecord, err error) {
 	var data []string
 	data, err = fr.FindFilesByPattern(inputConfig.Path)
 
@@ -21,7 +19,7 @@ func (fr *FileReader) ReadLines(inputConfig *domain.InputConfig) (lines chan str
 		return nil, err
 	}
 
-	lines = make(chan string)
+	lines = make(chan domain.LogRecord, LinesBufferSize)
 
 	go func() {
 		defer close(lines)
@@ -29,22 +27,13 @@ func (fr *FileReader) ReadLines(inputConfig *domain.InputConfig) (lines chan str
 		for _, path := range data {
 			file, err := os.Open(path)
 			if err != nil {
+				sendReadError(lines, domain.LogRecord{Source: path, Line: 1}, err)
 				continue
 			}
 
-			reader := bufio.NewReader(file)
-
-			for {
-				line, err := reader.ReadString('\n')
-				if err != nil {
-					if err == io.EOF {
-						break
-					}
-
-					continue
-				}
-				lines <- filepath.Base(file.Name()) + "$" + line
-			}
+			// Источник — путь целиком: одноимённые файлы из разных каталогов не должны смешиваться
+			// в определении формата и статистике по файлам.
+			readLines(file, path, lines)
 
 			file.Close()
 		}
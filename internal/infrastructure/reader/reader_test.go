@@ -6,7 +6,6 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"testing"
 
@@ -104,21 +103,20 @@ func TestReadLines(t *testing.T) {
 				for line := range lines {
 					mutex.Lock()
 
-					data := strings.Split(line, "$")
-					if _, ok := filesUsed[data[0]]; !ok {
-						collectedNames = append(collectedNames, data[0])
-						filesUsed[data[0]] = struct{}{}
+					if _, ok := filesUsed[line.Source]; !ok {
+						collectedNames = append(collectedNames, line.Source)
+						filesUsed[line.Source] = struct{}{}
 					}
 
-					collectedLines = append(collectedLines, data[1])
+					collectedLines = append(collectedLines, string(line.Raw))
 					mutex.Unlock()
 				}
 			}()
 		}
 
 		wg.Wait()
-		assert.ElementsMatch(t, []string{"file.txt"}, collectedNames)
-		assert.ElementsMatch(t, []string{"line1\n", "line2\n", "line3\n"}, collectedLines)
+		assert.ElementsMatch(t, []string{filePath}, collectedNames)
+		assert.ElementsMatch(t, []string{"line1", "line2", "line3"}, collectedLines)
 	})
 
 	t.Run("Read Lines from URL", func(t *testing.T) {
@@ -156,13 +154,12 @@ func TestReadLines(t *testing.T) {
 				for line := range lines {
 					mutex.Lock()
 
-					data := strings.Split(line, "$")
-					if _, ok := filesUsed[data[0]]; !ok {
-						collectedNames = append(collectedNames, data[0])
-						filesUsed[data[0]] = struct{}{}
+					if _, ok := filesUsed[line.Source]; !ok {
+						collectedNames = append(collectedNames, line.Source)
+						filesUsed[line.Source] = struct{}{}
 					}
 
-					collectedLines = append(collectedLines, data[1])
+					collectedLines = append(collectedLines, string(line.Raw))
 					mutex.Unlock()
 				}
 			}()
@@ -170,7 +167,7 @@ func TestReadLines(t *testing.T) {
 
 		wg.Wait()
 		assert.ElementsMatch(t, []string{"test.txt(from url)"}, collectedNames)
-		assert.ElementsMatch(t, []string{"mock file content\n"}, collectedLines)
+		assert.ElementsMatch(t, []string{"mock file content"}, collectedLines)
 	})
 
 	t.Run("Invalid URL", func(t *testing.T) {
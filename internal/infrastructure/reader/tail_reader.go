@@ -0,0 +1,297 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const (
+	DefaultPollInterval = time.Second
+	tailBufferSize      = 64 * 1024
+)
+
+// TailReader читает файлы по шаблону и продолжает следить за ними, как tail -F: подхватывает
+// новые файлы, переживает ротацию переименованием с созданием нового файла и copytruncate.
+// Существующее содержимое читается с начала. Канал закрывается после отмены контекста.
+// Сжатые файлы (архивы ротации) пропускаются — они не дописываются.
+type TailReader struct {
+	ctx          context.Context
+	pollInterval time.Duration
+	finder       FileReader
+}
+
+type tailedFile struct {
+	name    string
+	file    *os.File
+	offset  int64
+	partial []byte
+	// skip — файл сжат и больше не читается.
+	skip bool
+	// lineOffset и lineNumber описывают начало ещё не отправленной строки.
+	lineOffset int64
+	lineNumber int64
+}
+
+func NewTailReader(ctx context.Context, pollInterval time.Duration) *TailReader {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	return &TailReader{ctx: ctx, pollInterval: pollInterval}
+}
+
+func (tr *TailReader) ReadLines(inputConfig *domain.InputConfig) (lines chan domain.LogRecord, err error) {
+	// Файлов может ещё не быть: tail -F ждёт их появления, поэтому ErrFinding не ошибка.
+	if _, err := tr.finder.FindFilesByPattern(inputConfig.Path); err != nil && !errors.Is(err, domain.ErrFinding) {
+		return nil, err
+	}
+
+	lines = make(chan domain.LogRecord)
+
+	go tr.follow(inputConfig.Path, lines)
+
+	return lines, nil
+}
+
+func (tr *TailReader) follow(pattern string, lines chan<- domain.LogRecord) {
+	defer close(lines)
+
+	files := make(map[string]*tailedFile)
+	names := make(sourceNames)
+	// Буфер один на все опросы: строки копируются из него в record.
+	buffer := make([]byte, tailBufferSize)
+
+	defer func() {
+		for _, file := range files {
+			file.close()
+		}
+	}()
+
+	ticker := time.NewTicker(tr.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if !tr.poll(pattern, files, names, buffer, lines) {
+			return
+		}
+
+		select {
+		case <-tr.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll дочитывает открытые файлы, затем сверяет их с файлами по шаблону по идентичности
+// (inode), а не по имени: переименованный при ротации файл продолжает читаться с прежней
+// позиции, новый файл на старом пути читается с начала, а пропавшие из шаблона файлы закрываются.
+func (tr *TailReader) poll(
+	pattern string,
+	files map[string]*tailedFile,
+	names sourceNames,
+	buffer []byte,
+	lines chan<- domain.LogRecord,
+) bool {
+	for _, file := range files {
+		if !tr.readAvailable(file, buffer, lines) {
+			return false
+		}
+	}
+
+	paths, _ := tr.finder.FindFilesByPattern(pattern)
+	current := make(map[string]*tailedFile, len(paths))
+	kept := make(map[*tailedFile]struct{}, len(paths))
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if file := findSameFile(files, info); file != nil {
+			if info.Size() < file.offset {
+				file.reset(names.next(path))
+			}
+
+			current[path] = file
+			kept[file] = struct{}{}
+
+			continue
+		}
+
+		file, err := openTailedFile(path, names)
+		if err != nil {
+			continue
+		}
+
+		current[path] = file
+	}
+
+	for _, file := range files {
+		if _, ok := kept[file]; ok {
+			continue
+		}
+
+		if !tr.flushPartial(file, lines) {
+			return false
+		}
+
+		file.close()
+	}
+
+	clear(files)
+
+	for path, file := range current {
+		files[path] = file
+	}
+
+	for _, file := range files {
+		if !tr.readAvailable(file, buffer, lines) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func findSameFile(files map[string]*tailedFile, info os.FileInfo) *tailedFile {
+	for _, file := range files {
+		current, err := file.file.Stat()
+		if err == nil && os.SameFile(info, current) {
+			return file
+		}
+	}
+
+	return nil
+}
+
+func (tr *TailReader) readAvailable(file *tailedFile, buffer []byte, lines chan<- domain.LogRecord) bool {
+	if file.skip {
+		return true
+	}
+
+	for {
+		n, err := file.file.ReadAt(buffer, file.offset)
+		if n > 0 {
+			if file.offset == 0 && isCompressed(buffer[:n]) {
+				file.skip = true
+				return true
+			}
+
+			file.offset += int64(n)
+
+			if !tr.emit(file, buffer[:n], lines) {
+				return false
+			}
+		}
+
+		// io.EOF — данные кончились до следующего опроса; прочие ошибки чтения тоже ждут следующего опроса.
+		if err != nil || n == 0 {
+			return true
+		}
+	}
+}
+
+// emit отправляет полные строки, а незавершённый хвост оставляет до следующего чтения.
+func (tr *TailReader) emit(file *tailedFile, chunk []byte, lines chan<- domain.LogRecord) bool {
+	data := append(file.partial, chunk...)
+
+	for {
+		index := bytes.IndexByte(data, '\n')
+		if index == -1 {
+			break
+		}
+
+		if !tr.send(lines, file.record(data[:index])) {
+			return false
+		}
+
+		file.lineOffset += int64(index + 1)
+		data = data[index+1:]
+	}
+
+	file.partial = append([]byte(nil), data...)
+
+	return true
+}
+
+func (tr *TailReader) flushPartial(file *tailedFile, lines chan<- domain.LogRecord) bool {
+	if len(file.partial) == 0 {
+		return true
+	}
+
+	record := file.record(file.partial)
+	file.lineOffset += int64(len(file.partial))
+	file.partial = nil
+
+	return tr.send(lines, record)
+}
+
+func (tr *TailReader) send(lines chan<- domain.LogRecord, record domain.LogRecord) bool {
+	select {
+	case lines <- record:
+		return true
+	case <-tr.ctx.Done():
+		return false
+	}
+}
+
+func openTailedFile(path string, names sourceNames) (*tailedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tailedFile{name: names.next(path), file: file}, nil
+}
+
+// sourceNames считает содержимое, прочитанное с начала по каждому пути. Файл, созданный на месте
+// старого при ротации, и файл после copytruncate получают отдельное имя источника "путь (N)":
+// формат определяется для них заново, а номера строк и статистика по файлам не смешиваются
+// со старым содержимым.
+type sourceNames map[string]int
+
+func (sn sourceNames) next(path string) string {
+	sn[path]++
+
+	if sn[path] == 1 {
+		return path
+	}
+
+	return fmt.Sprintf("%s (%d)", path, sn[path])
+}
+
+// record оформляет очередную строку файла; raw копируется, так как буфер переиспользуется.
+func (tf *tailedFile) record(raw []byte) domain.LogRecord {
+	tf.lineNumber++
+
+	return domain.LogRecord{
+		Source: tf.name,
+		Offset: tf.lineOffset,
+		Line:   tf.lineNumber,
+		Raw:    append([]byte(nil), raw...),
+	}
+}
+
+// reset начинает чтение файла заново под новым именем источника после copytruncate.
+func (tf *tailedFile) reset(name string) {
+	tf.name = name
+	tf.skip = false
+	tf.offset = 0
+	tf.partial = nil
+	tf.lineOffset = 0
+	tf.lineNumber = 0
+}
+
+func (tf *tailedFile) close() {
+	if tf.file != nil {
+		tf.file.Close()
+	}
+}
@@ -0,0 +1,93 @@
+package reader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/reader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailReader_ReadLines(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "access.log")
+	require.NoError(t, os.WriteFile(path, []byte("existing\npart"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "access.log.1.gz"), compressGzip(t, "archived\n"), 0o600))
+
+	tailReader := reader.NewTailReader(ctx, 10*time.Millisecond)
+	lines, err := tailReader.ReadLines(&domain.InputConfig{Path: filepath.Join(tmpDir, "access.log*")})
+	require.NoError(t, err)
+
+	assert.Equal(t, path+":1:0 existing", receiveLine(t, lines))
+
+	appendToFile(t, path, "ial\nappended\n")
+	assert.Equal(t, path+":2:9 partial", receiveLine(t, lines))
+	assert.Equal(t, path+":3:17 appended", receiveLine(t, lines))
+
+	t.Run("Rename And Recreate", func(t *testing.T) {
+		require.NoError(t, os.Rename(path, filepath.Join(tmpDir, "access.log.1")))
+		appendToFile(t, filepath.Join(tmpDir, "access.log.1"), "late write\n")
+		require.NoError(t, os.WriteFile(path, []byte("after rotation\n"), 0o600))
+
+		received := []string{receiveLine(t, lines), receiveLine(t, lines)}
+		// Переименованный файл дочитывается под прежним именем и не перечитывается с начала,
+		// новый файл на том же пути — отдельный источник.
+		assert.ElementsMatch(t, []string{path + ":4:26 late write", path + " (2):1:0 after rotation"}, received)
+		assert.Empty(t, collectLines(lines))
+	})
+
+	t.Run("Copytruncate", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("truncated\n"), 0o600))
+
+		assert.Equal(t, path+" (3):1:0 truncated", receiveLine(t, lines))
+	})
+
+	cancel()
+
+	for range lines {
+	}
+}
+
+func receiveLine(t *testing.T, lines <-chan domain.LogRecord) string {
+	t.Helper()
+
+	select {
+	case line := <-lines:
+		return recordText(line)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for line")
+		return ""
+	}
+}
+
+func collectLines(lines <-chan domain.LogRecord) []string {
+	var collected []string
+
+	for {
+		select {
+		case line := <-lines:
+			collected = append(collected, recordText(line))
+		case <-time.After(100 * time.Millisecond):
+			return collected
+		}
+	}
+}
+
+func appendToFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
@@ -1,7 +1,6 @@
 package reader
 
 import (
-	"bufio"
 	"io"
 	"net/http"
 	"path/filepath"
@@ -14,31 +13,19 @@ const ValidStatusCode = 200
 type URLReader struct {
 }
 
-func (ur *URLReader) ReadLines(inputConfig *domain.InputConfig) (lines chan string, err error) {
+func (ur *URLReader) ReadLines(inputConfig *domain.InputConfig) (lines chan domain.LogRecord, err error) {
 	data, name, err := ur.ProcessURL(inputConfig.Path)
 
 	if err != nil {
 		return nil, err
 	}
 
-	lines = make(chan string)
+	lines = make(chan domain.LogRecord, LinesBufferSize)
 
 	go func() {
 		defer close(lines)
 
-		reader := bufio.NewReader(data)
-
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-
-				continue
-			}
-			lines <- name + "(from url)$" + line
-		}
+		readLines(data, name+"(from url)", lines)
 
 		data.Close()
 	}()
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/4domm/ngxstat/internal/sketch"
+	"github.com/4domm/ngxstat/internal/useragent"
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Accumulator накапливает статистику по разобранным строкам до подсчёта топов и перцентилей.
+// Частичные накопители (например, по интервалам времени) объединяются через Merge.
+type Accumulator struct {
+	AnalysisResult           *domain.AnalysisResult
+	Histogram                *hdrhistogram.Histogram
+	LatencyHistogram         *hdrhistogram.Histogram
+	UpstreamLatencyHistogram *hdrhistogram.Histogram
+	filesUsed                map[string]struct{}
+	resourceLatencies        map[string]*hdrhistogram.Histogram
+	timeBuckets              map[int64]*domain.TimeBucket
+	visitorIPs               *sketch.HyperLogLog
+	visitorIPUserAgents      *sketch.HyperLogLog
+	visitorRemoteUsers       *sketch.HyperLogLog
+	clients                  map[string]*domain.ClientStats
+	// topResources, topReferrers, topEndpoints, topPaths и topQueryParams заменяют словари результата
+	// в режиме --approx-top; topQueryValues — значения параметров с ключами queryValueKey.
+	topResources   *sketch.TopK
+	topReferrers   *sketch.TopK
+	topEndpoints   *sketch.TopK
+	topPaths       *sketch.TopK
+	topQueryParams *sketch.TopK
+	topQueryValues *sketch.TopK
+	// topClients ограничивает число клиентов, статистика которых хранится в clients.
+	topClients *sketch.TopK
+}
+
+func NewAccumulator() *Accumulator {
+	return NewApproxAccumulator(0)
+}
+
+// NewApproxAccumulator создаёт накопитель, который считает топы ресурсов, рефереров, путей и параметров
+// запроса в памяти на capacity значений каждый. Перцентили задержек по ресурсам собираются только для отслеживаемых
+// ресурсов, статистика клиентов — только для отслеживаемых IP-адресов. При capacity <= 0 топы считаются точно, как в NewAccumulator.
+func NewApproxAccumulator(capacity int) *Accumulator {
+	accumulator := &Accumulator{
+		Histogram:                hdrhistogram.New(MinHistogramValue, MaxHistogramValue, NumberOfSignificantValueDigits),
+		LatencyHistogram:         newLatencyHistogram(NumberOfSignificantValueDigits),
+		UpstreamLatencyHistogram: newLatencyHistogram(NumberOfSignificantValueDigits),
+		AnalysisResult:           domain.NewAnalysisResult(),
+		filesUsed:                make(map[string]struct{}),
+		resourceLatencies:        make(map[string]*hdrhistogram.Histogram),
+		timeBuckets:              make(map[int64]*domain.TimeBucket),
+		visitorIPs:               sketch.NewHyperLogLog(VisitorSketchPrecision),
+		visitorIPUserAgents:      sketch.NewHyperLogLog(VisitorSketchPrecision),
+		visitorRemoteUsers:       sketch.NewHyperLogLog(VisitorSketchPrecision),
+		clients:                  make(map[string]*domain.ClientStats),
+	}
+
+	if capacity > 0 {
+		accumulator.topResources = sketch.NewTopK(capacity)
+		accumulator.topReferrers = sketch.NewTopK(capacity)
+		accumulator.topEndpoints = sketch.NewTopK(capacity)
+		accumulator.topPaths = sketch.NewTopK(capacity)
+		accumulator.topQueryParams = sketch.NewTopK(capacity)
+		accumulator.topQueryValues = sketch.NewTopK(capacity)
+		accumulator.topClients = sketch.NewTopK(capacity)
+	}
+
+	return accumulator
+}
+
+// Add учитывает запись, прошедшую фильтры.
+func (a *Accumulator) Add(logData *domain.LogData) {
+	a.UpdateAnalytics(logData)
+	a.UpdateFiles(logData.Filename)
+
+	if logData.Format != "" {
+		a.AnalysisResult.AddFileFormat(logData.Filename, logData.Format)
+	}
+}
+
+func (a *Accumulator) UpdateAnalytics(logData *domain.LogData) {
+	a.AnalysisResult.TotalResponseSize += logData.ResponseSize
+	a.AnalysisResult.TotalRequests++
+
+	if a.IsServerErrorStatus(logData) {
+		a.AnalysisResult.TotalServerErrorsLogs++
+	}
+
+	a.updateTops(logData)
+	a.updateQuery(logData)
+	a.AnalysisResult.MostFrequentStatusCodes[logData.StatusCode]++
+	a.AnalysisResult.RequestMethods[logData.Method]++
+	a.updateLatencies(logData)
+	a.updateTimeSeries(logData)
+	a.updateVisitors(logData)
+	a.updateClients(logData)
+	a.updateAgentClasses(logData)
+	err := a.Histogram.RecordValue(logData.ResponseSize)
+
+	if err != nil {
+		return
+	}
+}
+
+func (a *Accumulator) updateTops(logData *domain.LogData) {
+	if a.topResources == nil {
+		if logData.Referer != "" {
+			a.AnalysisResult.MostFrequentReferrers[logData.Referer]++
+		}
+
+		if logData.Endpoint != "" {
+			a.AnalysisResult.MostRequestedEndpoints[logData.Endpoint]++
+		}
+
+		a.AnalysisResult.MostRequestedResources[logData.Resource]++
+
+		return
+	}
+
+	if logData.Referer != "" {
+		a.topReferrers.Add(logData.Referer)
+	}
+
+	if logData.Endpoint != "" {
+		a.topEndpoints.Add(logData.Endpoint)
+	}
+
+	if evicted, ok := a.topResources.Add(logData.Resource); ok {
+		delete(a.resourceLatencies, evicted)
+	}
+}
+
+// updateQuery учитывает путь запроса и параметры query string: имя параметра — один раз на запрос,
+// значения — каждое непустое.
+func (a *Accumulator) updateQuery(logData *domain.LogData) {
+	if logData.Path == "" {
+		return
+	}
+
+	if a.topPaths == nil {
+		a.AnalysisResult.MostRequestedPaths[logData.Path]++
+	} else {
+		a.topPaths.Add(logData.Path)
+	}
+
+	for name, values := range logData.Query {
+		if a.topQueryParams == nil {
+			a.AnalysisResult.MostFrequentQueryParams[name]++
+		} else {
+			a.topQueryParams.Add(name)
+		}
+
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+
+			if a.topQueryValues == nil {
+				a.AnalysisResult.AddQueryValue(name, value, 1)
+			} else {
+				a.topQueryValues.Add(queryValueKey(name, value))
+			}
+		}
+	}
+}
+
+// queryValueKey объединяет имя и значение параметра в ключ приближённого топа; нулевой байт
+// не встречается в декодированной query string строк лога.
+func queryValueKey(name, value string) string {
+	return name + "\x00" + value
+}
+
+func (a *Accumulator) updateLatencies(logData *domain.LogData) {
+	if logData.HasUpstreamTime {
+		_ = a.UpstreamLatencyHistogram.RecordValue(latencyValue(logData.UpstreamResponseTime))
+	}
+
+	if !logData.HasRequestTime {
+		return
+	}
+
+	value := latencyValue(logData.RequestTime)
+	_ = a.LatencyHistogram.RecordValue(value)
+
+	histogram, ok := a.resourceLatencies[logData.Resource]
+	if !ok {
+		histogram = newLatencyHistogram(ResourceLatencySignificantValueDigits)
+		a.resourceLatencies[logData.Resource] = histogram
+		a.pruneResourceLatencies()
+	}
+
+	_ = histogram.RecordValue(value)
+}
+
+// pruneResourceLatencies ограничивает гистограммы задержек точного режима: когда их больше
+// 2·MaxResourceLatencies, остаются гистограммы MaxResourceLatencies самых запрашиваемых ресурсов.
+// Ресурс, который после этого поднимется в топ, получит перцентили только по последующим запросам.
+// С --approx-top гистограммы ограничивает топ ресурсов.
+func (a *Accumulator) pruneResourceLatencies() {
+	if a.topResources != nil || len(a.resourceLatencies) <= 2*MaxResourceLatencies {
+		return
+	}
+
+	counts := a.AnalysisResult.MostRequestedResources
+	resources := make([]string, 0, len(a.resourceLatencies))
+
+	for resource := range a.resourceLatencies {
+		resources = append(resources, resource)
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		if counts[resources[i]] != counts[resources[j]] {
+			return counts[resources[i]] > counts[resources[j]]
+		}
+
+		return resources[i] < resources[j]
+	})
+
+	for _, resource := range resources[MaxResourceLatencies:] {
+		delete(a.resourceLatencies, resource)
+	}
+}
+
+func (a *Accumulator) updateTimeSeries(logData *domain.LogData) {
+	if logData.Timestamp.IsZero() {
+		return
+	}
+
+	start := logData.Timestamp.UTC().Truncate(domain.TimeSeriesBaseWidth)
+	a.timeBucket(start).Add(logData.StatusCode, logData.ResponseSize)
+}
+
+func (a *Accumulator) updateVisitors(logData *domain.LogData) {
+	if logData.IPAddress != "" {
+		a.visitorIPs.AddString(logData.IPAddress)
+		a.visitorIPUserAgents.AddPair(logData.IPAddress, logData.UserAgent)
+	}
+
+	if logData.RemoteUser != "" {
+		a.visitorRemoteUsers.AddString(logData.RemoteUser)
+	}
+}
+
+// updateClients учитывает запрос в статистике клиента. В режиме --approx-top статистика клиента,
+// вытесненного из числа отслеживаемых, удаляется и при возвращении копится заново; в точном режиме
+// число клиентов ограничивает pruneClients.
+func (a *Accumulator) updateClients(logData *domain.LogData) {
+	if logData.IPAddress == "" {
+		return
+	}
+
+	if a.topClients != nil {
+		if evicted, ok := a.topClients.Add(logData.IPAddress); ok {
+			delete(a.clients, evicted)
+		}
+	}
+
+	a.client(logData.IPAddress).Add(logData)
+	a.pruneClients()
+}
+
+// pruneClients ограничивает статистику клиентов точного режима: когда клиентов больше 2·MaxClients,
+// остаются MaxClients клиентов с наибольшим числом запросов. Клиент, который после этого поднимется
+// в топ, получит статистику только по последующим запросам. С --approx-top клиентов ограничивает топ.
+func (a *Accumulator) pruneClients() {
+	if a.topClients != nil || len(a.clients) <= 2*MaxClients {
+		return
+	}
+
+	ips := make([]string, 0, len(a.clients))
+	for ip := range a.clients {
+		ips = append(ips, ip)
+	}
+
+	sort.Slice(ips, func(i, j int) bool {
+		if a.clients[ips[i]].Requests != a.clients[ips[j]].Requests {
+			return a.clients[ips[i]].Requests > a.clients[ips[j]].Requests
+		}
+
+		return ips[i] < ips[j]
+	})
+
+	for _, ip := range ips[MaxClients:] {
+		delete(a.clients, ip)
+	}
+}
+
+func (a *Accumulator) updateAgentClasses(logData *domain.LogData) {
+	class := useragent.Classify(logData.UserAgent)
+	a.AnalysisResult.AgentClasses.Add(class.Device, class.Family, class.OS)
+}
+
+func (a *Accumulator) client(ip string) *domain.ClientStats {
+	stats, ok := a.clients[ip]
+	if !ok {
+		stats = domain.NewClientStats()
+		a.clients[ip] = stats
+	}
+
+	return stats
+}
+
+func (a *Accumulator) timeBucket(start time.Time) *domain.TimeBucket {
+	bucket, ok := a.timeBuckets[start.Unix()]
+	if !ok {
+		bucket = &domain.TimeBucket{Start: start}
+		a.timeBuckets[start.Unix()] = bucket
+	}
+
+	return bucket
+}
+
+func (a *Accumulator) UpdateFiles(name string) {
+	if _, ok := a.filesUsed[name]; !ok {
+		a.AnalysisResult.Filenames = append(a.AnalysisResult.Filenames, name)
+		a.filesUsed[name] = struct{}{}
+	}
+}
+
+// UpdateParseErrors учитывает запись, которую не удалось разобрать.
+func (a *Accumulator) UpdateParseErrors(record domain.LogRecord, err error) {
+	a.AnalysisResult.ParseErrors.Add(record, parser.ErrorKind(err))
+}
+
+func (a *Accumulator) IsServerErrorStatus(logData *domain.LogData) bool {
+	strStatusCode, _ := strconv.Atoi(logData.StatusCode)
+	return strStatusCode >= StartServerErrorCode && strStatusCode <= EndServerErrorCode
+}
+
+// Merge добавляет к накопителю статистику other; other после этого не изменяется.
+func (a *Accumulator) Merge(other *Accumulator) {
+	a.AnalysisResult.Merge(other.AnalysisResult)
+
+	for name := range other.filesUsed {
+		a.filesUsed[name] = struct{}{}
+	}
+
+	a.Histogram.Merge(other.Histogram)
+	a.LatencyHistogram.Merge(other.LatencyHistogram)
+	a.UpstreamLatencyHistogram.Merge(other.UpstreamLatencyHistogram)
+
+	for resource, histogram := range other.resourceLatencies {
+		target, ok := a.resourceLatencies[resource]
+		if !ok {
+			target = newLatencyHistogram(ResourceLatencySignificantValueDigits)
+			a.resourceLatencies[resource] = target
+		}
+
+		target.Merge(histogram)
+	}
+
+	a.pruneResourceLatencies()
+
+	for _, bucket := range other.timeBuckets {
+		a.timeBucket(bucket.Start).Merge(bucket)
+	}
+
+	for ip, stats := range other.clients {
+		a.client(ip).Merge(stats)
+	}
+
+	a.pruneClients()
+
+	// Точность у всех скетчей накопителей одна и та же, поэтому ошибки объединения не бывает.
+	_ = a.visitorIPs.Merge(other.visitorIPs)
+	_ = a.visitorIPUserAgents.Merge(other.visitorIPUserAgents)
+	_ = a.visitorRemoteUsers.Merge(other.visitorRemoteUsers)
+
+	a.mergeTops(other)
+}
+
+// mergeTops объединяет приближённые топы накопителей одной ёмкости.
+func (a *Accumulator) mergeTops(other *Accumulator) {
+	if a.topResources == nil || other.topResources == nil {
+		return
+	}
+
+	_ = a.topResources.Merge(other.topResources)
+	_ = a.topReferrers.Merge(other.topReferrers)
+	_ = a.topEndpoints.Merge(other.topEndpoints)
+	_ = a.topPaths.Merge(other.topPaths)
+	_ = a.topQueryParams.Merge(other.topQueryParams)
+	_ = a.topQueryValues.Merge(other.topQueryValues)
+	_ = a.topClients.Merge(other.topClients)
+
+	a.dropUntracked()
+}
+
+// truncateTops оставляет в приближённых топах по n значений (см. sketch.TopK.Truncate) вместе
+// с перцентилями и статистикой клиентов, которые остались отслеживаемыми.
+func (a *Accumulator) truncateTops(n int) {
+	if a.topResources == nil {
+		return
+	}
+
+	a.topResources.Truncate(n)
+	a.topReferrers.Truncate(n)
+	a.topEndpoints.Truncate(n)
+	a.topPaths.Truncate(n)
+	a.topQueryParams.Truncate(n)
+	a.topQueryValues.Truncate(n)
+	a.topClients.Truncate(n)
+
+	a.dropUntracked()
+}
+
+// dropUntracked удаляет перцентили ресурсов и статистику клиентов, выпавших из приближённых топов.
+func (a *Accumulator) dropUntracked() {
+	for resource := range a.resourceLatencies {
+		if !a.topResources.Contains(resource) {
+			delete(a.resourceLatencies, resource)
+		}
+	}
+
+	for ip := range a.clients {
+		if !a.topClients.Contains(ip) {
+			delete(a.clients, ip)
+		}
+	}
+}
+
+// Process считает топы, перцентили и временной ряд с интервалами ширины bucket (0 — автоматически).
+// Топы урезают счётчики результата, поэтому после вызова накопитель нельзя продолжать пополнять.
+func (a *Accumulator) Process(topN int, from, to time.Time, bucket time.Duration) *domain.AnalysisResult {
+	a.processApproxTop(topN)
+	a.AnalysisResult.ProcessAll(topN, a.Histogram, from, to)
+	a.AnalysisResult.ProcessLatencies(a.LatencyHistogram, a.UpstreamLatencyHistogram, a.resourceLatencies)
+	a.AnalysisResult.TimeSeries = domain.NewTimeSeries(a.timeBuckets, bucket)
+	a.AnalysisResult.UniqueVisitors = domain.UniqueVisitors{
+		IPs:          domain.NewCardinality(a.visitorIPs),
+		IPUserAgents: domain.NewCardinality(a.visitorIPUserAgents),
+		RemoteUsers:  domain.NewCardinality(a.visitorRemoteUsers),
+	}
+	a.AnalysisResult.TopClients = domain.NewTopClients(a.clients, topN)
+
+	return a.AnalysisResult
+}
+
+// approxTop возвращает ёмкость приближённых топов или 0, если топы точные.
+func (a *Accumulator) approxTop() int {
+	if a.topResources == nil {
+		return 0
+	}
+
+	return a.topResources.Capacity()
+}
+
+// processApproxTop переносит приближённые топы в словари результата вместе с погрешностями.
+func (a *Accumulator) processApproxTop(topN int) {
+	if a.topResources == nil {
+		return
+	}
+
+	approxTop := &domain.ApproxTop{
+		Capacity:         a.approxTop(),
+		ResourceErrors:   make(map[string]int64),
+		ReferrerErrors:   make(map[string]int64),
+		EndpointErrors:   make(map[string]int64),
+		PathErrors:       make(map[string]int64),
+		QueryParamErrors: make(map[string]int64),
+		QueryValueErrors: make(map[string]map[string]int64),
+	}
+
+	for _, counter := range a.topResources.Top(topN) {
+		a.AnalysisResult.MostRequestedResources[counter.Key] = counter.Count
+		approxTop.ResourceErrors[counter.Key] = counter.Error
+	}
+
+	for _, counter := range a.topReferrers.Top(topN) {
+		a.AnalysisResult.MostFrequentReferrers[counter.Key] = counter.Count
+		approxTop.ReferrerErrors[counter.Key] = counter.Error
+	}
+
+	for _, counter := range a.topEndpoints.Top(topN) {
+		a.AnalysisResult.MostRequestedEndpoints[counter.Key] = counter.Count
+		approxTop.EndpointErrors[counter.Key] = counter.Error
+	}
+
+	for _, counter := range a.topPaths.Top(topN) {
+		a.AnalysisResult.MostRequestedPaths[counter.Key] = counter.Count
+		approxTop.PathErrors[counter.Key] = counter.Error
+	}
+
+	for _, counter := range a.topQueryParams.Top(topN) {
+		a.AnalysisResult.MostFrequentQueryParams[counter.Key] = counter.Count
+		approxTop.QueryParamErrors[counter.Key] = counter.Error
+	}
+
+	// Значения всех параметров делят один топ, поэтому переносятся все отслеживаемые: топ значений
+	// каждого параметра выберет ProcessAll.
+	for _, counter := range a.topQueryValues.Top(a.approxTop()) {
+		name, value, _ := strings.Cut(counter.Key, "\x00")
+		a.AnalysisResult.AddQueryValue(name, value, counter.Count)
+
+		if approxTop.QueryValueErrors[name] == nil {
+			approxTop.QueryValueErrors[name] = make(map[string]int64)
+		}
+
+		approxTop.QueryValueErrors[name][value] = counter.Error
+	}
+
+	a.AnalysisResult.ApproxTop = approxTop
+}
+
+func newLatencyHistogram(significantValueDigits int) *hdrhistogram.Histogram {
+	return hdrhistogram.New(MinLatencyValue, MaxLatencyValue, significantValueDigits)
+}
+
+// latencyValue переводит задержку в микросекунды; нулевое время учитывается как минимальное.
+func latencyValue(latency time.Duration) int64 {
+	return max(latency.Microseconds(), MinLatencyValue)
+}
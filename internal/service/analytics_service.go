@@ -1,14 +1,17 @@
 package service
 
 import (
-	"strconv"
+	"runtime"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/filter"
 
 	"github.com/4domm/ngxstat/internal/infrastructure/parser"
-	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/4domm/ngxstat/internal/sketch"
+	"github.com/4domm/ngxstat/internal/urlpath"
 )
 
 const (
@@ -18,134 +21,237 @@ const (
 	StartServerErrorCode           = 500
 	EndServerErrorCode             = 599
 	TopN                           = 3
+
+	// Задержки записываются в микросекундах, верхняя граница — один час.
+	MaxLatencyValue                       = int64(time.Hour / time.Microsecond)
+	MinLatencyValue                       = 1
+	ResourceLatencySignificantValueDigits = 2
+	// MaxResourceLatencies — для скольких самых запрашиваемых ресурсов точный режим хранит гистограммы
+	// задержек; гистограммы остальных удаляются, когда их становится вдвое больше.
+	MaxResourceLatencies = 500
+	// MaxClients — статистику скольких клиентов с наибольшим числом запросов хранит точный режим;
+	// остальные удаляются, когда клиентов становится вдвое больше.
+	MaxClients = 1000
+
+	// VisitorSketchPrecision задаёт точность HyperLogLog для уникальных клиентов: 16 КиБ на скетч.
+	VisitorSketchPrecision = sketch.DefaultPrecision
 )
 
 type Reader interface {
-	ReadLines(*domain.InputConfig) (chan string, error)
+	ReadLines(*domain.InputConfig) (chan domain.LogRecord, error)
+}
+
+// pipeline — обработка строки в одном проходе по логам: парсер этого прохода (см. parser.ForRun),
+// шаблон пути и фильтры из настроек запуска.
+type pipeline struct {
+	parser     parser.LogParser
+	normalizer *urlpath.Normalizer
+	predicate  filter.Predicate
 }
 
+// newPipeline компилирует правила --rewrite и фильтры до чтения логов.
+func newPipeline(logParser parser.LogParser, inputConfig *domain.InputConfig) (*pipeline, error) {
+	normalizer, err := urlpath.ForConfig(inputConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	predicate, err := filter.ForConfig(inputConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pipeline{parser: parser.ForRun(logParser), normalizer: normalizer, predicate: predicate}, nil
+}
+
+// AnalyticsService не хранит статистику: каждый вызов Process, Aggregate, Follow или Serve копит её
+// в собственных накопителях и разбирает строки собственным парсером (см. parser.ForRun), поэтому
+// сервис можно вызывать повторно и из нескольких горутин сразу.
 type AnalyticsService struct {
-	AnalysisResult *domain.AnalysisResult
-	Histogram      *hdrhistogram.Histogram
-	LogParser      parser.LogParser
-	Reader         Reader
-	mu             sync.Mutex
-	filesUsed      map[string]struct{}
+	LogParser parser.LogParser
+	Reader    Reader
 }
 
 func NewAnalyticsService(logParser parser.LogParser, readers Reader) *AnalyticsService {
 	return &AnalyticsService{
-		LogParser:      logParser,
-		Reader:         readers,
-		Histogram:      hdrhistogram.New(MinHistogramValue, MaxHistogramValue, NumberOfSignificantValueDigits),
-		AnalysisResult: domain.NewAnalysisResult(),
-		filesUsed:      make(map[string]struct{}),
+		LogParser: logParser,
+		Reader:    readers,
 	}
 }
 
+// Process собирает статистику (см. Aggregate) и считает по ней топы и перцентили.
 func (s *AnalyticsService) Process(inputConfig *domain.InputConfig) (*domain.AnalysisResult, error) {
-	lines, err := s.Reader.ReadLines(inputConfig)
+	accumulator, err := s.Aggregate(inputConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	logData := s.parseAndFilter(lines, inputConfig)
-	s.runAnalyticsWorkers(logData)
-	s.AnalysisResult.ProcessAll(TopN, s.Histogram, inputConfig.From, inputConfig.To)
+	return ProcessResult(accumulator, inputConfig, inputConfig.From, inputConfig.To), nil
+}
+
+// ProcessResult считает результат по накопителю с настройками запуска (см. Accumulator.Process);
+// для --ip результат становится отчётом по клиенту.
+func ProcessResult(accumulator *Accumulator, inputConfig *domain.InputConfig, from, to time.Time) *domain.AnalysisResult {
+	result := accumulator.Process(TopN, from, to, inputConfig.Bucket)
+	result.QueryParam = inputConfig.QueryParam
 
-	return s.AnalysisResult, nil
+	if inputConfig.ClientIP != "" {
+		result.SelectClient(inputConfig.ClientIP, accumulator.clients, TopN)
+	}
+
+	return result
 }
 
-func (s *AnalyticsService) parseAndFilter(
-	lines <-chan string,
-	inputConfig *domain.InputConfig,
-) <-chan *domain.LogData {
-	filterFunction := parser.GetFilterFunction(inputConfig.FilterField, inputConfig.FilterValue)
-	logData := make(chan *domain.LogData)
+// Aggregate читает и разбирает строки в inputConfig.Workers горутинах (0 — по числу процессоров)
+// и возвращает новый накопитель до подсчёта топов: его можно сохранить снимком или объединить с другими.
+// Каждая горутина копит статистику в собственном накопителе без блокировок, накопители
+// объединяются после чтения всех строк.
+func (s *AnalyticsService) Aggregate(inputConfig *domain.InputConfig) (*Accumulator, error) {
+	stages, err := newPipeline(s.LogParser, inputConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	go func() {
-		defer close(logData)
+	lines, err := s.Reader.ReadLines(inputConfig)
+	if err != nil {
+		return nil, err
+	}
 
-		for line := range lines {
-			parsedData, err := s.LogParser.ParseLogLine(line)
-			if err == nil && parsedData != nil && s.IsTailoredForTimeRange(parsedData, inputConfig.From, inputConfig.To) {
-				if filterFunction == nil {
-					logData <- parsedData
-				} else if filterFunction(parsedData) {
-					logData <- parsedData
-				}
-			}
-		}
-	}()
+	accumulator := NewApproxAccumulator(inputConfig.ApproxTop)
 
-	return logData
+	for _, shard := range s.runShards(lines, inputConfig, stages) {
+		accumulator.Merge(shard)
+	}
+
+	// Порядок, в котором шарды встретили файлы, случаен; в отчёте файлы идут по имени.
+	slices.Sort(accumulator.AnalysisResult.Filenames)
+
+	return accumulator, nil
 }
 
-func (s *AnalyticsService) runAnalyticsWorkers(logData <-chan *domain.LogData) {
-	const numWorkers = 8
+// runShards разбирает строки в нескольких горутинах и возвращает их частичные накопители.
+func (s *AnalyticsService) runShards(
+	lines <-chan domain.LogRecord,
+	inputConfig *domain.InputConfig,
+	stages *pipeline,
+) []*Accumulator {
+	shards := make([]*Accumulator, workerCount(inputConfig.Workers))
 
 	var wg sync.WaitGroup
 
-	for i := 0; i < numWorkers; i++ {
+	for i := range shards {
+		shard := NewApproxAccumulator(inputConfig.ApproxTop)
+		shards[i] = shard
+
 		wg.Add(1)
 
 		go func() {
 			defer wg.Done()
 
-			for data := range logData {
-				s.mu.Lock()
-				s.UpdateAnalytics(data)
+			for record := range lines {
+				shard.AnalysisResult.TotalLines++
 
-				s.UpdateFiles(data.Filename)
+				logData, err := s.parseRecord(record, inputConfig, stages)
+				if err != nil {
+					shard.UpdateParseErrors(record, err)
+					continue
+				}
 
-				s.mu.Unlock()
+				if logData != nil {
+					shard.Add(logData)
+				}
 			}
 		}()
 	}
 
 	wg.Wait()
+
+	return shards
 }
 
-func (s *AnalyticsService) IsTailoredForTimeRange(logData *domain.LogData, from, to time.Time) bool {
-	if !from.IsZero() && logData.Timestamp.Before(from) {
-		return false
-	}
+// parseAndFilter разбирает строки в inputConfig.Workers горутинах и отдаёт записи, прошедшие
+// фильтры, в порядке готовности. Ошибки разбора и число прочитанных строк учитываются в lineStats.
+func (s *AnalyticsService) parseAndFilter(
+	lines <-chan domain.LogRecord,
+	inputConfig *domain.InputConfig,
+	stages *pipeline,
+	lineStats *lineCounter,
+) <-chan *domain.LogData {
+	logData := make(chan *domain.LogData)
 
-	if !to.IsZero() && logData.Timestamp.After(to) {
-		return false
+	var wg sync.WaitGroup
+
+	for i := 0; i < workerCount(inputConfig.Workers); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for record := range lines {
+				parsedData, err := s.parseRecord(record, inputConfig, stages)
+				lineStats.add(record, err)
+
+				if parsedData != nil {
+					logData <- parsedData
+				}
+			}
+		}()
 	}
 
-	return true
+	go func() {
+		wg.Wait()
+		close(logData)
+	}()
+
+	return logData
 }
-func (s *AnalyticsService) UpdateAnalytics(logData *domain.LogData) {
-	s.AnalysisResult.TotalResponseSize += logData.ResponseSize
-	s.AnalysisResult.TotalRequests++
 
-	if s.IsServerErrorStatus(logData) {
-		s.AnalysisResult.TotalServerErrorsLogs++
+// parseRecord разбирает строку и строит шаблон пути; возвращает nil без ошибки, если запись
+// не прошла фильтры.
+func (s *AnalyticsService) parseRecord(
+	record domain.LogRecord,
+	inputConfig *domain.InputConfig,
+	stages *pipeline,
+) (*domain.LogData, error) {
+	if record.Err != nil {
+		return nil, record.Err
 	}
 
-	if logData.Referer != "" {
-		s.AnalysisResult.MostFrequentReferrers[logData.Referer]++
+	parsedData, err := stages.parser.ParseLogLine(record)
+	if err != nil || parsedData == nil {
+		return nil, err
 	}
 
-	s.AnalysisResult.MostRequestedResources[logData.Resource]++
-	s.AnalysisResult.MostFrequentStatusCodes[logData.StatusCode]++
-	err := s.Histogram.RecordValue(logData.ResponseSize)
+	if !s.IsTailoredForTimeRange(parsedData, inputConfig.From, inputConfig.To) {
+		return nil, nil
+	}
 
-	if err != nil {
-		return
+	parsedData.Path, parsedData.Query = urlpath.Split(parsedData.Resource)
+	parsedData.Endpoint = stages.normalizer.Template(parsedData.Resource)
+
+	if stages.predicate != nil && !stages.predicate(parsedData) {
+		return nil, nil
 	}
+
+	return parsedData, nil
 }
 
-func (s *AnalyticsService) UpdateFiles(name string) {
-	if _, ok := s.filesUsed[name]; !ok {
-		s.AnalysisResult.Filenames = append(s.AnalysisResult.Filenames, name)
-		s.filesUsed[name] = struct{}{}
+func workerCount(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
 	}
+
+	return workers
 }
 
-func (s *AnalyticsService) IsServerErrorStatus(logData *domain.LogData) bool {
-	strStatusCode, _ := strconv.Atoi(logData.StatusCode)
-	return strStatusCode >= StartServerErrorCode && strStatusCode <= EndServerErrorCode
+func (s *AnalyticsService) IsTailoredForTimeRange(logData *domain.LogData, from, to time.Time) bool {
+	if !from.IsZero() && logData.Timestamp.Before(from) {
+		return false
+	}
+
+	if !to.IsZero() && logData.Timestamp.After(to) {
+		return false
+	}
+
+	return true
 }
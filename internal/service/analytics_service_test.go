@@ -1,6 +1,11 @@
 package service_test
 
 import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,6 +15,7 @@ import (
 	"github.com/4domm/ngxstat/internal/infrastructure/parser"
 	"github.com/4domm/ngxstat/internal/service"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsTailoredForTimeRange(t *testing.T) {
@@ -94,8 +100,8 @@ func mustParseDate(value string) time.Time {
 	return t
 }
 
-func TestAnalyticsService_UpdateAnalytics(t *testing.T) {
-	analyticsService := service.NewAnalyticsService(nil, nil)
+func TestAccumulator_UpdateAnalytics(t *testing.T) {
+	accumulator := service.NewAccumulator()
 	logData := []*domain.LogData{
 		{
 			Timestamp:    parser.ParseTimestamp("2023-01-01T00:00:00+0000"),
@@ -125,12 +131,541 @@ func TestAnalyticsService_UpdateAnalytics(t *testing.T) {
 	}
 
 	for _, log := range logData {
-		analyticsService.UpdateAnalytics(log)
+		accumulator.UpdateAnalytics(log)
 	}
 
-	analyticsService.AnalysisResult.ProcessAll(3, analyticsService.Histogram, time.Time{}, time.Time{})
+	accumulator.AnalysisResult.ProcessAll(3, accumulator.Histogram, time.Time{}, time.Time{})
 
-	assertAnalysisResult(t, expectedResult, analyticsService.AnalysisResult)
+	assertAnalysisResult(t, expectedResult, accumulator.AnalysisResult)
+}
+
+func TestAccumulator_Latencies(t *testing.T) {
+	accumulator := service.NewAccumulator()
+
+	for i := 1; i <= 100; i++ {
+		accumulator.UpdateAnalytics(&domain.LogData{
+			Resource:             "/api",
+			StatusCode:           "200",
+			RequestTime:          time.Duration(i) * time.Millisecond,
+			HasRequestTime:       true,
+			UpstreamResponseTime: time.Duration(i) * time.Millisecond / 2,
+			HasUpstreamTime:      i%2 == 0,
+		})
+	}
+
+	accumulator.UpdateAnalytics(&domain.LogData{Resource: "/static", StatusCode: "200"})
+
+	result := accumulator.AnalysisResult
+	result.ProcessAll(3, accumulator.Histogram, time.Time{}, time.Time{})
+	result.ProcessLatencies(accumulator.LatencyHistogram, accumulator.UpstreamLatencyHistogram, nil)
+
+	assert.Equal(t, int64(100), result.RequestLatency.Count)
+	assert.InDelta(t, 50*time.Millisecond, result.RequestLatency.P50, float64(time.Millisecond))
+	assert.InDelta(t, 95*time.Millisecond, result.RequestLatency.P95, float64(time.Millisecond))
+	assert.InDelta(t, 99*time.Millisecond, result.RequestLatency.P99, float64(time.Millisecond))
+	assert.InDelta(t, 100*time.Millisecond, result.RequestLatency.Max, float64(time.Millisecond))
+	assert.Equal(t, int64(50), result.UpstreamLatency.Count)
+	assert.InDelta(t, 50*time.Millisecond, result.UpstreamLatency.Max, float64(time.Millisecond))
+}
+
+func TestAccumulator_ResourceLatenciesBounded(t *testing.T) {
+	first, second := service.NewAccumulator(), service.NewAccumulator()
+
+	for i := 0; i < 3*service.MaxResourceLatencies; i++ {
+		// Половина запросов — к /hot, остальные к ресурсам, которые встречаются один раз.
+		first.Add(&domain.LogData{Resource: "/hot", StatusCode: "200", RequestTime: time.Millisecond, HasRequestTime: true})
+		first.Add(&domain.LogData{Resource: fmt.Sprintf("/once/%d", i), StatusCode: "200", RequestTime: time.Second, HasRequestTime: true})
+		second.Add(&domain.LogData{Resource: fmt.Sprintf("/other/%d", i), StatusCode: "200", RequestTime: time.Second, HasRequestTime: true})
+
+		require.LessOrEqual(t, first.ResourceLatencies(), 2*service.MaxResourceLatencies)
+	}
+
+	first.Merge(second)
+	assert.LessOrEqual(t, first.ResourceLatencies(), 2*service.MaxResourceLatencies)
+
+	result := first.Process(service.TopN, time.Time{}, time.Time{}, 0)
+	assert.Equal(t, int64(3*service.MaxResourceLatencies), result.ResourceLatencies["/hot"].Count)
+}
+
+type sliceReader []domain.LogRecord
+
+func (r sliceReader) ReadLines(*domain.InputConfig) (chan domain.LogRecord, error) {
+	lines := make(chan domain.LogRecord, len(r))
+	for _, record := range r {
+		lines <- record
+	}
+
+	close(lines)
+
+	return lines, nil
+}
+
+// records нумерует строки источника так же, как это делают читатели.
+func records(source string, lines ...string) sliceReader {
+	result := make(sliceReader, 0, len(lines))
+
+	var offset int64
+
+	for i, line := range lines {
+		result = append(result, domain.LogRecord{Source: source, Offset: offset, Line: int64(i + 1), Raw: []byte(line)})
+		offset += int64(len(line) + 1)
+	}
+
+	return result
+}
+
+func TestAnalyticsService_ProcessResourceLatencies(t *testing.T) {
+	formatParser, err := parser.NewFormatParser(parser.CommonLogFormat + " $request_time")
+	assert.NoError(t, err)
+
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /slow HTTP/1.1" 200 10 2.000`,
+		`10.0.0.1 - - [10/Oct/2023:13:55:37 +0000] "GET /slow HTTP/1.1" 200 10 4.000`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:38 +0000] "GET /fast HTTP/1.1" 200 10 0.010`,
+	)
+
+	result, err := service.NewAnalyticsService(formatParser, lines).Process(&domain.InputConfig{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(3), result.RequestLatency.Count)
+	assert.InDelta(t, 4*time.Second, result.RequestLatency.Max, float64(10*time.Millisecond))
+	assert.InDelta(t, 4*time.Second, result.ResourceLatencies["/slow"].Max, float64(50*time.Millisecond))
+	assert.InDelta(t, 10*time.Millisecond, result.ResourceLatencies["/fast"].P50, float64(time.Millisecond))
+}
+
+func TestAnalyticsService_ProcessApproxTop(t *testing.T) {
+	formatParser, err := parser.NewFormatParser(parser.CommonLogFormat + " $request_time")
+	assert.NoError(t, err)
+
+	lines := make([]string, 0, 1000)
+
+	for i := 0; i < 1000; i++ {
+		resource := fmt.Sprintf("/unique/%d", i)
+		if i%2 == 0 {
+			resource = fmt.Sprintf("/hot/%d", i%3)
+		}
+
+		lines = append(lines, fmt.Sprintf(`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET %s HTTP/1.1" 200 10 0.100`, resource))
+	}
+
+	config := &domain.InputConfig{ApproxTop: 10}
+	result, err := service.NewAnalyticsService(formatParser, records("access.log", lines...)).Process(config)
+	assert.NoError(t, err)
+
+	require.NotNil(t, result.ApproxTop)
+	assert.Equal(t, 10, result.ApproxTop.Capacity)
+	assert.Len(t, result.MostRequestedResources, service.TopN)
+
+	for i := 0; i < 3; i++ {
+		resource := fmt.Sprintf("/hot/%d", i)
+		exact := int64(len(lines)) / 2 / 3
+
+		require.Contains(t, result.MostRequestedResources, resource)
+		assert.GreaterOrEqual(t, result.MostRequestedResources[resource], exact)
+		assert.LessOrEqual(t, result.MostRequestedResources[resource]-result.ApproxTop.ResourceErrors[resource], exact+1)
+		assert.LessOrEqual(t, result.ApproxTop.ResourceErrors[resource], int64(len(lines)/10))
+		assert.Positive(t, result.ResourceLatencies[resource].Count)
+	}
+}
+
+func TestAnalyticsService_ProcessWorkers(t *testing.T) {
+	lines := append(records("b.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /a HTTP/1.1" 200 10`,
+		`garbage`,
+		`10.0.0.2 - - [10/Oct/2023:13:56:36 +0000] "GET /b HTTP/1.1" 503 20`,
+	), records("a.log",
+		`10.0.0.3 - - [10/Oct/2023:13:57:36 +0000] "GET /a HTTP/1.1" 404 30`,
+		`10.0.0.3 - - [10/Oct/2023:13:58:36 +0000] "GET /a HTTP/1.1" 200 40`,
+	)...)
+
+	for _, workers := range []int{1, 3, 16} {
+		t.Run(strconv.Itoa(workers), func(t *testing.T) {
+			config := &domain.InputConfig{Workers: workers}
+			result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"a.log", "b.log"}, result.Filenames)
+			assert.Equal(t, int64(5), result.TotalLines)
+			assert.Equal(t, int64(4), result.TotalRequests)
+			assert.Equal(t, int64(100), result.TotalResponseSize)
+			assert.Equal(t, int64(1), result.TotalServerErrorsLogs)
+			assert.Equal(t, int64(1), result.ParseErrors.Total)
+			assert.Equal(t, map[string]int64{"/a": 3, "/b": 1}, result.MostRequestedResources)
+			assert.Equal(t, int64(3), result.UniqueVisitors.IPs.Estimate)
+			assert.Len(t, result.TimeSeries.Buckets, 4)
+		})
+	}
+}
+
+func TestAnalyticsService_ProcessRepeated(t *testing.T) {
+	// У ресурсов и клиентов разное число запросов, чтобы топы не зависели от порядка обхода словарей.
+	var lines []string
+
+	for i, resource := range []string{"/a", "/b", "/c", "/d"} {
+		for j := i; j < 4; j++ {
+			lines = append(lines, fmt.Sprintf(`10.0.0.%d - - [10/Oct/2023:13:5%d:36 +0000] "GET %s HTTP/1.1" 200 %d`, i, j, resource, 10*(i+1)))
+		}
+	}
+
+	// JSON-строку разбирает только --parser auto, пока формат файла не закреплён: за один проход
+	// из 12 строк он не закрепляется, а за девять проходов закрепился бы, если бы состояние определения
+	// переживало проход.
+	jsonLine := `{"time_local":"10/Oct/2023:13:50:36 +0000","remote_addr":"10.0.0.9","request":"GET /e HTTP/1.1","status":"200"}`
+	reader := records("access.log", append(lines, jsonLine, "garbage")...)
+
+	candidates, err := parser.DefaultCandidates("")
+	require.NoError(t, err)
+
+	parsers := []struct {
+		name        string
+		parser      parser.LogParser
+		requests    int64
+		parseErrors int64
+	}{
+		{name: "nginx", parser: parser.NginxParser{}, requests: 10, parseErrors: 2},
+		{name: "auto", parser: parser.NewDetectingParser(parser.DefaultDetectLines, candidates...), requests: 11, parseErrors: 1},
+	}
+
+	for _, tt := range parsers {
+		for _, approxTop := range []int{0, 10} {
+			t.Run(tt.name+"/"+strconv.Itoa(approxTop), func(t *testing.T) {
+				analyticsService := service.NewAnalyticsService(tt.parser, reader)
+				config := &domain.InputConfig{Workers: 2, ApproxTop: approxTop}
+
+				first, err := analyticsService.Process(config)
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.requests, first.TotalRequests)
+				assert.Equal(t, tt.parseErrors, first.ParseErrors.Total)
+				assert.Equal(t, map[string]int64{"/a": 4, "/b": 3, "/c": 2}, first.MostRequestedResources)
+
+				// Каждый вызов копит статистику заново: повторные и одновременные вызовы дают тот же результат.
+				results := make([]*domain.AnalysisResult, 8)
+
+				var wg sync.WaitGroup
+
+				for i := range results {
+					wg.Add(1)
+
+					go func() {
+						defer wg.Done()
+
+						results[i], _ = analyticsService.Process(config)
+					}()
+				}
+
+				wg.Wait()
+
+				for _, result := range results {
+					assert.Equal(t, first, result)
+				}
+			})
+		}
+	}
+}
+
+func TestAnalyticsService_ProcessClients(t *testing.T) {
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /login HTTP/1.1" 401 10`,
+		`10.0.0.1 - - [10/Oct/2023:13:56:36 +0000] "GET /login HTTP/1.1" 401 10`,
+		`10.0.0.1 - - [10/Oct/2023:13:57:36 +0000] "GET /admin HTTP/1.1" 503 30`,
+		`10.0.0.1 - - [10/Oct/2023:13:58:36 +0000] "GET / HTTP/1.1" 200 50`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:40 +0000] "GET / HTTP/1.1" 200 100`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:50 +0000] "GET / HTTP/1.1" 200 100`,
+		`10.0.0.3 - - [10/Oct/2023:13:55:00 +0000] "GET /a HTTP/1.1" 200 1`,
+		`10.0.0.4 - - [10/Oct/2023:13:55:00 +0000] "GET /b HTTP/1.1" 200 1`,
+		`10.0.0.5 - - [10/Oct/2023:13:55:00 +0000] "GET /c HTTP/1.1" 200 1`,
+	)
+
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(&domain.InputConfig{Workers: 3})
+	require.NoError(t, err)
+
+	require.Len(t, result.TopClients, service.TopN)
+	assert.Nil(t, result.Client)
+
+	first := result.TopClients[0]
+	assert.Equal(t, "10.0.0.1", first.IP)
+	assert.Equal(t, int64(4), first.Requests)
+	assert.Equal(t, int64(100), first.Bytes)
+	assert.Equal(t, int64(2), first.ClientErrors)
+	assert.Equal(t, int64(1), first.ServerErrors)
+	assert.InDelta(t, 0.75, first.ErrorRate(), 1e-9)
+	assert.Equal(t, mustParseDate("2023-10-10T13:55:36+0000"), first.FirstRequest)
+	assert.Equal(t, mustParseDate("2023-10-10T13:58:36+0000"), first.LastRequest)
+	assert.Equal(t, map[string]int64{"/login": 2, "/admin": 1, "/": 1}, first.Resources)
+
+	assert.Equal(t, "10.0.0.2", result.TopClients[1].IP)
+	// При равном числе запросов клиенты упорядочены по адресу.
+	assert.Equal(t, "10.0.0.3", result.TopClients[2].IP)
+
+	config := &domain.InputConfig{ClientIP: "10.0.0.1"}
+	result, err = service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	require.NoError(t, err)
+
+	require.NotNil(t, result.Client)
+	assert.Equal(t, first, *result.Client)
+	assert.Equal(t, int64(4), result.TotalRequests)
+	assert.Len(t, result.TimeSeries.Buckets, 4)
+
+	config = &domain.InputConfig{ClientIP: "192.168.0.1"}
+	result, err = service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, &domain.Client{IP: "192.168.0.1"}, result.Client)
+}
+
+func TestAccumulator_ClientResourcesBounded(t *testing.T) {
+	first, second := service.NewAccumulator(), service.NewAccumulator()
+
+	// Сканер перебирает тысячу адресов и часто возвращается к /login.
+	for i := 0; i < 1000; i++ {
+		first.Add(&domain.LogData{IPAddress: "10.0.0.1", Resource: fmt.Sprintf("/scan/%d", i), StatusCode: "404"})
+
+		if i%10 == 0 {
+			second.Add(&domain.LogData{IPAddress: "10.0.0.1", Resource: "/login", StatusCode: "401"})
+		}
+	}
+
+	assert.Equal(t, domain.MaxClientResources, first.ClientResources("10.0.0.1"))
+
+	first.Merge(second)
+	assert.Equal(t, domain.MaxClientResources, first.ClientResources("10.0.0.1"))
+
+	client := first.Process(service.TopN, time.Time{}, time.Time{}, 0).TopClients[0]
+	assert.Equal(t, int64(1100), client.Requests)
+
+	// Счётчик /login — оценка сверху: он мог унаследовать счётчик вытесненного ресурса.
+	assert.GreaterOrEqual(t, client.Resources["/login"], int64(100))
+}
+
+func TestAccumulator_ClientsBounded(t *testing.T) {
+	first, second := service.NewAccumulator(), service.NewAccumulator()
+
+	// Три частых клиента и поток адресов, каждый из которых встречается один раз.
+	for i := 0; i < 3*service.MaxClients; i++ {
+		first.Add(&domain.LogData{IPAddress: fmt.Sprintf("10.1.%d.%d", i/256, i%256), Resource: "/", StatusCode: "200"})
+		first.Add(&domain.LogData{IPAddress: fmt.Sprintf("10.0.0.%d", i%3), Resource: "/", StatusCode: "200"})
+		second.Add(&domain.LogData{IPAddress: fmt.Sprintf("10.2.%d.%d", i/256, i%256), Resource: "/", StatusCode: "200"})
+	}
+
+	assert.LessOrEqual(t, first.Clients(), 2*service.MaxClients)
+
+	first.Merge(second)
+	assert.LessOrEqual(t, first.Clients(), 2*service.MaxClients)
+
+	result := first.Process(3, time.Time{}, time.Time{}, 0)
+	assert.Equal(t, int64(9*service.MaxClients), result.TotalRequests)
+	require.Len(t, result.TopClients, 3)
+
+	for i, client := range result.TopClients {
+		assert.Equal(t, fmt.Sprintf("10.0.0.%d", i), client.IP)
+		assert.Equal(t, int64(service.MaxClients), client.Requests)
+	}
+}
+
+func TestAnalyticsService_ProcessAgentClasses(t *testing.T) {
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10 "-" "Mozilla/5.0 (compatible; Googlebot/2.1)"`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:37 +0000] "GET / HTTP/1.1" 200 10 "-" "curl/8.5.0"`,
+		`10.0.0.3 - - [10/Oct/2023:13:55:38 +0000] "GET / HTTP/1.1" 200 10 "-" "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) Mobile"`,
+		`10.0.0.4 - - [10/Oct/2023:13:55:39 +0000] "GET / HTTP/1.1" 200 10 "-" "Mozilla/5.0 (X11; Linux x86_64) Firefox/125.0"`,
+	)
+
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(&domain.InputConfig{Workers: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int64{"bot": 2, "mobile": 1, "desktop": 1}, result.AgentClasses.Devices)
+	assert.Equal(t, map[string]int64{"Googlebot": 1, "curl": 1, "Other": 1, "Firefox": 1}, result.AgentClasses.Families)
+	assert.Equal(t, map[string]int64{"Other": 2, "iOS": 1, "Linux": 1}, result.AgentClasses.OSes)
+
+	config := &domain.InputConfig{FieldFilters: []domain.FieldFilter{{Field: domain.AGENTCLASS, Value: "bot"}}}
+	result, err = service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), result.TotalRequests)
+}
+
+func TestAnalyticsService_ProcessEndpoints(t *testing.T) {
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /users/123?tab=posts HTTP/1.1" 200 10`,
+		`10.0.0.1 - - [10/Oct/2023:13:55:37 +0000] "GET /users/456 HTTP/1.1" 200 10`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:38 +0000] "GET /users/789/avatar HTTP/1.1" 200 10`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:39 +0000] "GET /static/app.3f2a.js HTTP/1.1" 200 10`,
+		`10.0.0.3 - - [10/Oct/2023:13:55:40 +0000] "GET /static/app.9c1b.js HTTP/1.1" 200 10`,
+	)
+
+	config := &domain.InputConfig{Workers: 2, Rewrites: []string{`^/static/.*\.js$=>/static/*.js`}}
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int64{"/users/{id}": 2, "/static/*.js": 2, "/users/{id}/avatar": 1}, result.MostRequestedEndpoints)
+	assert.Len(t, result.MostRequestedResources, service.TopN)
+
+	config = &domain.InputConfig{Filters: []string{`endpoint == "/users/{id}"`}}
+	result, err = service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), result.TotalRequests)
+}
+
+func TestAnalyticsService_ProcessQuery(t *testing.T) {
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /search?q=go&page=2 HTTP/1.1" 200 10`,
+		`10.0.0.1 - - [10/Oct/2023:13:55:37 +0000] "GET /search?q=rust&page=3 HTTP/1.1" 200 10`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:38 +0000] "GET /search?q=go&utm_source=mail HTTP/1.1" 200 10`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:39 +0000] "GET /?utm_source=mail&utm_source=ads HTTP/1.1" 200 10`,
+		`10.0.0.3 - - [10/Oct/2023:13:55:40 +0000] "GET /about?utm_source=&ref=x HTTP/1.1" 200 10`,
+		`10.0.0.3 - - [10/Oct/2023:13:55:41 +0000] "GET /about HTTP/1.1" 200 10`,
+	)
+
+	for _, approxTop := range []int{0, 100} {
+		config := &domain.InputConfig{Workers: 2, QueryParam: "utm_source", ApproxTop: approxTop}
+		result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]int64{"/search": 3, "/about": 2, "/": 1}, result.MostRequestedPaths)
+		// Имя параметра считается один раз на запрос, даже если параметр повторяется.
+		assert.Equal(t, map[string]int64{"q": 3, "utm_source": 3, "page": 2}, result.MostFrequentQueryParams)
+		assert.Equal(t, "utm_source", result.QueryParam)
+		assert.Equal(t, map[string]int64{"mail": 2, "ads": 1}, result.QueryParamValues["utm_source"])
+		assert.Equal(t, map[string]int64{"go": 2, "rust": 1}, result.QueryParamValues["q"])
+
+		if approxTop > 0 {
+			assert.Equal(t, map[string]int64{"mail": 0, "ads": 0}, result.ApproxTop.QueryValueErrors["utm_source"])
+		}
+	}
+
+	config := &domain.InputConfig{Filters: []string{`path == "/search"`}}
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), result.TotalRequests)
+}
+
+func TestAnalyticsService_ProcessFileFormats(t *testing.T) {
+	candidates, err := parser.DefaultCandidates("")
+	assert.NoError(t, err)
+
+	lines := append(records("a.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10`,
+		`10.0.0.1 - - [10/Oct/2023:13:55:37 +0000] "GET / HTTP/1.1" 200 10`,
+		`garbage`,
+	), records("b.log", `garbage $1`)...)
+
+	result, err := service.NewAnalyticsService(parser.NewDetectingParser(2, candidates...), lines).Process(&domain.InputConfig{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]int64{"a.log": {parser.FormatCommon: 2}}, result.FileFormats)
+	assert.Equal(t, int64(4), result.TotalLines)
+	assert.Equal(t, int64(2), result.ParseErrors.Total)
+	assert.Equal(t, map[string]int64{"a.log": 1, "b.log": 1}, result.ParseErrors.ByFile)
+	assert.Equal(t, map[string]int64{parser.ErrorKindFormat: 2}, result.ParseErrors.ByKind)
+	assert.InDelta(t, 0.5, result.ParseErrorRate(), 1e-9)
+	assert.ElementsMatch(t, []domain.ParseErrorSample{
+		{Filename: "a.log", LineNumber: 3, Line: "garbage", Kind: parser.ErrorKindFormat},
+		{Filename: "b.log", LineNumber: 1, Line: "garbage $1", Kind: parser.ErrorKindFormat},
+	}, result.ParseErrors.Samples)
+}
+
+func TestAnalyticsService_ProcessReadErrors(t *testing.T) {
+	lines := records("access.log", `10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 10`)
+	lines = append(lines, domain.LogRecord{
+		Source: "access.log.1.gz",
+		Line:   1,
+		Err:    fmt.Errorf("%w: %w", domain.ErrReadLog, io.ErrUnexpectedEOF),
+	})
+
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(&domain.InputConfig{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(1), result.TotalRequests)
+	assert.Equal(t, int64(1), result.ParseErrors.Total)
+	assert.Equal(t, map[string]int64{"access.log.1.gz": 1}, result.ParseErrors.ByFile)
+	assert.Equal(t, map[string]int64{parser.ErrorKindRead: 1}, result.ParseErrors.ByKind)
+	assert.Equal(t, []domain.ParseErrorSample{{
+		Filename:   "access.log.1.gz",
+		LineNumber: 1,
+		Line:       "не удалось дочитать лог: unexpected EOF",
+		Kind:       parser.ErrorKindRead,
+	}}, result.ParseErrors.Samples)
+}
+
+func TestAnalyticsService_ProcessTimeSeries(t *testing.T) {
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0300] "GET / HTTP/1.1" 200 0`,
+		`10.0.0.1 - - [10/Oct/2023:13:55:59 +0300] "GET / HTTP/1.1" 200 700`,
+		`10.0.0.1 - - [10/Oct/2023:13:58:01 +0300] "GET / HTTP/1.1" 200 1500`,
+	)
+
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(&domain.InputConfig{})
+	assert.NoError(t, err)
+
+	start := time.Date(2023, time.October, 10, 10, 55, 0, 0, time.UTC)
+	assert.Equal(t, domain.TimeSeries{Width: time.Minute, Buckets: []domain.TimeBucket{
+		{Start: start, Requests: 2, Bytes: 700, Status2xx: 2},
+		{Start: start.Add(time.Minute)},
+		{Start: start.Add(2 * time.Minute)},
+		{Start: start.Add(3 * time.Minute), Requests: 1, Bytes: 1500, Status2xx: 1},
+	}}, result.TimeSeries)
+
+	assert.Equal(t, []domain.SizeBucket{
+		{From: 0, To: 0, Count: 1},
+		{From: 1, To: 1}, {From: 2, To: 3}, {From: 4, To: 7}, {From: 8, To: 15}, {From: 16, To: 31},
+		{From: 32, To: 63}, {From: 64, To: 127}, {From: 128, To: 255}, {From: 256, To: 511},
+		{From: 512, To: 1023, Count: 1},
+		{From: 1024, To: 2047, Count: 1},
+	}, result.SizeDistribution)
+}
+
+func TestAnalyticsService_ProcessTimeSeriesBucket(t *testing.T) {
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET / HTTP/1.1" 200 100`,
+		`10.0.0.1 - - [10/Oct/2023:14:05:00 +0000] "GET / HTTP/1.1" 404 10`,
+		`10.0.0.1 - - [10/Oct/2023:14:59:59 +0000] "GET / HTTP/1.1" 503 20`,
+		`10.0.0.1 - - [10/Oct/2023:16:00:00 +0000] "GET / HTTP/1.1" 301 0`,
+	)
+
+	config := &domain.InputConfig{Bucket: domain.BucketWidths["1h"]}
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	assert.NoError(t, err)
+
+	start := time.Date(2023, time.October, 10, 13, 0, 0, 0, time.UTC)
+	assert.Equal(t, domain.TimeSeries{Width: time.Hour, Explicit: true, Buckets: []domain.TimeBucket{
+		{Start: start, Requests: 1, Bytes: 100, Status2xx: 1},
+		{Start: start.Add(time.Hour), Requests: 2, Bytes: 30, Status4xx: 1, Status5xx: 1},
+		{Start: start.Add(2 * time.Hour)},
+		{Start: start.Add(3 * time.Hour), Requests: 1, Status3xx: 1},
+	}}, result.TimeSeries)
+}
+
+func TestAnalyticsService_ProcessTimeSeriesBucketLimit(t *testing.T) {
+	// Три дня по минутам — 4321 интервал, больше MaxExplicitTimeSeriesBuckets: ряд укрупняется до 5m.
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:00:00:00 +0000] "GET / HTTP/1.1" 200 100`,
+		`10.0.0.1 - - [13/Oct/2023:00:00:00 +0000] "GET / HTTP/1.1" 200 100`,
+	)
+
+	config := &domain.InputConfig{Bucket: domain.BucketWidths["1m"]}
+	result, err := service.NewAnalyticsService(parser.NginxParser{}, lines).Process(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5*time.Minute, result.TimeSeries.Width)
+	assert.True(t, result.TimeSeries.Explicit)
+	assert.Len(t, result.TimeSeries.Buckets, 3*24*12+1)
+	assert.LessOrEqual(t, len(result.TimeSeries.Buckets), domain.MaxExplicitTimeSeriesBuckets)
+}
+
+func TestParseErrors_SamplesAreBounded(t *testing.T) {
+	parseErrors := domain.NewParseErrors()
+
+	for i := 0; i < domain.MaxParseErrorSamples*2; i++ {
+		record := domain.LogRecord{Source: "a.log", Line: int64(i + 1), Raw: []byte(strings.Repeat("x", domain.MaxParseErrorSampleSize*2))}
+		parseErrors.Add(record, parser.ErrorKindData)
+	}
+
+	assert.Equal(t, int64(domain.MaxParseErrorSamples*2), parseErrors.Total)
+	assert.Len(t, parseErrors.Samples, domain.MaxParseErrorSamples)
+	assert.Len(t, parseErrors.Samples[0].Line, domain.MaxParseErrorSampleSize+len("..."))
 }
 
 func assertAnalysisResult(t *testing.T, expected, actual *domain.AnalysisResult) {
@@ -154,3 +689,38 @@ func assertTopNMaps(t *testing.T, name string, expected, actual map[string]int64
 		assert.Equal(t, expectedValue, actualValue, "Value mismatch for key %s in %s", key, name)
 	}
 }
+
+func TestAnalyticsService_Compare(t *testing.T) {
+	line := func(day int, resource string) string {
+		return fmt.Sprintf(`10.0.0.1 - - [%02d/Oct/2023:13:55:36 +0000] "GET %s HTTP/1.1" 200 10`, day, resource)
+	}
+
+	var lines []string
+
+	// 10 октября /d четвёртый и в топ не входит, 11 октября он первый, а /c выпадает из топа.
+	for resource, counts := range map[string][2]int{"/a": {5, 3}, "/b": {4, 2}, "/c": {3, 1}, "/d": {2, 6}} {
+		for i := 0; i < counts[0]; i++ {
+			lines = append(lines, line(10, resource))
+		}
+
+		for i := 0; i < counts[1]; i++ {
+			lines = append(lines, line(11, resource))
+		}
+	}
+
+	for _, approxTop := range []int{0, 10} {
+		baseConfig := &domain.InputConfig{From: mustParseDate("2023-10-10"), To: mustParseDate("2023-10-11"), ApproxTop: approxTop}
+		inputConfig := &domain.InputConfig{From: mustParseDate("2023-10-11"), To: mustParseDate("2023-10-12"), ApproxTop: approxTop}
+
+		comparison, err := service.NewAnalyticsService(parser.NginxParser{}, records("access.log", lines...)).Compare(baseConfig, inputConfig)
+		require.NoError(t, err)
+
+		assert.Equal(t, []domain.CountChange{
+			{Value: "/d", Base: 2, Current: 6, Status: domain.EntryNew},
+			{Value: "/a", Base: 5, Current: 3, Status: domain.EntryKept},
+			{Value: "/b", Base: 4, Current: 2, Status: domain.EntryKept},
+			{Value: "/c", Base: 3, Current: 1, Status: domain.EntryGone},
+		}, comparison.Resources)
+		assert.Len(t, comparison.Current.MostRequestedResources, service.TopN)
+	}
+}
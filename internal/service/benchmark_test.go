@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/4domm/ngxstat/internal/service"
+)
+
+const benchmarkLines = 100_000
+
+// benchmarkRecords генерирует строки combined-формата с разными адресами, ресурсами и кодами ответа.
+func benchmarkRecords() (sliceReader, int64) {
+	lines := make([]string, benchmarkLines)
+
+	var size int64
+
+	for i := range lines {
+		lines[i] = fmt.Sprintf(
+			`10.0.%d.%d - - [10/Oct/2023:%02d:%02d:36 +0000] "GET /api/v1/items/%d?page=%d HTTP/1.1" %d %d "https://example.com/%d" "Mozilla/5.0"`,
+			i/256%256, i%256, i/3600%24, i/60%60, i%500, i%7, 200+i%4*100, i%10_000, i%50)
+		size += int64(len(lines[i]) + 1)
+	}
+
+	return records("access.log", lines...), size
+}
+
+// benchmarkWorkers добавляет к списку число процессоров, если его там нет.
+func benchmarkWorkers(workers ...int) []int {
+	if !slices.Contains(workers, runtime.GOMAXPROCS(0)) {
+		workers = append(workers, runtime.GOMAXPROCS(0))
+	}
+
+	return workers
+}
+
+// BenchmarkAnalyticsService_Process показывает, как пропускная способность (MB/s) растёт с числом
+// обработчиков: go test -bench Process -cpu 1,2,4,8 ./internal/service.
+func BenchmarkAnalyticsService_Process(b *testing.B) {
+	lines, size := benchmarkRecords()
+
+	formatParser, err := parser.NewFormatParser(parser.CombinedLogFormat)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, workers := range benchmarkWorkers(1, 2, 4, 8) {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(size)
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				config := &domain.InputConfig{Workers: workers}
+				if _, err := service.NewAnalyticsService(formatParser, lines).Process(config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAnalyticsService_ProcessDetecting — то же с автоопределением формата (--parser auto).
+func BenchmarkAnalyticsService_ProcessDetecting(b *testing.B) {
+	lines, size := benchmarkRecords()
+
+	candidates, err := parser.DefaultCandidates("")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, workers := range benchmarkWorkers(1) {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(size)
+
+			for i := 0; i < b.N; i++ {
+				config := &domain.InputConfig{Workers: workers}
+				logParser := parser.NewDetectingParser(parser.DefaultDetectLines, candidates...)
+
+				if _, err := service.NewAnalyticsService(logParser, lines).Process(config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAccumulator_Merge оценивает стоимость объединения частичных накопителей в конце Process.
+func BenchmarkAccumulator_Merge(b *testing.B) {
+	partial := service.NewAccumulator()
+
+	for i := 0; i < 10_000; i++ {
+		partial.Add(&domain.LogData{
+			Filename:   "access.log",
+			IPAddress:  fmt.Sprintf("10.0.%d.%d", i/256%256, i%256),
+			Resource:   fmt.Sprintf("/items/%d", i%500),
+			StatusCode: "200",
+		})
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		service.NewAccumulator().Merge(partial)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"maps"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// Compare собирает базовый (baseConfig) и текущий (inputConfig) периоды и сравнивает их. Топы
+// урезаются только для отчёта: числа вхождений в сравнении берутся из счётчиков до урезания, поэтому
+// известны и у значений, которые вошли в топ только одного из периодов.
+func (s *AnalyticsService) Compare(baseConfig, inputConfig *domain.InputConfig) (*domain.Comparison, error) {
+	base, err := s.Aggregate(baseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.Aggregate(inputConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	baseCounts, currentCounts := base.topCounts(), current.topCounts()
+
+	return domain.NewComparison(
+		ProcessResult(base, baseConfig, baseConfig.From, baseConfig.To),
+		ProcessResult(current, inputConfig, inputConfig.From, inputConfig.To),
+		baseCounts,
+		currentCounts,
+	), nil
+}
+
+// topCounts копирует счётчики, из которых Process выбирает топы; с --approx-top вместо словарей
+// результата берутся оценки отслеживаемых значений.
+func (a *Accumulator) topCounts() domain.TopCounts {
+	counts := domain.TopCounts{
+		StatusCodes: maps.Clone(a.AnalysisResult.MostFrequentStatusCodes),
+		Resources:   maps.Clone(a.AnalysisResult.MostRequestedResources),
+		Referrers:   maps.Clone(a.AnalysisResult.MostFrequentReferrers),
+	}
+
+	if a.topResources == nil {
+		return counts
+	}
+
+	for _, counter := range a.topResources.Top(a.approxTop()) {
+		counts.Resources[counter.Key] = counter.Count
+	}
+
+	for _, counter := range a.topReferrers.Top(a.approxTop()) {
+		counts.Referrers[counter.Key] = counter.Count
+	}
+
+	return counts
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+// SlidingWindow открывает окно --follow тестам пакета service_test.
+type SlidingWindow struct {
+	window *slidingWindow
+}
+
+func NewSlidingWindow(size time.Duration, approxTop int) *SlidingWindow {
+	return &SlidingWindow{window: newSlidingWindow(size, approxTop)}
+}
+
+func (w *SlidingWindow) Add(logData *domain.LogData) {
+	w.window.add(logData)
+}
+
+func (w *SlidingWindow) Slots() int {
+	return len(w.window.slots)
+}
+
+// TopCapacity — суммарная ёмкость приближённых топов ресурсов всех интервалов окна.
+func (w *SlidingWindow) TopCapacity() int {
+	capacity := 0
+	for _, slot := range w.window.slots {
+		capacity += slot.approxTop()
+	}
+
+	return capacity
+}
+
+func (w *SlidingWindow) Merge() *domain.AnalysisResult {
+	end := w.window.end(time.Now())
+
+	return w.window.merge().Process(TopN, end.Add(-w.window.size), end, 0)
+}
+
+// Clients — число клиентов, статистику которых хранит накопитель.
+func (a *Accumulator) Clients() int {
+	return len(a.clients)
+}
+
+// ClientResources — число ресурсов, которые накопитель считает для клиента ip.
+func (a *Accumulator) ClientResources(ip string) int {
+	return len(a.clients[ip].Resources)
+}
+
+// ResourceLatencies — число ресурсов, для которых накопитель хранит гистограммы задержек.
+func (a *Accumulator) ResourceLatencies() int {
+	return len(a.resourceLatencies)
+}
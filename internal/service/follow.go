@@ -0,0 +1,93 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+)
+
+// Follow обрабатывает бесконечный поток строк (см. reader.TailReader) и раз в inputConfig.Refresh
+// передаёт в render статистику за последние inputConfig.Window по времени лога: окно заканчивается
+// на самой поздней прочитанной записи, а не на текущем времени хоста. Ошибки разбора считаются
+// с момента запуска. Когда читатель закрывает поток, render вызывается в последний раз.
+func (s *AnalyticsService) Follow(inputConfig *domain.InputConfig, render func(*domain.AnalysisResult)) error {
+	stages, err := newPipeline(s.LogParser, inputConfig)
+	if err != nil {
+		return err
+	}
+
+	lines, err := s.Reader.ReadLines(inputConfig)
+	if err != nil {
+		return err
+	}
+
+	// Ошибки разбора не привязаны к окну: они копятся с запуска и добавляются к каждому результату.
+	lineStats := newLineCounter()
+	logData := s.parseAndFilter(lines, inputConfig, stages, lineStats)
+	window := newSlidingWindow(inputConfig.Window, inputConfig.ApproxTop)
+
+	ticker := time.NewTicker(inputConfig.Refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data, ok := <-logData:
+			if !ok {
+				render(windowResult(window, lineStats, time.Now(), inputConfig))
+				return nil
+			}
+
+			window.add(data)
+		case now := <-ticker.C:
+			render(windowResult(window, lineStats, now, inputConfig))
+		}
+	}
+}
+
+func windowResult(
+	window *slidingWindow,
+	lineStats *lineCounter,
+	now time.Time,
+	inputConfig *domain.InputConfig,
+) *domain.AnalysisResult {
+	accumulator := window.merge()
+	lineStats.mergeInto(accumulator.AnalysisResult)
+
+	end := window.end(now)
+
+	return ProcessResult(accumulator, inputConfig, end.Add(-inputConfig.Window), end)
+}
+
+// lineCounter — прочитанные строки и ошибки разбора Follow с момента запуска: горутины разбора
+// пополняют их, пока отрисовка читает.
+type lineCounter struct {
+	mu          sync.Mutex
+	lines       int64
+	parseErrors domain.ParseErrors
+}
+
+func newLineCounter() *lineCounter {
+	return &lineCounter{parseErrors: domain.NewParseErrors()}
+}
+
+func (lc *lineCounter) add(record domain.LogRecord, err error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.lines++
+
+	if err != nil {
+		lc.parseErrors.Add(record, parser.ErrorKind(err))
+	}
+}
+
+// mergeInto добавляет строки и ошибки разбора к result.
+func (lc *lineCounter) mergeInto(result *domain.AnalysisResult) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	result.TotalLines += lc.lines
+	result.ParseErrors.Merge(lc.parseErrors)
+}
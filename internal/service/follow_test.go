@@ -0,0 +1,170 @@
+package service_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/4domm/ngxstat/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsService_Follow(t *testing.T) {
+	now := time.Now()
+	line := func(ago time.Duration, resource, status string) string {
+		return `10.0.0.1 - - [` + now.Add(-ago).Format(parser.NginxDateFormat) + `] "GET ` + resource +
+			` HTTP/1.1" ` + status + ` 100`
+	}
+
+	lines := records("access.log",
+		line(time.Hour, "/old", "500"),
+		line(2*time.Minute, "/recent", "200"),
+		line(time.Minute, "/recent", "502"),
+		line(time.Second, "/new", "200"),
+		"garbage",
+	)
+
+	var results []*domain.AnalysisResult
+
+	config := &domain.InputConfig{Window: 5 * time.Minute, Refresh: time.Hour}
+	err := service.NewAnalyticsService(parser.NginxParser{}, lines).Follow(config, func(result *domain.AnalysisResult) {
+		results = append(results, result)
+	})
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	result := results[0]
+	assert.Equal(t, int64(3), result.TotalRequests)
+	assert.Equal(t, int64(1), result.TotalServerErrorsLogs)
+	assert.Equal(t, map[string]int64{"/recent": 2, "/new": 1}, result.MostRequestedResources)
+	assert.Equal(t, int64(5), result.TotalLines)
+	assert.Equal(t, int64(1), result.ParseErrors.Total)
+	assert.Equal(t, 5*time.Minute, result.To.Sub(result.From))
+}
+
+func TestAnalyticsService_FollowHistoricalLog(t *testing.T) {
+	// Строки из прошлого (накопившийся лог или часы nginx, отстающие от хоста) попадают в окно
+	// относительно самой поздней записи.
+	newest := time.Date(2023, 10, 10, 13, 55, 36, 0, time.UTC)
+	line := func(ago time.Duration, resource string) string {
+		return `10.0.0.1 - - [` + newest.Add(-ago).Format(parser.NginxDateFormat) + `] "GET ` + resource + ` HTTP/1.1" 200 100`
+	}
+
+	lines := records("access.log",
+		line(time.Hour, "/old"),
+		line(3*time.Minute, "/recent"),
+		line(0, "/recent"),
+	)
+
+	var results []*domain.AnalysisResult
+
+	config := &domain.InputConfig{Window: 5 * time.Minute, Refresh: time.Hour}
+	err := service.NewAnalyticsService(parser.NginxParser{}, lines).Follow(config, func(result *domain.AnalysisResult) {
+		results = append(results, result)
+	})
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	result := results[0]
+	assert.Equal(t, int64(2), result.TotalRequests)
+	assert.Equal(t, map[string]int64{"/recent": 2}, result.MostRequestedResources)
+	assert.True(t, newest.Equal(result.To))
+	assert.True(t, newest.Add(-5*time.Minute).Equal(result.From))
+}
+
+func TestSlidingWindow_Backlog(t *testing.T) {
+	// При запуске --follow TailReader читает файлы с начала: шесть часов записей раз в секунду.
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := service.NewSlidingWindow(5*time.Minute, 0)
+
+	for i := 0; i < 6*60*60; i++ {
+		window.Add(&domain.LogData{Timestamp: start.Add(time.Duration(i) * time.Second), Resource: "/a", StatusCode: "200"})
+		require.LessOrEqual(t, window.Slots(), 61)
+	}
+
+	// Запись старше окна относительно самой поздней уже не попадёт ни в один результат.
+	window.Add(&domain.LogData{Timestamp: start, Resource: "/late", StatusCode: "200"})
+
+	// Окно собирается из целых пятисекундных интервалов: интервал, на который приходится его начало,
+	// учитывается целиком.
+	result := window.Merge()
+	assert.Equal(t, int64(5*60+5), result.TotalRequests)
+	assert.Equal(t, map[string]int64{"/a": 5*60 + 5}, result.MostRequestedResources)
+}
+
+func TestSlidingWindow_ApproxTop(t *testing.T) {
+	const approxTop = 600
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := service.NewSlidingWindow(5*time.Minute, approxTop)
+
+	for i := 0; i < 10*60; i++ {
+		timestamp := start.Add(time.Duration(i) * time.Second)
+		// В каждую секунду — /hot и десять ресурсов, которые больше не встречаются.
+		window.Add(&domain.LogData{Timestamp: timestamp, Resource: "/hot", StatusCode: "200"})
+
+		for j := 0; j < 10; j++ {
+			window.Add(&domain.LogData{Timestamp: timestamp, Resource: fmt.Sprintf("/once/%d/%d", i, j), StatusCode: "200"})
+		}
+
+		// Полные скетчи только у нового интервала, остальные 60 интервалов держат усечённые топы.
+		require.LessOrEqual(t, window.TopCapacity(), approxTop+60*max(approxTop/60, 16))
+	}
+
+	result := window.Merge()
+	require.NotNil(t, result.ApproxTop)
+
+	hot, ok := result.MostRequestedResources["/hot"]
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, hot, int64(5*60+5))
+	assert.LessOrEqual(t, hot-result.ApproxTop.ResourceErrors["/hot"], int64(5*60+5))
+}
+
+func TestAccumulator_Merge(t *testing.T) {
+	first, second := service.NewAccumulator(), service.NewAccumulator()
+
+	first.Add(&domain.LogData{Filename: "a.log", Resource: "/a", StatusCode: "200", ResponseSize: 100, Format: "combined"})
+	second.Add(&domain.LogData{Filename: "b.log", Resource: "/a", StatusCode: "503", ResponseSize: 300,
+		RequestTime: time.Second, HasRequestTime: true})
+	second.Add(&domain.LogData{Filename: "a.log", Resource: "/b", StatusCode: "200", ResponseSize: 200, Referer: "ref"})
+
+	first.Merge(second)
+	result := first.Process(service.TopN, time.Time{}, time.Time{}, 0)
+
+	assert.Equal(t, int64(3), result.TotalRequests)
+	assert.Equal(t, int64(600), result.TotalResponseSize)
+	assert.Equal(t, int64(1), result.TotalServerErrorsLogs)
+	assert.Equal(t, []string{"a.log", "b.log"}, result.Filenames)
+	assert.Equal(t, map[string]int64{"/a": 2, "/b": 1}, result.MostRequestedResources)
+	assert.Equal(t, map[string]int64{"ref": 1}, result.MostFrequentReferrers)
+	assert.Equal(t, int64(300), result.Percentile95ResponseSize)
+	assert.Equal(t, int64(1), result.ResourceLatencies["/a"].Count)
+	assert.Equal(t, map[string]map[string]int64{"a.log": {"combined": 1}}, result.FileFormats)
+}
+
+func TestAccumulator_MergeUniqueVisitors(t *testing.T) {
+	first, second := service.NewAccumulator(), service.NewAccumulator()
+
+	for i := 0; i < 1000; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		first.Add(&domain.LogData{IPAddress: ip, UserAgent: "curl", StatusCode: "200"})
+		// Половина адресов второго накопителя пересекается с первым, у пересекающихся другой User-Agent.
+		second.Add(&domain.LogData{IPAddress: fmt.Sprintf("10.0.%d.%d", (i+500)/256, (i+500)%256), UserAgent: "wget"})
+	}
+
+	second.Add(&domain.LogData{IPAddress: "10.0.0.1", RemoteUser: "alice"})
+	second.Add(&domain.LogData{IPAddress: "10.0.0.1", RemoteUser: "bob"})
+
+	first.Merge(second)
+	visitors := first.Process(service.TopN, time.Time{}, time.Time{}, 0).UniqueVisitors
+
+	assert.InDelta(t, 1500, visitors.IPs.Estimate, 1500*3*visitors.IPs.RelativeError)
+	assert.InDelta(t, 2001, visitors.IPUserAgents.Estimate, 2001*3*visitors.IPUserAgents.RelativeError)
+	assert.Equal(t, int64(2), visitors.RemoteUsers.Estimate)
+	assert.InDelta(t, 0.0081, visitors.RemoteUsers.RelativeError, 1e-4)
+}
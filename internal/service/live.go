@@ -0,0 +1,283 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// DefaultMaxEndpoints — число шаблонов путей с собственными счётчиками в метриках serve без --approx-top.
+const DefaultMaxEndpoints = 1000
+
+// LiveStats — счётчики метрик serve с момента запуска. Каждая горутина разбора пишет в собственный
+// шард, и блокировку шарда кроме неё берёт только Sample при запросе метрик: шарды складываются
+// при каждом запросе. Кроме счётчиков метрик ничего не хранится, поэтому память не растёт
+// с числом ресурсов, клиентов и рефереров.
+type LiveStats struct {
+	mu     sync.Mutex
+	shards []*liveShard
+	// endpoints — шаблоны путей с собственными счётчиками: первые maxEndpoints шаблонов, остальные
+	// считаются под domain.OtherEndpoint. Набор только растёт, поэтому счётчики метрик монотонны.
+	endpointsMu  sync.RWMutex
+	endpoints    map[string]struct{}
+	maxEndpoints int
+}
+
+// liveShard — счётчики одной горутины разбора.
+type liveShard struct {
+	mu              sync.Mutex
+	lines           int64
+	parseErrors     int64
+	requests        int64
+	responseBytes   int64
+	statusCodes     map[string]int64
+	methods         map[string]int64
+	endpoints       map[string]int64
+	otherEndpoints  int64
+	responseSizes   *hdrhistogram.Histogram
+	requestLatency  *hdrhistogram.Histogram
+	upstreamLatency *hdrhistogram.Histogram
+}
+
+// NewLiveStats создаёт пустую статистику; approxTop ограничивает число шаблонов путей с собственными
+// счётчиками (0 — DefaultMaxEndpoints).
+func NewLiveStats(approxTop int) *LiveStats {
+	maxEndpoints := approxTop
+	if maxEndpoints <= 0 {
+		maxEndpoints = DefaultMaxEndpoints
+	}
+
+	return &LiveStats{
+		endpoints:    make(map[string]struct{}),
+		maxEndpoints: maxEndpoints,
+	}
+}
+
+func newLiveShard() *liveShard {
+	return &liveShard{
+		statusCodes:     make(map[string]int64),
+		methods:         make(map[string]int64),
+		endpoints:       make(map[string]int64),
+		responseSizes:   hdrhistogram.New(MinHistogramValue, MaxHistogramValue, NumberOfSignificantValueDigits),
+		requestLatency:  newLatencyHistogram(NumberOfSignificantValueDigits),
+		upstreamLatency: newLatencyHistogram(NumberOfSignificantValueDigits),
+	}
+}
+
+// Serve обрабатывает бесконечный поток строк (см. reader.TailReader) в inputConfig.Workers горутинах
+// и копит статистику в stats, пока читатель не закроет поток. В отличие от Follow, окна нет:
+// счётчики только растут, как того ждут метрики Prometheus.
+func (s *AnalyticsService) Serve(inputConfig *domain.InputConfig, stats *LiveStats) error {
+	stages, err := newPipeline(s.LogParser, inputConfig)
+	if err != nil {
+		return err
+	}
+
+	lines, err := s.Reader.ReadLines(inputConfig)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workerCount(inputConfig.Workers); i++ {
+		wg.Add(1)
+
+		shard := stats.newShard()
+
+		go func() {
+			defer wg.Done()
+
+			for record := range lines {
+				logData, err := s.parseRecord(record, inputConfig, stages)
+				stats.add(shard, logData, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// newShard регистрирует шард для очередной горутины разбора.
+func (ls *LiveStats) newShard() *liveShard {
+	shard := newLiveShard()
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.shards = append(ls.shards, shard)
+
+	return shard
+}
+
+// add учитывает строку в шарде горутины разбора.
+func (ls *LiveStats) add(shard *liveShard, logData *domain.LogData, err error) {
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.lines++
+
+	switch {
+	case err != nil:
+		shard.parseErrors++
+	case logData != nil:
+		shard.add(logData)
+		ls.addEndpoint(shard, logData.Endpoint)
+	}
+}
+
+func (shard *liveShard) add(logData *domain.LogData) {
+	shard.requests++
+	shard.responseBytes += logData.ResponseSize
+	shard.statusCodes[statusLabel(logData.StatusCode)]++
+	shard.methods[methodLabel(logData.Method)]++
+	_ = shard.responseSizes.RecordValue(logData.ResponseSize)
+
+	if logData.HasRequestTime {
+		_ = shard.requestLatency.RecordValue(latencyValue(logData.RequestTime))
+	}
+
+	if logData.HasUpstreamTime {
+		_ = shard.upstreamLatency.RecordValue(latencyValue(logData.UpstreamResponseTime))
+	}
+}
+
+// metricMethods — методы с собственной меткой в метриках serve.
+var metricMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+}
+
+// methodLabel сводит метод из лога к метке метрики: значения из строки запроса не ограничены,
+// и каждое новое заводило бы свою серию.
+func methodLabel(method string) string {
+	if _, ok := metricMethods[method]; ok {
+		return method
+	}
+
+	return domain.OtherMethod
+}
+
+// statusLabel оставляет только коды вида 1xx–5xx: разбор пропускает и «0200» или «+200».
+func statusLabel(status string) string {
+	if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+		return domain.OtherStatus
+	}
+
+	for i := 1; i < len(status); i++ {
+		if status[i] < '0' || status[i] > '9' {
+			return domain.OtherStatus
+		}
+	}
+
+	return status
+}
+
+// addEndpoint учитывает запрос к шаблону. Общий набор шаблонов проверяется, только если шаблона
+// ещё нет среди счётчиков шарда.
+func (ls *LiveStats) addEndpoint(shard *liveShard, endpoint string) {
+	if endpoint == "" {
+		return
+	}
+
+	if _, ok := shard.endpoints[endpoint]; ok || ls.admitEndpoint(endpoint) {
+		shard.endpoints[endpoint]++
+		return
+	}
+
+	shard.otherEndpoints++
+}
+
+// admitEndpoint сообщает, есть ли у шаблона собственный счётчик, и заводит его, пока шаблонов меньше maxEndpoints.
+func (ls *LiveStats) admitEndpoint(endpoint string) bool {
+	ls.endpointsMu.RLock()
+	_, ok := ls.endpoints[endpoint]
+	full := len(ls.endpoints) >= ls.maxEndpoints
+	ls.endpointsMu.RUnlock()
+
+	if ok || full {
+		return ok
+	}
+
+	ls.endpointsMu.Lock()
+	defer ls.endpointsMu.Unlock()
+
+	if _, ok := ls.endpoints[endpoint]; ok {
+		return true
+	}
+
+	if len(ls.endpoints) >= ls.maxEndpoints {
+		return false
+	}
+
+	ls.endpoints[endpoint] = struct{}{}
+
+	return true
+}
+
+// Sample складывает счётчики шардов для экспорта метрик.
+func (ls *LiveStats) Sample() *domain.MetricsSample {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	total := newLiveShard()
+	for _, shard := range ls.shards {
+		shard.mergeInto(total)
+	}
+
+	sample := &domain.MetricsSample{
+		TotalLines:        total.lines,
+		ParseErrors:       total.parseErrors,
+		TotalRequests:     total.requests,
+		TotalResponseSize: total.responseBytes,
+		StatusCodes:       total.statusCodes,
+		Methods:           total.methods,
+		Endpoints:         total.endpoints,
+		ResponseSizes:     total.responseSizes,
+		RequestLatency:    total.requestLatency,
+		UpstreamLatency:   total.upstreamLatency,
+	}
+
+	if total.otherEndpoints > 0 {
+		sample.Endpoints[domain.OtherEndpoint] = total.otherEndpoints
+	}
+
+	return sample
+}
+
+// mergeInto добавляет счётчики шарда к total.
+func (shard *liveShard) mergeInto(total *liveShard) {
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	total.lines += shard.lines
+	total.parseErrors += shard.parseErrors
+	total.requests += shard.requests
+	total.responseBytes += shard.responseBytes
+	total.otherEndpoints += shard.otherEndpoints
+
+	mergeCounts(total.statusCodes, shard.statusCodes)
+	mergeCounts(total.methods, shard.methods)
+	mergeCounts(total.endpoints, shard.endpoints)
+
+	total.responseSizes.Merge(shard.responseSizes)
+	total.requestLatency.Merge(shard.requestLatency)
+	total.upstreamLatency.Merge(shard.upstreamLatency)
+}
+
+func mergeCounts(target, source map[string]int64) {
+	for key, count := range source {
+		target[key] += count
+	}
+}
@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/infrastructure/parser"
+	"github.com/4domm/ngxstat/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsService_Serve(t *testing.T) {
+	formatParser, err := parser.NewFormatParser(parser.CommonLogFormat + " $request_time")
+	require.NoError(t, err)
+
+	lines := records("access.log",
+		`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /users/1 HTTP/1.1" 200 100 0.010`,
+		`10.0.0.1 - - [10/Oct/2023:13:55:37 +0000] "GET /users/2?tab=1 HTTP/1.1" 200 300 0.020`,
+		`10.0.0.2 - - [10/Oct/2023:13:55:38 +0000] "POST /orders HTTP/1.1" 502 50 1.500`,
+		"garbage",
+	)
+
+	for _, approxTop := range []int{0, 10} {
+		stats := service.NewLiveStats(approxTop)
+		config := &domain.InputConfig{Workers: 2, ApproxTop: approxTop}
+
+		require.NoError(t, service.NewAnalyticsService(formatParser, lines).Serve(config, stats))
+
+		sample := stats.Sample()
+		assert.Equal(t, int64(4), sample.TotalLines)
+		assert.Equal(t, int64(1), sample.ParseErrors)
+		assert.Equal(t, int64(3), sample.TotalRequests)
+		assert.Equal(t, int64(450), sample.TotalResponseSize)
+		assert.Equal(t, map[string]int64{"200": 2, "502": 1}, sample.StatusCodes)
+		assert.Equal(t, map[string]int64{"GET": 2, "POST": 1}, sample.Methods)
+		assert.Equal(t, map[string]int64{"/users/{id}": 2, "/orders": 1}, sample.Endpoints)
+		assert.Equal(t, int64(3), sample.ResponseSizes.TotalCount())
+		assert.Equal(t, int64(3), sample.RequestLatency.TotalCount())
+		assert.Zero(t, sample.UpstreamLatency.TotalCount())
+	}
+}
+
+func TestLiveStats_EndpointLimit(t *testing.T) {
+	line := func(resource string) string {
+		return `10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET ` + resource + ` HTTP/1.1" 200 100`
+	}
+
+	stats := service.NewLiveStats(2)
+	config := &domain.InputConfig{Workers: 1, ApproxTop: 2}
+
+	// С одной горутиной шаблоны встречаются в порядке строк: собственные счётчики получают /a и /b.
+	lines := records("access.log", line("/a"), line("/b"), line("/c"), line("/a"))
+	require.NoError(t, service.NewAnalyticsService(parser.NginxParser{}, lines).Serve(config, stats))
+	assert.Equal(t, map[string]int64{"/a": 2, "/b": 1, domain.OtherEndpoint: 1}, stats.Sample().Endpoints)
+
+	// Новые частые шаблоны не вытесняют прежние: счётчики только растут.
+	lines = records("access.log", line("/c"), line("/c"), line("/d"), line("/b"))
+	require.NoError(t, service.NewAnalyticsService(parser.NginxParser{}, lines).Serve(config, stats))
+	assert.Equal(t, map[string]int64{"/a": 2, "/b": 2, domain.OtherEndpoint: 4}, stats.Sample().Endpoints)
+}
+
+func TestLiveStats_MethodAndStatusLabels(t *testing.T) {
+	// JSON-лог кладёт метод и код ответа в отдельные поля как есть, поэтому разбор пропускает
+	// и мусор сканеров, и код «0200».
+	line := func(method, status string) string {
+		return `{"time_local":"10/Oct/2023:13:55:36 +0000","remote_addr":"10.0.0.1","request_method":"` + method +
+			`","request_uri":"/","status":"` + status + `","body_bytes_sent":"100"}`
+	}
+
+	lines := records("access.log",
+		line("GET", "200"),
+		line(`\u0016\u0003\u0001`, "400"),
+		line("FOO", "0200"),
+		line("POST", "+404"),
+	)
+
+	jsonParser := parser.NewJSONParser(parser.DefaultJSONFieldMapping())
+	stats := service.NewLiveStats(0)
+	require.NoError(t, service.NewAnalyticsService(jsonParser, lines).Serve(&domain.InputConfig{Workers: 1}, stats))
+
+	sample := stats.Sample()
+	assert.Equal(t, int64(4), sample.TotalRequests)
+	assert.Equal(t, map[string]int64{"GET": 1, "POST": 1, domain.OtherMethod: 2}, sample.Methods)
+	assert.Equal(t, map[string]int64{"200": 1, "400": 1, domain.OtherStatus: 2}, sample.StatusCodes)
+}
+
+func TestLiveStats_SampleWhileServing(t *testing.T) {
+	var lines []string
+	for i := 0; i < 2000; i++ {
+		lines = append(lines, fmt.Sprintf(`10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /items/%d HTTP/1.1" 200 100`, i))
+	}
+
+	stats := service.NewLiveStats(0)
+	done := make(chan error)
+
+	go func() {
+		done <- service.NewAnalyticsService(parser.NginxParser{}, records("access.log", lines...)).Serve(&domain.InputConfig{Workers: 4}, stats)
+	}()
+
+	// Шарды складываются при каждом запросе метрик: счётчики между запросами только растут.
+	var previous int64
+
+	for serving := true; serving; {
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+
+			serving = false
+		default:
+		}
+
+		sample := stats.Sample()
+		assert.GreaterOrEqual(t, sample.TotalRequests, previous)
+		assert.Equal(t, sample.TotalRequests, sample.Endpoints["/items/{id}"])
+
+		previous = sample.TotalRequests
+	}
+
+	assert.Equal(t, int64(2000), previous)
+}
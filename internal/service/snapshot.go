@@ -0,0 +1,338 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/sketch"
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// SnapshotMagic открывает файл снимка, за ним следует байт версии и состояние в gob, сжатое gzip.
+	SnapshotMagic = "NGXSTAT-SNAPSHOT"
+	// SnapshotVersion увеличивается при любом изменении snapshotState: gob молча пропускает поля, которых
+	// нет в файле, и снимок другой версии дал бы неполную статистику вместо ошибки.
+	// 2 — клиенты, шаблоны путей, пути и параметры запроса.
+	SnapshotVersion = 2
+)
+
+// Snapshot — накопленное до подсчёта топов состояние одного или нескольких запусков.
+// From и To — границы анализа, нулевые, если не заданы.
+type Snapshot struct {
+	From        time.Time
+	To          time.Time
+	Accumulator *Accumulator
+}
+
+// snapshotState — сериализуемая форма накопителя. Поля переносятся явно, а не через AnalysisResult:
+// gob не передаёт пустые словари, а накопитель рассчитывает на созданные словари результата.
+type snapshotState struct {
+	From, To          time.Time
+	Filenames         []string
+	TotalRequests     int64
+	TotalResponseSize int64
+	TotalServerErrors int64
+	TotalLines        int64
+	Resources         map[string]int64
+	StatusCodes       map[string]int64
+	Referrers         map[string]int64
+	Methods           map[string]int64
+	Endpoints         map[string]int64
+	Paths             map[string]int64
+	QueryParams       map[string]int64
+	QueryValues       map[string]map[string]int64
+	FileFormats       map[string]map[string]int64
+	ParseErrors       domain.ParseErrors
+	Histogram         []byte
+	RequestLatency    []byte
+	UpstreamLatency   []byte
+	ResourceLatencies map[string][]byte
+	TimeBuckets       []domain.TimeBucket
+	VisitorIPs        *sketch.HyperLogLog
+	VisitorIPAgents   *sketch.HyperLogLog
+	VisitorUsers      *sketch.HyperLogLog
+	Clients           map[string]*domain.ClientStats
+	AgentClasses      domain.AgentClasses
+	ApproxTop         int
+	// Top* заданы только при ApproxTop > 0.
+	TopResources   *sketch.TopK
+	TopReferrers   *sketch.TopK
+	TopClients     *sketch.TopK
+	TopEndpoints   *sketch.TopK
+	TopPaths       *sketch.TopK
+	TopQueryParams *sketch.TopK
+	TopQueryValues *sketch.TopK
+}
+
+// WriteSnapshot сохраняет снимок в w.
+func WriteSnapshot(w io.Writer, snapshot *Snapshot) error {
+	state, err := newSnapshotState(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, SnapshotMagic); err != nil {
+		return err
+	}
+
+	if _, err := w.Write([]byte{SnapshotVersion}); err != nil {
+		return err
+	}
+
+	compressed := gzip.NewWriter(w)
+
+	if err := gob.NewEncoder(compressed).Encode(state); err != nil {
+		return err
+	}
+
+	return compressed.Close()
+}
+
+// ReadSnapshot читает снимок, записанный WriteSnapshot.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, len(SnapshotMagic)+1)
+
+	if _, err := io.ReadFull(reader, header); err != nil || string(header[:len(SnapshotMagic)]) != SnapshotMagic {
+		return nil, domain.ErrSnapshotFormat
+	}
+
+	if version := header[len(SnapshotMagic)]; version != SnapshotVersion {
+		return nil, &domain.SnapshotVersionError{Version: int(version), Supported: SnapshotVersion}
+	}
+
+	compressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrSnapshotFormat, err)
+	}
+
+	var state snapshotState
+	if err := gob.NewDecoder(compressed).Decode(&state); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrSnapshotFormat, err)
+	}
+
+	accumulator, err := state.accumulator()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrSnapshotFormat, err)
+	}
+
+	return &Snapshot{From: state.From, To: state.To, Accumulator: accumulator}, nil
+}
+
+// MergeSnapshots объединяет снимки в первый из них. Границы анализа расширяются до охвата всех
+// снимков; если хотя бы у одного граница не задана, она не задана и у результата.
+func MergeSnapshots(snapshots []*Snapshot) (*Snapshot, error) {
+	if len(snapshots) == 0 {
+		return nil, domain.ErrNoSnapshots
+	}
+
+	merged := snapshots[0]
+
+	for _, snapshot := range snapshots[1:] {
+		if snapshot.Accumulator.approxTop() != merged.Accumulator.approxTop() {
+			return nil, &domain.SnapshotMismatchError{
+				ApproxTop:      merged.Accumulator.approxTop(),
+				OtherApproxTop: snapshot.Accumulator.approxTop(),
+			}
+		}
+
+		merged.Accumulator.Merge(snapshot.Accumulator)
+		merged.From = widerBound(merged.From, snapshot.From, snapshot.From.Before)
+		merged.To = widerBound(merged.To, snapshot.To, snapshot.To.After)
+	}
+
+	slices.Sort(merged.Accumulator.AnalysisResult.Filenames)
+
+	return merged, nil
+}
+
+// widerBound возвращает более широкую из границ; нулевая граница означает отсутствие ограничения.
+func widerBound(current, other time.Time, wider func(time.Time) bool) time.Time {
+	if current.IsZero() || other.IsZero() {
+		return time.Time{}
+	}
+
+	if wider(current) {
+		return other
+	}
+
+	return current
+}
+
+func newSnapshotState(snapshot *Snapshot) (*snapshotState, error) {
+	a := snapshot.Accumulator
+	result := a.AnalysisResult
+	state := &snapshotState{
+		From:              snapshot.From,
+		To:                snapshot.To,
+		Filenames:         result.Filenames,
+		TotalRequests:     result.TotalRequests,
+		TotalResponseSize: result.TotalResponseSize,
+		TotalServerErrors: result.TotalServerErrorsLogs,
+		TotalLines:        result.TotalLines,
+		Resources:         result.MostRequestedResources,
+		StatusCodes:       result.MostFrequentStatusCodes,
+		Referrers:         result.MostFrequentReferrers,
+		Methods:           result.RequestMethods,
+		Endpoints:         result.MostRequestedEndpoints,
+		Paths:             result.MostRequestedPaths,
+		QueryParams:       result.MostFrequentQueryParams,
+		QueryValues:       result.QueryParamValues,
+		FileFormats:       result.FileFormats,
+		ParseErrors:       result.ParseErrors,
+		TimeBuckets:       make([]domain.TimeBucket, 0, len(a.timeBuckets)),
+		VisitorIPs:        a.visitorIPs,
+		VisitorIPAgents:   a.visitorIPUserAgents,
+		VisitorUsers:      a.visitorRemoteUsers,
+		Clients:           a.clients,
+		AgentClasses:      result.AgentClasses,
+		ApproxTop:         a.approxTop(),
+		TopResources:      a.topResources,
+		TopReferrers:      a.topReferrers,
+		TopClients:        a.topClients,
+		TopEndpoints:      a.topEndpoints,
+		TopPaths:          a.topPaths,
+		TopQueryParams:    a.topQueryParams,
+		TopQueryValues:    a.topQueryValues,
+	}
+
+	for _, bucket := range a.timeBuckets {
+		state.TimeBuckets = append(state.TimeBuckets, *bucket)
+	}
+
+	if err := state.encodeHistograms(a); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (s *snapshotState) encodeHistograms(a *Accumulator) error {
+	var err error
+
+	if s.Histogram, err = encodeHistogram(a.Histogram); err != nil {
+		return err
+	}
+
+	if s.RequestLatency, err = encodeHistogram(a.LatencyHistogram); err != nil {
+		return err
+	}
+
+	if s.UpstreamLatency, err = encodeHistogram(a.UpstreamLatencyHistogram); err != nil {
+		return err
+	}
+
+	s.ResourceLatencies = make(map[string][]byte, len(a.resourceLatencies))
+
+	for resource, histogram := range a.resourceLatencies {
+		if s.ResourceLatencies[resource], err = encodeHistogram(histogram); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// accumulator восстанавливает накопитель, сливая сохранённое состояние в новый пустой.
+func (s *snapshotState) accumulator() (*Accumulator, error) {
+	a := NewApproxAccumulator(s.ApproxTop)
+	a.AnalysisResult.Merge(&domain.AnalysisResult{
+		Filenames:               s.Filenames,
+		TotalRequests:           s.TotalRequests,
+		TotalResponseSize:       s.TotalResponseSize,
+		TotalServerErrorsLogs:   s.TotalServerErrors,
+		TotalLines:              s.TotalLines,
+		MostRequestedResources:  s.Resources,
+		MostFrequentStatusCodes: s.StatusCodes,
+		MostFrequentReferrers:   s.Referrers,
+		RequestMethods:          s.Methods,
+		MostRequestedEndpoints:  s.Endpoints,
+		MostRequestedPaths:      s.Paths,
+		MostFrequentQueryParams: s.QueryParams,
+		QueryParamValues:        s.QueryValues,
+		FileFormats:             s.FileFormats,
+		ParseErrors:             s.ParseErrors,
+		AgentClasses:            s.AgentClasses,
+	})
+
+	for _, filename := range s.Filenames {
+		a.filesUsed[filename] = struct{}{}
+	}
+
+	for i := range s.TimeBuckets {
+		a.timeBucket(s.TimeBuckets[i].Start).Merge(&s.TimeBuckets[i])
+	}
+
+	for ip, stats := range s.Clients {
+		a.client(ip).Merge(stats)
+	}
+
+	if err := s.decodeHistograms(a); err != nil {
+		return nil, err
+	}
+
+	if err := s.restoreSketches(a); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (s *snapshotState) decodeHistograms(a *Accumulator) error {
+	var err error
+
+	if a.Histogram, err = hdrhistogram.Decode(s.Histogram); err != nil {
+		return err
+	}
+
+	if a.LatencyHistogram, err = hdrhistogram.Decode(s.RequestLatency); err != nil {
+		return err
+	}
+
+	if a.UpstreamLatencyHistogram, err = hdrhistogram.Decode(s.UpstreamLatency); err != nil {
+		return err
+	}
+
+	for resource, data := range s.ResourceLatencies {
+		if a.resourceLatencies[resource], err = hdrhistogram.Decode(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreSketches переносит скетчи уникальных клиентов и приближённых топов; снимок без любого из них
+// повреждён.
+func (s *snapshotState) restoreSketches(a *Accumulator) error {
+	if s.VisitorIPs == nil || s.VisitorIPAgents == nil || s.VisitorUsers == nil {
+		return domain.ErrSnapshotFormat
+	}
+
+	a.visitorIPs, a.visitorIPUserAgents, a.visitorRemoteUsers = s.VisitorIPs, s.VisitorIPAgents, s.VisitorUsers
+
+	if s.ApproxTop <= 0 {
+		return nil
+	}
+
+	tops := []*sketch.TopK{s.TopResources, s.TopReferrers, s.TopClients, s.TopEndpoints, s.TopPaths, s.TopQueryParams, s.TopQueryValues}
+	if slices.Contains(tops, nil) {
+		return domain.ErrSnapshotFormat
+	}
+
+	a.topResources, a.topReferrers, a.topClients = s.TopResources, s.TopReferrers, s.TopClients
+	a.topEndpoints, a.topPaths, a.topQueryParams, a.topQueryValues = s.TopEndpoints, s.TopPaths, s.TopQueryParams, s.TopQueryValues
+
+	return nil
+}
+
+func encodeHistogram(histogram *hdrhistogram.Histogram) ([]byte, error) {
+	return histogram.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+}
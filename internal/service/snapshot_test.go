@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roundTrip(t *testing.T, snapshot *service.Snapshot) *service.Snapshot {
+	t.Helper()
+
+	var buffer bytes.Buffer
+
+	require.NoError(t, service.WriteSnapshot(&buffer, snapshot))
+
+	restored, err := service.ReadSnapshot(&buffer)
+	require.NoError(t, err)
+
+	return restored
+}
+
+func TestSnapshot_MergeMatchesSingleRun(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	entries := []*domain.LogData{
+		{Filename: "b.log", Timestamp: start, IPAddress: "10.0.0.1", Resource: "/a", StatusCode: "200", ResponseSize: 100,
+			RequestTime: 100 * time.Millisecond, HasRequestTime: true},
+		{Filename: "b.log", Timestamp: start.Add(time.Minute), IPAddress: "10.0.0.2", Resource: "/b", StatusCode: "502",
+			ResponseSize: 300, Referer: "ref"},
+		{Filename: "a.log", Timestamp: start.Add(2 * time.Minute), IPAddress: "10.0.0.1", Resource: "/a", StatusCode: "404",
+			ResponseSize: 200, RequestTime: 300 * time.Millisecond, HasRequestTime: true, Format: "combined"},
+	}
+
+	single, first, second := service.NewAccumulator(), service.NewAccumulator(), service.NewAccumulator()
+
+	for i, entry := range entries {
+		single.Add(entry)
+
+		if i < 2 {
+			first.Add(entry)
+		} else {
+			second.Add(entry)
+		}
+	}
+
+	merged, err := service.MergeSnapshots([]*service.Snapshot{
+		roundTrip(t, &service.Snapshot{From: start, To: start.Add(time.Hour), Accumulator: first}),
+		roundTrip(t, &service.Snapshot{From: start.Add(-time.Hour), To: start.Add(30 * time.Minute), Accumulator: second}),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, start.Add(-time.Hour), merged.From)
+	assert.Equal(t, start.Add(time.Hour), merged.To)
+
+	expected := single.Process(service.TopN, merged.From, merged.To, time.Minute)
+	// Как и AnalyticsService, MergeSnapshots упорядочивает имена файлов.
+	slices.Sort(expected.Filenames)
+	actual := merged.Accumulator.Process(service.TopN, merged.From, merged.To, time.Minute)
+
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, []string{"a.log", "b.log"}, actual.Filenames)
+	assert.Equal(t, int64(3), actual.TotalRequests)
+	assert.Len(t, actual.TimeSeries.Buckets, 3)
+}
+
+func TestSnapshot_ApproxTop(t *testing.T) {
+	accumulator := service.NewApproxAccumulator(2)
+
+	for _, resource := range []string{"/a", "/a", "/b", "/c", "/a"} {
+		accumulator.Add(&domain.LogData{Resource: resource, StatusCode: "200"})
+	}
+
+	restored := roundTrip(t, &service.Snapshot{Accumulator: accumulator})
+	result := restored.Accumulator.Process(service.TopN, time.Time{}, time.Time{}, 0)
+
+	require.NotNil(t, result.ApproxTop)
+	assert.Equal(t, 2, result.ApproxTop.Capacity)
+	assert.Equal(t, int64(3), result.MostRequestedResources["/a"])
+
+	_, err := service.MergeSnapshots([]*service.Snapshot{restored, {Accumulator: service.NewAccumulator()}})
+
+	var mismatch *domain.SnapshotMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, 2, mismatch.ApproxTop)
+	assert.Equal(t, 0, mismatch.OtherApproxTop)
+}
+
+func TestReadSnapshot_Errors(t *testing.T) {
+	var buffer bytes.Buffer
+
+	require.NoError(t, service.WriteSnapshot(&buffer, &service.Snapshot{Accumulator: service.NewAccumulator()}))
+	data := buffer.Bytes()
+
+	_, err := service.ReadSnapshot(bytes.NewReader([]byte("not a snapshot at all")))
+	assert.ErrorIs(t, err, domain.ErrSnapshotFormat)
+
+	_, err = service.ReadSnapshot(bytes.NewReader(data[:len(data)/2]))
+	assert.ErrorIs(t, err, domain.ErrSnapshotFormat)
+
+	future := append([]byte(nil), data...)
+	future[len(service.SnapshotMagic)] = service.SnapshotVersion + 1
+
+	_, err = service.ReadSnapshot(bytes.NewReader(future))
+
+	var versionErr *domain.SnapshotVersionError
+	require.ErrorAs(t, err, &versionErr)
+	assert.Equal(t, service.SnapshotVersion+1, versionErr.Version)
+
+	// Снимок первой версии не содержит клиентов и шаблонов путей и не должен читаться как неполный.
+	old := append([]byte(nil), data...)
+	old[len(service.SnapshotMagic)] = 1
+
+	_, err = service.ReadSnapshot(bytes.NewReader(old))
+	require.ErrorAs(t, err, &versionErr)
+	assert.Equal(t, 1, versionErr.Version)
+
+	_, err = service.MergeSnapshots(nil)
+	assert.ErrorIs(t, err, domain.ErrNoSnapshots)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const (
+	windowSlots   = 60
+	minWindowSlot = time.Second
+	// minClosedSlotTop — наименьшая длина приближённых топов закрытого интервала.
+	minClosedSlotTop = 16
+)
+
+// slidingWindow раскладывает записи по интервалам времени лога, чтобы статистику за последние
+// size можно было собрать слиянием интервалов, не храня сами записи. С --approx-top полные скетчи
+// ёмкости approxTop есть только у нового интервала: при открытии следующего его топы усекаются
+// до closedSlotTop значений, и всё окно занимает память примерно на 2·approxTop значений каждого топа.
+type slidingWindow struct {
+	size      time.Duration
+	slot      time.Duration
+	slots     map[int64]*Accumulator
+	approxTop int
+	// newest — самое позднее время записи. Интервалы, закончившиеся раньше newest-size, уже не попадут
+	// в окно, поэтому при чтении накопившихся логов окно держит не больше windowSlots+1 интервалов.
+	newest time.Time
+}
+
+// newSlidingWindow создаёт окно размера size; approxTop — ёмкость приближённых топов (0 — точные).
+func newSlidingWindow(size time.Duration, approxTop int) *slidingWindow {
+	return &slidingWindow{
+		size:      size,
+		slot:      max(size/windowSlots, minWindowSlot),
+		slots:     make(map[int64]*Accumulator),
+		approxTop: approxTop,
+	}
+}
+
+// add учитывает запись; записи старше newest-size отбрасываются.
+func (w *slidingWindow) add(logData *domain.LogData) {
+	if logData.Timestamp.After(w.newest) {
+		w.newest = logData.Timestamp
+	}
+
+	start := logData.Timestamp.Truncate(w.slot)
+	cutoff := w.newest.Add(-w.size)
+
+	if w.expired(start, cutoff) {
+		return
+	}
+
+	slot, ok := w.slots[start.Unix()]
+	if !ok {
+		w.evict(cutoff)
+		w.closeSlots()
+
+		slot = NewApproxAccumulator(w.approxTop)
+		w.slots[start.Unix()] = slot
+	}
+
+	slot.Add(logData)
+}
+
+// expired сообщает, закончился ли интервал с началом start к моменту cutoff.
+func (w *slidingWindow) expired(start, cutoff time.Time) bool {
+	return !start.Add(w.slot).After(cutoff)
+}
+
+// closeSlots усекает приближённые топы уже открытых интервалов: записи в них ещё могут прийти,
+// но основная часть интервала уже посчитана.
+func (w *slidingWindow) closeSlots() {
+	for _, slot := range w.slots {
+		slot.truncateTops(w.closedSlotTop())
+	}
+}
+
+// closedSlotTop — длина топов закрытого интервала: вместе закрытые интервалы хранят около approxTop
+// значений каждого топа.
+func (w *slidingWindow) closedSlotTop() int {
+	return max(w.approxTop/windowSlots, minClosedSlotTop)
+}
+
+// evict удаляет интервалы, закончившиеся к моменту cutoff.
+func (w *slidingWindow) evict(cutoff time.Time) {
+	for key := range w.slots {
+		if w.expired(time.Unix(key, 0), cutoff) {
+			delete(w.slots, key)
+		}
+	}
+}
+
+// end — конец окна: время самой поздней записи. Окно отсчитывается по часам лога, а не хоста, чтобы
+// накопившиеся старые строки и расхождение часов nginx и хоста не давали пустой отчёт. Пока записей
+// нет, концом считается now.
+func (w *slidingWindow) end(now time.Time) time.Time {
+	if w.newest.IsZero() {
+		return now
+	}
+
+	return w.newest
+}
+
+// merge удаляет интервалы старше newest-size и объединяет оставшиеся в новый накопитель.
+func (w *slidingWindow) merge() *Accumulator {
+	w.evict(w.newest.Add(-w.size))
+
+	keys := make([]int64, 0, len(w.slots))
+	for key := range w.slots {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	merged := NewApproxAccumulator(w.approxTop)
+	for _, key := range keys {
+		merged.Merge(w.slots[key])
+	}
+
+	return merged
+}
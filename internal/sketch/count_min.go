@@ -0,0 +1,61 @@
+package sketch
+
+import "errors"
+
+var ErrShapeMismatch = errors.New("sketch: Count-Min разного размера нельзя объединить")
+
+// CountMin оценивает частоты значений сверху (Cormode, Muthukrishnan, 2005): оценка никогда
+// не меньше точного числа и с вероятностью не меньше 1-e^-depth превышает его не больше чем на e·N/width.
+type CountMin struct {
+	width, depth int
+	table        []int64
+}
+
+// NewCountMin создаёт таблицу depth×width; размеры меньше единицы заменяются единицей.
+func NewCountMin(width, depth int) *CountMin {
+	width, depth = max(width, 1), max(depth, 1)
+
+	return &CountMin{width: width, depth: depth, table: make([]int64, width*depth)}
+}
+
+// Add учитывает weight вхождений key.
+func (c *CountMin) Add(key string, weight int64) {
+	hash := hashString(key)
+
+	for row := 0; row < c.depth; row++ {
+		c.table[c.cell(row, hash)] += weight
+	}
+}
+
+// Estimate возвращает оценку числа вхождений key сверху.
+func (c *CountMin) Estimate(key string) int64 {
+	hash := hashString(key)
+	estimate := c.table[c.cell(0, hash)]
+
+	for row := 1; row < c.depth; row++ {
+		estimate = min(estimate, c.table[c.cell(row, hash)])
+	}
+
+	return estimate
+}
+
+// Merge добавляет к таблице счётчики other того же размера.
+func (c *CountMin) Merge(other *CountMin) error {
+	if c.width != other.width || c.depth != other.depth {
+		return ErrShapeMismatch
+	}
+
+	for i, value := range other.table {
+		c.table[i] += value
+	}
+
+	return nil
+}
+
+// cell выбирает ячейку строки row двойным хэшированием (Kirsch, Mitzenmacher): хэши строк
+// получаются из двух половин одного 64-битного хэша.
+func (c *CountMin) cell(row int, hash uint64) int {
+	first, second := hash>>32, hash&0xffffffff|1
+
+	return row*c.width + int((first+uint64(row)*second)%uint64(c.width))
+}
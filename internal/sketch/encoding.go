@@ -0,0 +1,208 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	spaceSavingVersion = 1
+	countMinVersion    = 1
+	topKVersion        = 1
+)
+
+var (
+	errCorrupted = errors.New("sketch: повреждённые данные")
+	errTruncated = errors.New("sketch: усечённый TopK не кодируется")
+)
+
+// decoder читает varint-поля, запоминая первую ошибку: проверка нужна только в конце разбора.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	value, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+
+	d.data = d.data[n:]
+
+	return value
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	value, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+
+	d.data = d.data[n:]
+
+	return value
+}
+
+func (d *decoder) bytes() []byte {
+	size := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+
+	if size > uint64(len(d.data)) {
+		d.err = errCorrupted
+		return nil
+	}
+
+	value := d.data[:size]
+	d.data = d.data[size:]
+
+	return value
+}
+
+// finish возвращает ошибку разбора; лишние байты в конце тоже считаются повреждением.
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) > 0 {
+		return errCorrupted
+	}
+
+	return d.err
+}
+
+// newDecoder проверяет байт версии формата.
+func newDecoder(data []byte, version byte) *decoder {
+	if len(data) == 0 || data[0] != version {
+		return &decoder{err: errors.New("sketch: неизвестная версия формата")}
+	}
+
+	return &decoder{data: data[1:]}
+}
+
+// MarshalBinary кодирует сводку: версия формата, ёмкость и счётчики.
+func (s *SpaceSaving) MarshalBinary() ([]byte, error) {
+	data := []byte{spaceSavingVersion}
+	data = binary.AppendUvarint(data, uint64(s.capacity))
+	data = binary.AppendUvarint(data, uint64(len(s.counters)))
+
+	for _, counter := range s.counters {
+		data = binary.AppendUvarint(data, uint64(len(counter.Key)))
+		data = append(data, counter.Key...)
+		data = binary.AppendVarint(data, counter.Count)
+		data = binary.AppendVarint(data, counter.Error)
+	}
+
+	return data, nil
+}
+
+func (s *SpaceSaving) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, spaceSavingVersion)
+	capacity, size := d.uvarint(), d.uvarint()
+
+	if d.err == nil && (capacity == 0 || size > capacity || size > uint64(len(d.data))) {
+		return errCorrupted
+	}
+
+	counters := make([]Counter, 0, size)
+	for i := uint64(0); i < size && d.err == nil; i++ {
+		counters = append(counters, Counter{Key: string(d.bytes()), Count: d.varint(), Error: d.varint()})
+	}
+
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*s = *NewSpaceSaving(int(capacity))
+	for _, counter := range counters {
+		s.counters = append(s.counters, counter)
+		s.index[counter.Key] = len(s.counters) - 1
+		s.up(len(s.counters) - 1)
+	}
+
+	return nil
+}
+
+// MarshalBinary кодирует таблицу: версия формата, размеры и счётчики построчно.
+func (c *CountMin) MarshalBinary() ([]byte, error) {
+	data := []byte{countMinVersion}
+	data = binary.AppendUvarint(data, uint64(c.width))
+	data = binary.AppendUvarint(data, uint64(c.depth))
+
+	for _, value := range c.table {
+		data = binary.AppendVarint(data, value)
+	}
+
+	return data, nil
+}
+
+func (c *CountMin) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, countMinVersion)
+	width, depth := d.uvarint(), d.uvarint()
+
+	// Каждый счётчик занимает хотя бы байт, это ограничивает размер таблицы до выделения памяти.
+	if d.err == nil && (width == 0 || depth == 0 || width*depth > uint64(len(d.data))) {
+		return errCorrupted
+	}
+
+	table := make([]int64, width*depth)
+	for i := range table {
+		table[i] = d.varint()
+	}
+
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*c = CountMin{width: int(width), depth: int(depth), table: table}
+
+	return nil
+}
+
+// MarshalBinary кодирует TopK: версия формата, затем сводка SpaceSaving и таблица Count-Min.
+func (t *TopK) MarshalBinary() ([]byte, error) {
+	if t.counts == nil {
+		return nil, errTruncated
+	}
+
+	summary, _ := t.summary.MarshalBinary()
+	counts, _ := t.counts.MarshalBinary()
+
+	data := []byte{topKVersion}
+	data = binary.AppendUvarint(data, uint64(len(summary)))
+	data = append(data, summary...)
+	data = binary.AppendUvarint(data, uint64(len(counts)))
+
+	return append(data, counts...), nil
+}
+
+func (t *TopK) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, topKVersion)
+	summaryData, countsData := d.bytes(), d.bytes()
+
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	summary, counts := &SpaceSaving{}, &CountMin{}
+	if err := summary.UnmarshalBinary(summaryData); err != nil {
+		return err
+	}
+
+	if err := counts.UnmarshalBinary(countsData); err != nil {
+		return err
+	}
+
+	t.summary, t.counts = summary, counts
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package sketch_test
+
+import (
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopK_MarshalBinary(t *testing.T) {
+	stream, _ := zipfStream(6, 20_000)
+	topK := sketch.NewTopK(50)
+
+	for _, key := range stream {
+		topK.Add(key)
+	}
+
+	data, err := topK.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := &sketch.TopK{}
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, topK.Top(50), restored.Top(50))
+	assert.Equal(t, topK.Capacity(), restored.Capacity())
+
+	// Восстановленный TopK продолжает считать так же, как исходный.
+	topK.Add("/page/0")
+	restored.Add("/page/0")
+	assert.Equal(t, topK.Top(1), restored.Top(1))
+
+	for _, corrupted := range [][]byte{nil, {99}, data[:len(data)/2], append(append([]byte(nil), data...), 0)} {
+		assert.Error(t, (&sketch.TopK{}).UnmarshalBinary(corrupted))
+	}
+}
+
+func TestCountMin_MarshalBinary(t *testing.T) {
+	counts := sketch.NewCountMin(64, sketch.CountMinDepth)
+	counts.Add("/a", 3)
+	counts.Add("/b", 1)
+
+	data, err := counts.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := &sketch.CountMin{}
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, counts.Estimate("/a"), restored.Estimate("/a"))
+	assert.NoError(t, restored.Merge(counts))
+	assert.Error(t, (&sketch.CountMin{}).UnmarshalBinary(data[:len(data)-1]))
+}
@@ -0,0 +1,69 @@
+package sketch_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperLogLog_Count(t *testing.T) {
+	for _, distinct := range []int{0, 1, 100, 10_000, 1_000_000} {
+		t.Run(strconv.Itoa(distinct), func(t *testing.T) {
+			hll := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+
+			for i := 0; i < distinct; i++ {
+				hll.AddString("10.0." + strconv.Itoa(i))
+				hll.AddString("10.0." + strconv.Itoa(i))
+			}
+
+			// Три стандартные ошибки — оценка выходит за них с вероятностью меньше 0,3%.
+			assert.InDelta(t, distinct, hll.Count(), 3*hll.RelativeError()*float64(distinct)+1)
+		})
+	}
+}
+
+func TestHyperLogLog_AddPair(t *testing.T) {
+	hll := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+
+	hll.AddPair("10.0.0.1", "curl")
+	hll.AddPair("10.0.0.1", "curl")
+	hll.AddPair("10.0.0.1", "wget")
+	hll.AddPair("10.0.0.1c", "url")
+
+	assert.Equal(t, uint64(3), hll.Count())
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	first, second := sketch.NewHyperLogLog(12), sketch.NewHyperLogLog(12)
+
+	for i := 0; i < 30_000; i++ {
+		first.AddString(strconv.Itoa(i))
+		second.AddString(strconv.Itoa(i + 20_000))
+	}
+
+	require.NoError(t, first.Merge(second))
+	assert.InDelta(t, 50_000, first.Count(), 3*first.RelativeError()*50_000)
+
+	assert.ErrorIs(t, first.Merge(sketch.NewHyperLogLog(10)), sketch.ErrPrecisionMismatch)
+}
+
+func TestHyperLogLog_MarshalBinary(t *testing.T) {
+	hll := sketch.NewHyperLogLog(10)
+	for i := 0; i < 5000; i++ {
+		hll.AddString(strconv.Itoa(i))
+	}
+
+	data, err := hll.MarshalBinary()
+	require.NoError(t, err)
+
+	var restored sketch.HyperLogLog
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, hll.Count(), restored.Count())
+	assert.InDelta(t, hll.RelativeError(), restored.RelativeError(), 1e-12)
+
+	assert.Error(t, restored.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, restored.UnmarshalBinary([]byte{0}))
+}
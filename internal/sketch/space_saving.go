@@ -0,0 +1,203 @@
+package sketch
+
+import (
+	"slices"
+	"sort"
+)
+
+// Counter — счётчик значения в SpaceSaving: точное число вхождений лежит в [Count-Error, Count].
+type Counter struct {
+	Key   string
+	Count int64
+	Error int64
+}
+
+// SpaceSaving отслеживает не больше capacity самых частых значений (Metwally и др., 2005).
+// Значение, встречавшееся больше N/capacity раз из N, гарантированно остаётся в сводке,
+// а погрешность любого счётчика не превышает N/capacity.
+type SpaceSaving struct {
+	capacity int
+	// counters — min-куча по Count, index — позиция значения в куче.
+	counters []Counter
+	index    map[string]int
+}
+
+// NewSpaceSaving создаёт пустую сводку; capacity меньше единицы заменяется единицей.
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	capacity = max(capacity, 1)
+
+	return &SpaceSaving{
+		capacity: capacity,
+		counters: make([]Counter, 0, capacity),
+		index:    make(map[string]int, capacity),
+	}
+}
+
+// Add учитывает weight вхождений key. Если для key пришлось вытеснить самый редкий счётчик,
+// возвращается вытесненное значение и true.
+func (s *SpaceSaving) Add(key string, weight int64) (string, bool) {
+	if i, ok := s.index[key]; ok {
+		s.counters[i].Count += weight
+		s.down(i)
+
+		return "", false
+	}
+
+	if len(s.counters) < s.capacity {
+		s.counters = append(s.counters, Counter{Key: key, Count: weight})
+		s.index[key] = len(s.counters) - 1
+		s.up(len(s.counters) - 1)
+
+		return "", false
+	}
+
+	// Новое значение наследует счётчик вытесненного: оно могло встречаться до этого не больше min раз.
+	evicted := s.counters[0]
+	delete(s.index, evicted.Key)
+
+	s.counters[0] = Counter{Key: key, Count: evicted.Count + weight, Error: evicted.Count}
+	s.index[key] = 0
+	s.down(0)
+
+	return evicted.Key, true
+}
+
+// Contains сообщает, отслеживается ли key.
+func (s *SpaceSaving) Contains(key string) bool {
+	_, ok := s.index[key]
+	return ok
+}
+
+// Capacity возвращает наибольшее число отслеживаемых значений.
+func (s *SpaceSaving) Capacity() int {
+	return s.capacity
+}
+
+// Counters возвращает копию всех счётчиков по убыванию Count, при равенстве — по Key.
+func (s *SpaceSaving) Counters() []Counter {
+	counters := append([]Counter(nil), s.counters...)
+	sortCounters(counters)
+
+	return counters
+}
+
+// Merge объединяет сводки по схеме Agarwal и др. ("Mergeable summaries", 2012): значение,
+// которого нет в заполненной сводке, могло встретиться в ней не больше её минимального счётчика.
+// Ёмкость результата — ёмкость s.
+func (s *SpaceSaving) Merge(other *SpaceSaving) {
+	floor, otherFloor := s.floor(), other.floor()
+	merged := make(map[string]Counter, len(s.counters)+len(other.counters))
+
+	for _, counter := range s.counters {
+		merged[counter.Key] = Counter{Key: counter.Key, Count: counter.Count + otherFloor, Error: counter.Error + otherFloor}
+	}
+
+	for _, counter := range other.counters {
+		current, ok := merged[counter.Key]
+		if !ok {
+			current = Counter{Key: counter.Key, Count: floor, Error: floor}
+		} else {
+			current.Count -= otherFloor
+			current.Error -= otherFloor
+		}
+
+		current.Count += counter.Count
+		current.Error += counter.Error
+		merged[counter.Key] = current
+	}
+
+	counters := make([]Counter, 0, len(merged))
+	for _, counter := range merged {
+		counters = append(counters, counter)
+	}
+
+	sortCounters(counters)
+
+	// Массив по возрастанию Count уже является min-кучей.
+	s.counters = counters[:min(len(counters), s.capacity)]
+	slices.Reverse(s.counters)
+	clear(s.index)
+
+	for i, counter := range s.counters {
+		s.index[counter.Key] = i
+	}
+}
+
+// Truncate оставляет n самых частых счётчиков и уменьшает ёмкость до n. Отброшенные значения
+// встречались не чаще оставшихся, поэтому границы счётчиков и floor остаются верными.
+func (s *SpaceSaving) Truncate(n int) {
+	n = max(n, 1)
+	if n >= s.capacity {
+		return
+	}
+
+	counters := s.Counters()
+
+	// Массив по возрастанию Count уже является min-кучей.
+	s.counters = counters[:min(len(counters), n)]
+	slices.Reverse(s.counters)
+	s.capacity = n
+	s.index = make(map[string]int, n)
+
+	for i, counter := range s.counters {
+		s.index[counter.Key] = i
+	}
+}
+
+// floor — верхняя граница числа вхождений значения, которого нет в сводке.
+func (s *SpaceSaving) floor() int64 {
+	if len(s.counters) < s.capacity {
+		return 0
+	}
+
+	return s.counters[0].Count
+}
+
+func (s *SpaceSaving) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if s.counters[parent].Count <= s.counters[i].Count {
+			return
+		}
+
+		s.swap(i, parent)
+		i = parent
+	}
+}
+
+func (s *SpaceSaving) down(i int) {
+	for {
+		smallest, left, right := i, 2*i+1, 2*i+2
+
+		if left < len(s.counters) && s.counters[left].Count < s.counters[smallest].Count {
+			smallest = left
+		}
+
+		if right < len(s.counters) && s.counters[right].Count < s.counters[smallest].Count {
+			smallest = right
+		}
+
+		if smallest == i {
+			return
+		}
+
+		s.swap(i, smallest)
+		i = smallest
+	}
+}
+
+func (s *SpaceSaving) swap(i, j int) {
+	s.counters[i], s.counters[j] = s.counters[j], s.counters[i]
+	s.index[s.counters[i].Key] = i
+	s.index[s.counters[j].Key] = j
+}
+
+func sortCounters(counters []Counter) {
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Count != counters[j].Count {
+			return counters[i].Count > counters[j].Count
+		}
+
+		return counters[i].Key < counters[j].Key
+	})
+}
@@ -0,0 +1,87 @@
+package sketch
+
+// CountMinDepth — число строк Count-Min в TopK: вероятность превысить границу ошибки e^-4 < 2%.
+const CountMinDepth = 4
+
+// TopK ищет самые частые значения в памяти, пропорциональной capacity, а не числу различных значений.
+// Кандидатов отбирает SpaceSaving, а Count-Min уточняет верхнюю границу счётчиков, доставшихся
+// значениям при вытеснении.
+type TopK struct {
+	summary *SpaceSaving
+	// counts — nil после Truncate: без Count-Min счётчики берутся из сводки как есть.
+	counts *CountMin
+}
+
+// NewTopK создаёт TopK на capacity значений; ширина Count-Min — 2·capacity.
+func NewTopK(capacity int) *TopK {
+	summary := NewSpaceSaving(capacity)
+
+	return &TopK{summary: summary, counts: NewCountMin(2*summary.Capacity(), CountMinDepth)}
+}
+
+// Add учитывает вхождение key; возвращает значение, вытесненное из числа отслеживаемых.
+func (t *TopK) Add(key string) (string, bool) {
+	if t.counts != nil {
+		t.counts.Add(key, 1)
+	}
+
+	return t.summary.Add(key, 1)
+}
+
+// Contains сообщает, отслеживается ли key.
+func (t *TopK) Contains(key string) bool {
+	return t.summary.Contains(key)
+}
+
+// Capacity возвращает наибольшее число отслеживаемых значений.
+func (t *TopK) Capacity() int {
+	return t.summary.Capacity()
+}
+
+// Top возвращает до n самых частых значений. Count — верхняя граница числа вхождений,
+// Error — насколько точное число может быть меньше Count.
+func (t *TopK) Top(n int) []Counter {
+	counters := t.summary.Counters()
+
+	if t.counts == nil {
+		return counters[:min(n, len(counters))]
+	}
+
+	for i, counter := range counters {
+		lower := counter.Count - counter.Error
+		counters[i].Count = min(counter.Count, t.counts.Estimate(counter.Key))
+		counters[i].Error = counters[i].Count - lower
+	}
+
+	sortCounters(counters)
+
+	return counters[:min(n, len(counters))]
+}
+
+// Merge объединяет t с other той же ёмкости. Если у одного из них Count-Min отброшен Truncate,
+// отбрасывается и у результата: иначе его оценки перестали бы быть верхними границами.
+func (t *TopK) Merge(other *TopK) error {
+	if t.counts != nil && other.counts != nil {
+		if err := t.counts.Merge(other.counts); err != nil {
+			return err
+		}
+	} else {
+		t.counts = nil
+	}
+
+	t.summary.Merge(other.summary)
+
+	return nil
+}
+
+// Truncate оставляет n значений с наибольшими счётчиками сводки и отбрасывает Count-Min: такой TopK
+// занимает память на n значений и по-прежнему объединяется через Merge. Уточнения Count-Min при этом
+// теряются — границы остаются верными, но становятся шире.
+func (t *TopK) Truncate(n int) {
+	if n >= t.summary.Capacity() {
+		return
+	}
+
+	t.summary.Truncate(n)
+	t.counts = nil
+}
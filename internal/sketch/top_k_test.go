@@ -0,0 +1,198 @@
+package sketch_test
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipfStream возвращает n значений с распределением Ципфа и точные частоты значений.
+func zipfStream(seed int64, n int) ([]string, map[string]int64) {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(seed)), 1.2, 1, 100_000)
+	stream := make([]string, n)
+	exact := make(map[string]int64)
+
+	for i := range stream {
+		stream[i] = "/page/" + strconv.FormatUint(zipf.Uint64(), 10)
+		exact[stream[i]]++
+	}
+
+	return stream, exact
+}
+
+func TestSpaceSaving_ExactUnderCapacity(t *testing.T) {
+	summary := sketch.NewSpaceSaving(3)
+
+	summary.Add("/a", 1)
+	summary.Add("/b", 2)
+	summary.Add("/a", 2)
+
+	assert.Equal(t, []sketch.Counter{{Key: "/a", Count: 3}, {Key: "/b", Count: 2}}, summary.Counters())
+}
+
+func TestSpaceSaving_Eviction(t *testing.T) {
+	summary := sketch.NewSpaceSaving(2)
+
+	summary.Add("/a", 5)
+	summary.Add("/b", 1)
+
+	evicted, ok := summary.Add("/c", 1)
+	require.True(t, ok)
+	assert.Equal(t, "/b", evicted)
+	assert.False(t, summary.Contains("/b"))
+	assert.Equal(t, []sketch.Counter{{Key: "/a", Count: 5}, {Key: "/c", Count: 2, Error: 1}}, summary.Counters())
+}
+
+func TestSpaceSaving_Guarantee(t *testing.T) {
+	const capacity = 200
+
+	stream, exact := zipfStream(1, 100_000)
+	summary := sketch.NewSpaceSaving(capacity)
+
+	for _, key := range stream {
+		summary.Add(key, 1)
+	}
+
+	bound := int64(len(stream) / capacity)
+
+	for _, counter := range summary.Counters() {
+		assert.LessOrEqual(t, counter.Error, bound)
+		assert.GreaterOrEqual(t, counter.Count, exact[counter.Key], counter.Key)
+		assert.LessOrEqual(t, counter.Count-counter.Error, exact[counter.Key], counter.Key)
+	}
+
+	for key, count := range exact {
+		if count > bound {
+			assert.True(t, summary.Contains(key), key)
+		}
+	}
+}
+
+func TestSpaceSaving_Merge(t *testing.T) {
+	const capacity = 100
+
+	first, second := sketch.NewSpaceSaving(capacity), sketch.NewSpaceSaving(capacity)
+	firstStream, exact := zipfStream(2, 50_000)
+	secondStream, secondExact := zipfStream(3, 50_000)
+
+	for _, key := range firstStream {
+		first.Add(key, 1)
+	}
+
+	for _, key := range secondStream {
+		second.Add(key, 1)
+	}
+
+	for key, count := range secondExact {
+		exact[key] += count
+	}
+
+	first.Merge(second)
+
+	counters := first.Counters()
+	require.Len(t, counters, capacity)
+
+	for _, counter := range counters {
+		assert.GreaterOrEqual(t, counter.Count, exact[counter.Key], counter.Key)
+		assert.LessOrEqual(t, counter.Count-counter.Error, exact[counter.Key], counter.Key)
+		assert.LessOrEqual(t, counter.Error, int64(100_000/capacity))
+	}
+
+	// После слияния куча должна остаться корректной: вытесняется самый редкий счётчик.
+	evicted, ok := first.Add("/new", 1)
+	require.True(t, ok)
+
+	for _, counter := range counters {
+		if counter.Key == evicted {
+			assert.Equal(t, counters[len(counters)-1].Count, counter.Count)
+		}
+	}
+}
+
+func TestCountMin_NeverUnderestimates(t *testing.T) {
+	stream, exact := zipfStream(4, 50_000)
+	first, second := sketch.NewCountMin(512, sketch.CountMinDepth), sketch.NewCountMin(512, sketch.CountMinDepth)
+
+	for i, key := range stream {
+		if i%2 == 0 {
+			first.Add(key, 1)
+		} else {
+			second.Add(key, 1)
+		}
+	}
+
+	require.NoError(t, first.Merge(second))
+
+	for key, count := range exact {
+		assert.GreaterOrEqual(t, first.Estimate(key), count, key)
+	}
+
+	assert.Equal(t, int64(0), sketch.NewCountMin(512, sketch.CountMinDepth).Estimate("/missing"))
+	assert.ErrorIs(t, first.Merge(sketch.NewCountMin(256, sketch.CountMinDepth)), sketch.ErrShapeMismatch)
+}
+
+func TestTopK_Top(t *testing.T) {
+	stream, exact := zipfStream(5, 100_000)
+	topK := sketch.NewTopK(100)
+
+	for _, key := range stream {
+		topK.Add(key)
+	}
+
+	top := topK.Top(10)
+	require.Len(t, top, 10)
+
+	for i, counter := range top {
+		assert.GreaterOrEqual(t, counter.Count, exact[counter.Key], counter.Key)
+		assert.LessOrEqual(t, counter.Count-counter.Error, exact[counter.Key], counter.Key)
+
+		if i > 0 {
+			assert.GreaterOrEqual(t, top[i-1].Count, counter.Count)
+		}
+	}
+
+	// Самые частые значения распределения Ципфа далеко отстоят друг от друга и находятся точно.
+	assert.Equal(t, "/page/0", top[0].Key)
+	assert.Equal(t, "/page/1", top[1].Key)
+}
+
+func TestTopK_Truncate(t *testing.T) {
+	firstStream, exact := zipfStream(7, 50_000)
+	secondStream, secondExact := zipfStream(8, 50_000)
+	first, second := sketch.NewTopK(200), sketch.NewTopK(200)
+
+	for _, key := range firstStream {
+		first.Add(key)
+	}
+
+	for _, key := range secondStream {
+		second.Add(key)
+	}
+
+	for key, count := range secondExact {
+		exact[key] += count
+	}
+
+	second.Truncate(20)
+	assert.Equal(t, 20, second.Capacity())
+	assert.Len(t, second.Top(200), 20)
+
+	// Усечённый TopK объединяется с полным, границы счётчиков остаются верными.
+	require.NoError(t, first.Merge(second))
+
+	top := first.Top(10)
+	require.Len(t, top, 10)
+	assert.Equal(t, "/page/0", top[0].Key)
+
+	for _, counter := range top {
+		assert.GreaterOrEqual(t, counter.Count, exact[counter.Key], counter.Key)
+		assert.LessOrEqual(t, counter.Count-counter.Error, exact[counter.Key], counter.Key)
+	}
+
+	_, err := first.MarshalBinary()
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+// Package urlpath сводит запрошенные ресурсы к шаблонам путей: /users/123?tab=1 и /users/456
+// считаются одним эндпоинтом /users/{id}.
+package urlpath
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/4domm/ngxstat/internal/domain"
+)
+
+const (
+	PlaceholderID   = "{id}"
+	PlaceholderUUID = "{uuid}"
+	PlaceholderHash = "{hash}"
+
+	// MinHashLength — с какой длины шестнадцатеричный сегмент считается хэшем (MD5, SHA-1 и т. п.).
+	MinHashLength = 16
+
+	// RewriteSeparator разделяет выражение и замену в правиле --rewrite.
+	RewriteSeparator = "=>"
+)
+
+// rewrite — пользовательское правило: все совпадения pattern заменяются на replacement
+// с подстановкой групп $1, ${name}, как в regexp.ReplaceAllString.
+type rewrite struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// Normalizer строит шаблон пути ресурса. Безопасен для одновременного использования.
+type Normalizer struct {
+	rewrites []rewrite
+}
+
+// NewNormalizer компилирует правила вида "выражение=>замена". Правила применяются по порядку,
+// каждое к результату предыдущего, до встроенных замен сегментов.
+func NewNormalizer(rules []string) (*Normalizer, error) {
+	normalizer := &Normalizer{rewrites: make([]rewrite, 0, len(rules))}
+
+	for _, rule := range rules {
+		expression, replacement, ok := strings.Cut(rule, RewriteSeparator)
+		if !ok {
+			return nil, &domain.InvalidRewriteRuleError{Rule: rule, Reason: "ожидается выражение" + RewriteSeparator + "замена"}
+		}
+
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, &domain.InvalidRewriteRuleError{Rule: rule, Reason: "неверное регулярное выражение: " + err.Error()}
+		}
+
+		normalizer.rewrites = append(normalizer.rewrites, rewrite{pattern: pattern, replacement: replacement})
+	}
+
+	return normalizer, nil
+}
+
+// ForConfig создаёт нормализатор с правилами --rewrite запуска.
+func ForConfig(inputConfig *domain.InputConfig) (*Normalizer, error) {
+	return NewNormalizer(inputConfig.Rewrites)
+}
+
+// Template возвращает шаблон ресурса: путь без query string и фрагмента, к которому применены
+// правила --rewrite, а затем встроенные замены сегментов — числа на {id}, UUID на {uuid},
+// шестнадцатеричные хэши на {hash}.
+func (n *Normalizer) Template(resource string) string {
+	path := Path(resource)
+
+	for _, r := range n.rewrites {
+		path = r.pattern.ReplaceAllString(path, r.replacement)
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = templateSegment(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// Path возвращает путь ресурса без query string и фрагмента.
+func Path(resource string) string {
+	if i := strings.IndexAny(resource, "?#"); i >= 0 {
+		return resource[:i]
+	}
+
+	return resource
+}
+
+// Split делит ресурс на путь и декодированные параметры query string; фрагмент отбрасывается.
+// Без query string параметры — nil. Неверно закодированные пары пропускаются, остальные сохраняются.
+func Split(resource string) (string, url.Values) {
+	resource, _, _ = strings.Cut(resource, "#")
+
+	path, rawQuery, ok := strings.Cut(resource, "?")
+	if !ok || rawQuery == "" {
+		return path, nil
+	}
+
+	query, _ := url.ParseQuery(rawQuery)
+
+	return path, query
+}
+
+func templateSegment(segment string) string {
+	switch {
+	case segment == "":
+		return segment
+	case all(segment, isDigit):
+		return PlaceholderID
+	case isUUID(segment):
+		return PlaceholderUUID
+	case len(segment) >= MinHashLength && all(segment, isHexDigit):
+		return PlaceholderHash
+	default:
+		return segment
+	}
+}
+
+// isUUID проверяет запись UUID 8-4-4-4-12 шестнадцатеричных цифр.
+func isUUID(segment string) bool {
+	if len(segment) != 36 {
+		return false
+	}
+
+	for i := 0; i < len(segment); i++ {
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if segment[i] != '-' {
+				return false
+			}
+		} else if !isHexDigit(segment[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func all(segment string, predicate func(byte) bool) bool {
+	for i := 0; i < len(segment); i++ {
+		if !predicate(segment[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,90 @@
+package urlpath_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/domain"
+	"github.com/4domm/ngxstat/internal/urlpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizer_Template(t *testing.T) {
+	normalizer, err := urlpath.NewNormalizer(nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{"/", "/"},
+		{"/users/123", "/users/{id}"},
+		{"/users/456?tab=posts&page=2", "/users/{id}"},
+		{"/users/42/orders/7#top", "/users/{id}/orders/{id}"},
+		{"/orders/3f2a9c1e-0b7d-4c2e-9a51-6d8e7f001122", "/orders/{uuid}"},
+		{"/blobs/d41d8cd98f00b204e9800998ecf8427e", "/blobs/{hash}"},
+		{"/blobs/cafebabe", "/blobs/cafebabe"},
+		{"/v2/api/items/", "/v2/api/items/"},
+		{"/search?q=123", "/search"},
+		{"*", "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizer.Template(tt.resource))
+		})
+	}
+}
+
+func TestNormalizer_Rewrites(t *testing.T) {
+	normalizer, err := urlpath.NewNormalizer([]string{
+		`^/static/.*\.(js|css)$=>/static/*.$1`,
+		`^/@[^/]+=>/@{user}`,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "/static/*.js", normalizer.Template("/static/app.3f2a.js?v=1"))
+	assert.Equal(t, "/static/*.css", normalizer.Template("/static/css/main.css"))
+	assert.Equal(t, "/@{user}/posts/{id}", normalizer.Template("/@alice/posts/15"))
+	assert.Equal(t, "/static/logo.png", normalizer.Template("/static/logo.png"))
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		resource string
+		path     string
+		query    url.Values
+	}{
+		{"/", "/", nil},
+		{"/search?", "/search", nil},
+		{"/search?q=go+lang&page=2", "/search", url.Values{"q": {"go lang"}, "page": {"2"}}},
+		{"/a?utm_source=mail&utm_source=ads#top", "/a", url.Values{"utm_source": {"mail", "ads"}}},
+		{"/a?name=%D0%B8%D0%BC%D1%8F&flag", "/a", url.Values{"name": {"имя"}, "flag": {""}}},
+		{"/a?bad=%zz&ok=1", "/a", url.Values{"ok": {"1"}}},
+		{"/page#section?x=1", "/page", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			path, query := urlpath.Split(tt.resource)
+
+			assert.Equal(t, tt.path, path)
+			assert.Equal(t, tt.query, query)
+			assert.Equal(t, tt.path, urlpath.Path(tt.resource))
+		})
+	}
+}
+
+func TestNewNormalizer_Errors(t *testing.T) {
+	for _, rule := range []string{"/static/.*", "(unclosed=>/x"} {
+		_, err := urlpath.NewNormalizer([]string{rule})
+
+		var ruleErr *domain.InvalidRewriteRuleError
+		require.ErrorAs(t, err, &ruleErr, rule)
+		assert.Equal(t, rule, ruleErr.Rule)
+	}
+
+	_, err := urlpath.ForConfig(&domain.InputConfig{Rewrites: []string{"^/a=>/b"}})
+	assert.NoError(t, err)
+}
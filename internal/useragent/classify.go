@@ -0,0 +1,168 @@
+// Package useragent относит строку User-Agent к семейству браузера, операционной системе и классу
+// устройства по встроенному набору правил rules.txt, без обращения к внешним базам.
+package useragent
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// Other — семейство или ОС, которые не распознаны правилами.
+	Other = "Other"
+
+	DeviceBot     = "bot"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceOther   = "other"
+
+	// MaxCachedAgents ограничивает кэш классификации: после заполнения новые строки классифицируются
+	// заново при каждом вызове.
+	MaxCachedAgents = 10000
+)
+
+//go:embed rules.txt
+var rulesFile string
+
+var defaultClassifier = mustNewClassifier(rulesFile)
+
+// Class — результат классификации User-Agent.
+type Class struct {
+	Family string
+	OS     string
+	Device string
+}
+
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Classifier применяет правила к строкам User-Agent. Безопасен для одновременного использования:
+// результаты кэшируются, потому что в логах одни и те же строки повторяются тысячи раз.
+type Classifier struct {
+	bots     []rule
+	families []rule
+	oses     []rule
+	devices  []rule
+	cache    sync.Map
+	cached   atomic.Int64
+}
+
+// Classify классифицирует userAgent встроенным набором правил.
+func Classify(userAgent string) Class {
+	return defaultClassifier.Classify(userAgent)
+}
+
+// NewClassifier разбирает правила в формате rules.txt: строки "вид имя выражение", пустые строки
+// и комментарии с # пропускаются.
+func NewClassifier(rules string) (*Classifier, error) {
+	classifier := &Classifier{}
+	scanner := bufio.NewScanner(strings.NewReader(rules))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("правило %d: ожидается \"вид имя выражение\"", line)
+		}
+
+		expression := strings.TrimSpace(strings.TrimSpace(text[len(fields[0]):])[len(fields[1]):])
+
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("правило %d: %w", line, err)
+		}
+
+		target := classifier.kind(fields[0])
+		if target == nil {
+			return nil, fmt.Errorf("правило %d: неизвестный вид %q", line, fields[0])
+		}
+
+		*target = append(*target, rule{name: fields[1], pattern: pattern})
+	}
+
+	return classifier, scanner.Err()
+}
+
+func mustNewClassifier(rules string) *Classifier {
+	classifier, err := NewClassifier(rules)
+	if err != nil {
+		panic("useragent: " + err.Error())
+	}
+
+	return classifier
+}
+
+func (c *Classifier) kind(name string) *[]rule {
+	switch name {
+	case "bot":
+		return &c.bots
+	case "family":
+		return &c.families
+	case "os":
+		return &c.oses
+	case "device":
+		return &c.devices
+	default:
+		return nil
+	}
+}
+
+// Classify возвращает класс userAgent. Для ботов семейство — имя бота, класс устройства — bot;
+// пустая строка User-Agent тоже считается ботом: браузеры её не отправляют.
+func (c *Classifier) Classify(userAgent string) Class {
+	if class, ok := c.cache.Load(userAgent); ok {
+		return class.(Class)
+	}
+
+	class := c.classify(userAgent)
+
+	if c.cached.Load() < MaxCachedAgents {
+		if _, loaded := c.cache.LoadOrStore(userAgent, class); !loaded {
+			c.cached.Add(1)
+		}
+	}
+
+	return class
+}
+
+func (c *Classifier) classify(userAgent string) Class {
+	class := Class{Family: Other, OS: match(c.oses, userAgent, Other), Device: DeviceOther}
+
+	bot := match(c.bots, userAgent, "")
+	if bot == "" && (strings.TrimSpace(userAgent) == "" || userAgent == "-") {
+		bot = Other
+	}
+
+	if bot != "" {
+		class.Family, class.Device = bot, DeviceBot
+		return class
+	}
+
+	class.Family = match(c.families, userAgent, Other)
+	class.Device = match(c.devices, userAgent, DeviceOther)
+
+	return class
+}
+
+// match возвращает имя первого подошедшего правила или fallback.
+func match(rules []rule, userAgent, fallback string) string {
+	for _, r := range rules {
+		if r.pattern.MatchString(userAgent) {
+			return r.name
+		}
+	}
+
+	return fallback
+}
@@ -0,0 +1,86 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/4domm/ngxstat/internal/useragent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      useragent.Class
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			useragent.Class{Family: "Chrome", OS: "Windows", Device: useragent.DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
+			useragent.Class{Family: "Edge", OS: "Windows", Device: useragent.DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			useragent.Class{Family: "Safari", OS: "macOS", Device: useragent.DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			useragent.Class{Family: "Firefox", OS: "Linux", Device: useragent.DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) " +
+				"Version/17.4 Mobile/15E148 Safari/604.1",
+			useragent.Class{Family: "Safari", OS: "iOS", Device: useragent.DeviceMobile},
+		},
+		{
+			"Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0 Mobile/15E148 Safari/604.1",
+			useragent.Class{Family: "Chrome", OS: "iOS", Device: useragent.DeviceTablet},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Mobile Safari/537.36",
+			useragent.Class{Family: "Chrome", OS: "Android", Device: useragent.DeviceMobile},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0 Safari/537.36",
+			useragent.Class{Family: "SamsungInternet", OS: "Android", Device: useragent.DeviceTablet},
+		},
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			useragent.Class{Family: "Googlebot", OS: useragent.Other, Device: useragent.DeviceBot},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Mobile Safari/537.36 " +
+				"(compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			useragent.Class{Family: "Googlebot", OS: "Android", Device: useragent.DeviceBot},
+		},
+		{"curl/8.5.0", useragent.Class{Family: "curl", OS: useragent.Other, Device: useragent.DeviceBot}},
+		{"python-requests/2.31.0", useragent.Class{Family: "python-requests", OS: useragent.Other, Device: useragent.DeviceBot}},
+		{"Mozilla/5.0 (compatible; MJ12bot/v1.4.8)", useragent.Class{Family: useragent.Other, OS: useragent.Other, Device: useragent.DeviceBot}},
+		{"", useragent.Class{Family: useragent.Other, OS: useragent.Other, Device: useragent.DeviceBot}},
+		{"-", useragent.Class{Family: useragent.Other, OS: useragent.Other, Device: useragent.DeviceBot}},
+		{"SomeApp/1.0", useragent.Class{Family: useragent.Other, OS: useragent.Other, Device: useragent.DeviceOther}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			assert.Equal(t, tt.want, useragent.Classify(tt.userAgent))
+			// Повторный вызов берёт результат из кэша.
+			assert.Equal(t, tt.want, useragent.Classify(tt.userAgent))
+		})
+	}
+}
+
+func TestNewClassifier(t *testing.T) {
+	classifier, err := useragent.NewClassifier("# комментарий\n\nbot  Probe  probe v\\d\nfamily Cli  ^cli/\n")
+	require.NoError(t, err)
+
+	assert.Equal(t, useragent.Class{Family: "Probe", OS: useragent.Other, Device: useragent.DeviceBot}, classifier.Classify("probe v2"))
+	assert.Equal(t, useragent.Class{Family: "Cli", OS: useragent.Other, Device: useragent.DeviceOther}, classifier.Classify("cli/1.0"))
+
+	for _, rules := range []string{"bot Probe", "browser Cli ^cli/", "os Windows (Windows"} {
+		_, err := useragent.NewClassifier(rules)
+		assert.Error(t, err, rules)
+	}
+}